@@ -4,20 +4,31 @@ import (
 	"errors"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
+	"github.com/theartofdevel/notes_system/api_service/internal/account"
 	"github.com/theartofdevel/notes_system/api_service/internal/client/category_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/file_service"
 	"github.com/theartofdevel/notes_system/api_service/internal/client/note_service"
 	"github.com/theartofdevel/notes_system/api_service/internal/client/tag_service"
 	"github.com/theartofdevel/notes_system/api_service/internal/client/user_service"
 	"github.com/theartofdevel/notes_system/api_service/internal/config"
+	"github.com/theartofdevel/notes_system/api_service/internal/handlers/admin"
 	"github.com/theartofdevel/notes_system/api_service/internal/handlers/auth"
 	"github.com/theartofdevel/notes_system/api_service/internal/handlers/categories"
+	"github.com/theartofdevel/notes_system/api_service/internal/handlers/files"
 	"github.com/theartofdevel/notes_system/api_service/internal/handlers/notes"
 	"github.com/theartofdevel/notes_system/api_service/internal/handlers/tags"
+	"github.com/theartofdevel/notes_system/api_service/internal/handlers/tokens"
+	"github.com/theartofdevel/notes_system/api_service/internal/handlers/users"
+	"github.com/theartofdevel/notes_system/api_service/internal/lockout"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache/bolt"
 	"github.com/theartofdevel/notes_system/api_service/pkg/cache/freecache"
 	"github.com/theartofdevel/notes_system/api_service/pkg/handlers/metric"
 	"github.com/theartofdevel/notes_system/api_service/pkg/jwt"
 	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+	"github.com/theartofdevel/notes_system/api_service/pkg/oidc"
 	"github.com/theartofdevel/notes_system/api_service/pkg/shutdown"
+	"io"
 	"net"
 	"net/http"
 	"os"
@@ -39,37 +50,130 @@ func main() {
 	router := httprouter.New()
 
 	logger.Println("cache initializing")
-	refreshTokenCache := freecache.NewCacheRepo(104857600) // 100MB
+	var closers []io.Closer
+	var refreshTokenCache cache.Repository
+	switch cfg.RefreshTokenStore.Type {
+	case "bolt":
+		logger.Infof("refresh tokens are stored in %s", cfg.RefreshTokenStore.Path)
+		boltCache, err := bolt.NewCacheRepo(cfg.RefreshTokenStore.Path)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		refreshTokenCache = boltCache
+		closers = append(closers, boltCache.(io.Closer))
+	case "memory":
+		refreshTokenCache = freecache.NewCacheRepo(104857600) // 100MB
+	default:
+		logger.Fatalf("unknown refresh token store type: %s", cfg.RefreshTokenStore.Type)
+	}
+	loginAttemptsCache := freecache.NewCacheRepo(10485760) // 10MB
 
 	logger.Println("helpers initializing")
-	jwtHelper := jwt.NewHelper(refreshTokenCache, logger)
+	keySet, err := jwt.NewKeySet(jwt.KeySetConfig{
+		Algorithm: cfg.JWT.Algorithm,
+		Dir:       cfg.JWT.KeysDir,
+		Rotation:  time.Duration(cfg.JWT.KeyRotation) * time.Second,
+		Retention: jwt.AccessTokenTTL,
+	}, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	go keySet.RunRotation(nil)
+	jwt.SetKeySet(keySet)
+	userService := user_service.NewService(cfg.UserService.URL, "/users", logger)
+	jwtHelper := jwt.NewHelper(keySet, refreshTokenCache, userService, time.Duration(cfg.JWT.RefreshTTL)*time.Second, logger)
 
 	logger.Println("create and register handlers")
 
 	metricHandler := metric.Handler{Logger: logger}
 	metricHandler.Register(router)
 
-	userService := user_service.NewService(cfg.UserService.URL, "/users", logger)
-	authHandler := auth.Handler{JWTHelper: jwtHelper, UserService: userService, Logger: logger}
+	authHandler := auth.Handler{
+		JWTHelper:            jwtHelper,
+		KeySet:               keySet,
+		UserService:          userService,
+		Logger:               logger,
+		RequireVerifiedEmail: cfg.Auth.RequireVerifiedEmail,
+		TrustForwardedFor:    cfg.Auth.TrustForwardedFor,
+		AccountLimiter: lockout.NewLimiter(loginAttemptsCache, "account:", lockout.Config{
+			MaxAttempts: cfg.Auth.Lockout.AccountMaxAttempts,
+			BaseLockout: time.Duration(cfg.Auth.Lockout.BaseLockout) * time.Second,
+			MaxLockout:  time.Duration(cfg.Auth.Lockout.MaxLockout) * time.Second,
+			Window:      time.Duration(cfg.Auth.Lockout.Window) * time.Second,
+		}),
+		IPLimiter: lockout.NewLimiter(loginAttemptsCache, "ip:", lockout.Config{
+			MaxAttempts: cfg.Auth.Lockout.IPMaxAttempts,
+			BaseLockout: time.Duration(cfg.Auth.Lockout.BaseLockout) * time.Second,
+			MaxLockout:  time.Duration(cfg.Auth.Lockout.MaxLockout) * time.Second,
+			Window:      time.Duration(cfg.Auth.Lockout.Window) * time.Second,
+		}),
+	}
+	if cfg.Auth.OIDC.Enabled {
+		logger.Infof("login with identity provider %s is enabled", cfg.Auth.OIDC.Issuer)
+		authHandler.OIDC = oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.Auth.OIDC.Issuer,
+			ClientID:     cfg.Auth.OIDC.ClientID,
+			ClientSecret: cfg.Auth.OIDC.ClientSecret,
+			RedirectURL:  cfg.Auth.OIDC.RedirectURL,
+			Scopes:       cfg.Auth.OIDC.Scopes,
+		}, logger)
+		authHandler.OIDCStates = freecache.NewCacheRepo(10485760) // 10MB
+	}
 	authHandler.Register(router)
 
+	fileService := file_service.NewService(cfg.FileService.URL, "/files", logger)
+	adminHandler := admin.Handler{UserService: userService, FileService: fileService, JWTHelper: jwtHelper, Logger: logger}
+	adminHandler.Register(router)
+
+	jwt.SetAPITokenAuthenticator(userService)
+	tokensHandler := tokens.Handler{UserService: userService, Logger: logger}
+	tokensHandler.Register(router)
+
 	categoryService := category_service.NewService(cfg.CategoryService.URL, "/categories", logger)
 	categoriesHandler := categories.Handler{CategoryService: categoryService, Logger: logger}
 	categoriesHandler.Register(router)
 
 	noteService := note_service.NewService(cfg.NoteService.URL, "/notes", logger)
-	notesHandler := notes.Handler{NoteService: noteService, Logger: logger}
-	notesHandler.Register(router)
-
 	tagService := tag_service.NewService(cfg.TagService.URL, "/tags", logger)
-	tagsHandler := tags.Handler{TagService: tagService, Logger: logger}
+	tagsHandler := tags.Handler{TagService: tagService, NoteService: noteService, Logger: logger}
+	if cfg.TagsStats.CacheTTL > 0 {
+		tagsHandler.StatsCache = freecache.NewCacheRepo(10485760) // 10MB
+		tagsHandler.StatsCacheTTL = cfg.TagsStats.CacheTTL
+	}
 	tagsHandler.Register(router)
 
+	notesHandler := notes.Handler{NoteService: noteService, TagsStatsCache: tagsHandler.StatsCache, Logger: logger}
+	notesHandler.Register(router)
+
+	filesHandler := files.Handler{
+		Logger:          logger,
+		FileService:     fileService,
+		NoteService:     noteService,
+		CategoryService: categoryService,
+	}
+	filesHandler.Register(router)
+
+	usersHandler := users.Handler{
+		Logger:          logger,
+		UserService:     userService,
+		CategoryService: categoryService,
+		JWTHelper:       jwtHelper,
+		Deleter: &account.Deleter{
+			CategoryService: categoryService,
+			NoteService:     noteService,
+			TagService:      tagService,
+			FileService:     fileService,
+			UserService:     userService,
+			Logger:          logger,
+		},
+	}
+	usersHandler.Register(router)
+
 	logger.Println("start application")
-	start(router, logger, cfg)
+	start(router, logger, cfg, closers...)
 }
 
-func start(router *httprouter.Router, logger logging.Logger, cfg *config.Config) {
+func start(router *httprouter.Router, logger logging.Logger, cfg *config.Config, closers ...io.Closer) {
 	var server *http.Server
 	var listener net.Listener
 
@@ -104,7 +208,7 @@ func start(router *httprouter.Router, logger logging.Logger, cfg *config.Config)
 	}
 
 	go shutdown.Graceful([]os.Signal{syscall.SIGABRT, syscall.SIGQUIT, syscall.SIGHUP, os.Interrupt, syscall.SIGTERM},
-		server)
+		append([]io.Closer{server}, closers...)...)
 
 	logger.Println("application initialized and started")
 
@@ -0,0 +1,33 @@
+// mockoidc runs an OpenID Connect provider which signs in a fixed user without a login page,
+// point auth.oidc.issuer of the config to it to try OIDC login locally
+package main
+
+import (
+	"flag"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+	"github.com/theartofdevel/notes_system/api_service/pkg/oidc/mockoidc"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9999", "address to listen on")
+	clientID := flag.String("client-id", "notes", "client id")
+	clientSecret := flag.String("client-secret", "secret", "client secret")
+	subject := flag.String("subject", "mock-user", "subject of the signed in user")
+	email := flag.String("email", "mock@example.com", "email of the signed in user")
+	emailVerified := flag.Bool("email-verified", true, "whether the email is verified")
+	flag.Parse()
+
+	logging.Init()
+	logger := logging.GetLogger()
+
+	server, err := mockoidc.New(*clientID, *clientSecret)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	server.Issuer = "http://" + *addr
+	server.User = mockoidc.User{Subject: *subject, Email: *email, EmailVerified: *emailVerified}
+
+	logger.Infof("mock identity provider %s signs in %s", server.Issuer, *email)
+	logger.Fatal(http.ListenAndServe(*addr, server))
+}
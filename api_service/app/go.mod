@@ -11,4 +11,5 @@ require (
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/sirupsen/logrus v1.8.1
 	github.com/stretchr/testify v1.2.2
+	go.etcd.io/bbolt v1.3.6
 )
@@ -0,0 +1,185 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/category_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/file_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/note_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/tag_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/user_service"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+)
+
+const (
+	StatusDeleted = "deleted"
+	StatusPartial = "partial"
+
+	StepDone    = "done"
+	StepFailed  = "failed"
+	StepSkipped = "skipped"
+)
+
+// Step is the outcome of deleting user data in one service
+type Step struct {
+	Service string `json:"service"`
+	Status  string `json:"status"`
+	Deleted int    `json:"deleted"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Report tells which data was deleted, a partial deletion can be repeated to finish it
+type Report struct {
+	UserUUID string `json:"user_uuid"`
+	Status   string `json:"status"`
+	Steps    []Step `json:"steps"`
+}
+
+func (r Report) Completed() bool {
+	return r.Status == StatusDeleted
+}
+
+// Deleter removes the account with all data it owns. Data is deleted from the leaves up:
+// files before their notes, notes before their categories and the user last,
+// so nothing is left without an owner when a service fails in the middle.
+type Deleter struct {
+	CategoryService category_service.CategoryService
+	NoteService     note_service.NoteService
+	TagService      tag_service.TagService
+	FileService     file_service.FileService
+	UserService     user_service.UserService
+	Logger          logging.Logger
+}
+
+func (d *Deleter) Delete(ctx context.Context, userUUID string) Report {
+	files := Step{Service: "file_service"}
+	notes := Step{Service: "note_service"}
+	categories := Step{Service: "category_service"}
+
+	categoryUUIDs, err := d.userCategories(ctx, userUUID)
+	if err != nil {
+		categories.fail(err)
+		files.Status, notes.Status = StepSkipped, StepSkipped
+	} else {
+		for _, categoryUUID := range categoryUUIDs {
+			d.deleteNotes(ctx, categoryUUID, &files, &notes)
+		}
+		files.done()
+		notes.done()
+
+		if notes.Status == StepDone {
+			deleted, err := d.CategoryService.DeleteUserCategories(ctx, userUUID)
+			if err != nil && !errors.Is(err, apperror.ErrNotFound) {
+				categories.fail(err)
+			} else {
+				categories.Deleted = deleted
+				categories.Status = StepDone
+			}
+		} else {
+			categories.Status = StepSkipped
+		}
+	}
+
+	tags := Step{Service: "tag_service"}
+	deleted, err := d.TagService.DeleteByOwner(ctx, userUUID)
+	if err != nil {
+		tags.fail(err)
+	} else {
+		tags.Deleted = deleted
+		tags.Status = StepDone
+	}
+
+	report := Report{UserUUID: userUUID, Steps: []Step{files, notes, categories, tags}}
+	user := Step{Service: "user_service", Status: StepSkipped}
+	if categories.Status == StepDone && tags.Status == StepDone {
+		if err = d.UserService.Delete(ctx, userUUID); err != nil {
+			user.fail(err)
+		} else {
+			user.Deleted = 1
+			user.Status = StepDone
+		}
+	}
+	report.Steps = append(report.Steps, user)
+
+	report.Status = StatusDeleted
+	if user.Status != StepDone {
+		report.Status = StatusPartial
+	}
+	for _, step := range report.Steps {
+		if step.Status == StepFailed {
+			d.Logger.Errorf("failed to delete data of user %s in %s: %s", userUUID, step.Service, step.Error)
+		}
+	}
+
+	return report
+}
+
+// deleteNotes deletes files of every note in the category and then the notes,
+// notes are kept if any of their files is left
+func (d *Deleter) deleteNotes(ctx context.Context, categoryUUID string, files, notes *Step) {
+	notesBytes, err := d.NoteService.GetByCategoryUUID(ctx, categoryUUID)
+	if err != nil {
+		if !errors.Is(err, apperror.ErrNotFound) {
+			notes.fail(err)
+		}
+		return
+	}
+	var categoryNotes []note_service.Note
+	if err = json.Unmarshal(notesBytes, &categoryNotes); err != nil {
+		notes.fail(err)
+		return
+	}
+
+	filesLeft := false
+	for _, n := range categoryNotes {
+		deleted, err := d.FileService.DeleteByNoteUUID(ctx, n.UUID)
+		if err != nil {
+			files.fail(err)
+			filesLeft = true
+			continue
+		}
+		files.Deleted += deleted
+	}
+	if filesLeft {
+		notes.fail(errors.New("notes with undeleted files are kept"))
+		return
+	}
+
+	deleted, err := d.NoteService.DeleteByCategoryUUID(ctx, categoryUUID)
+	if err != nil {
+		notes.fail(err)
+		return
+	}
+	notes.Deleted += deleted
+}
+
+func (d *Deleter) userCategories(ctx context.Context, userUUID string) ([]string, error) {
+	categoriesBytes, err := d.CategoryService.GetUserCategories(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var categories []category_service.Category
+	if err = json.Unmarshal(categoriesBytes, &categories); err != nil {
+		return nil, err
+	}
+	return category_service.UUIDs(categories), nil
+}
+
+// fail keeps the first error, the step is failed once anything in it failed
+func (s *Step) fail(err error) {
+	if s.Status != StepFailed {
+		s.Error = err.Error()
+	}
+	s.Status = StepFailed
+}
+
+func (s *Step) done() {
+	if s.Status == "" {
+		s.Status = StepDone
+	}
+}
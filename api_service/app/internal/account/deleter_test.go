@@ -0,0 +1,130 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/category_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/file_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/note_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/tag_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/user_service"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+)
+
+type fakeCategories struct {
+	category_service.CategoryService
+	deleted bool
+}
+
+func (f *fakeCategories) GetUserCategories(context.Context, string) ([]byte, error) {
+	return []byte(`[{"uuid":"root","name":"root","children":[{"uuid":"child","name":"child"}]}]`), nil
+}
+
+func (f *fakeCategories) DeleteUserCategories(context.Context, string) (int, error) {
+	f.deleted = true
+	return 2, nil
+}
+
+type fakeNotes struct {
+	note_service.NoteService
+	deleted []string
+}
+
+func (f *fakeNotes) GetByCategoryUUID(_ context.Context, categoryUUID string) ([]byte, error) {
+	if categoryUUID == "child" {
+		return nil, apperror.ErrNotFound
+	}
+	return []byte(`[{"uuid":"note1"},{"uuid":"note2"}]`), nil
+}
+
+func (f *fakeNotes) DeleteByCategoryUUID(_ context.Context, categoryUUID string) (int, error) {
+	f.deleted = append(f.deleted, categoryUUID)
+	return 2, nil
+}
+
+type fakeFiles struct {
+	file_service.FileService
+	failNote string
+}
+
+func (f *fakeFiles) DeleteByNoteUUID(_ context.Context, noteUUID string) (int, error) {
+	if noteUUID == f.failNote {
+		return 0, errors.New("file_service is unavailable")
+	}
+	return 1, nil
+}
+
+type fakeTags struct {
+	tag_service.TagService
+}
+
+func (f *fakeTags) DeleteByOwner(context.Context, string) (int, error) {
+	return 3, nil
+}
+
+type fakeUsers struct {
+	user_service.UserService
+	deleted bool
+}
+
+func (f *fakeUsers) Delete(context.Context, string) error {
+	f.deleted = true
+	return nil
+}
+
+func newTestDeleter(files *fakeFiles) (*Deleter, *fakeCategories, *fakeNotes, *fakeUsers) {
+	categories, notes, users := &fakeCategories{}, &fakeNotes{}, &fakeUsers{}
+	return &Deleter{
+		CategoryService: categories,
+		NoteService:     notes,
+		TagService:      &fakeTags{},
+		FileService:     files,
+		UserService:     users,
+		Logger:          logging.Logger{Entry: logrus.NewEntry(logrus.New())},
+	}, categories, notes, users
+}
+
+func TestDelete(t *testing.T) {
+	d, categories, notes, users := newTestDeleter(&fakeFiles{})
+
+	// Test scenario:
+	// 1. files, notes, categories and tags of the user are deleted
+	// 2. the user is deleted last
+	report := d.Delete(context.Background(), "user")
+	assert.True(t, report.Completed())
+	assert.Equal(t, []Step{
+		{Service: "file_service", Status: StepDone, Deleted: 2},
+		{Service: "note_service", Status: StepDone, Deleted: 2},
+		{Service: "category_service", Status: StepDone, Deleted: 2},
+		{Service: "tag_service", Status: StepDone, Deleted: 3},
+		{Service: "user_service", Status: StepDone, Deleted: 1},
+	}, report.Steps)
+	assert.Equal(t, []string{"root"}, notes.deleted)
+	assert.True(t, categories.deleted)
+	assert.True(t, users.deleted)
+}
+
+func TestDeleteKeepsOwnersOfUndeletedFiles(t *testing.T) {
+	d, categories, notes, users := newTestDeleter(&fakeFiles{failNote: "note2"})
+
+	// Test scenario:
+	// 1. a file of a note can not be deleted
+	// 2. the note, its category and the user are kept, so the deletion can be repeated
+	report := d.Delete(context.Background(), "user")
+	assert.False(t, report.Completed())
+	assert.Equal(t, StatusPartial, report.Status)
+	assert.Equal(t, StepFailed, report.Steps[0].Status)
+	assert.Equal(t, 1, report.Steps[0].Deleted)
+	assert.Equal(t, StepFailed, report.Steps[1].Status)
+	assert.Equal(t, StepSkipped, report.Steps[2].Status)
+	assert.Equal(t, StepDone, report.Steps[3].Status)
+	assert.Equal(t, StepSkipped, report.Steps[4].Status)
+	assert.Empty(t, notes.deleted)
+	assert.False(t, categories.deleted)
+	assert.False(t, users.deleted)
+}
@@ -6,7 +6,10 @@ import (
 )
 
 var (
-	ErrNotFound = NewAppError("not found", "NS-000010", "")
+	ErrNotFound         = NewAppError("not found", "NS-000010", "")
+	ErrEmailNotVerified = NewAppError("email is not verified", "NS-000011", "follow the link sent to the email")
+	ErrAccountLocked    = NewAppError("too many failed login attempts", "NS-000012", "try again after Retry-After seconds")
+	ErrForbidden        = NewAppError("access denied", "NS-000013", "the user does not have a required role")
 )
 
 type AppError struct {
@@ -17,6 +17,14 @@ func Middleware(h appHandler) http.HandlerFunc {
 					w.WriteHeader(http.StatusNotFound)
 					w.Write(ErrNotFound.Marshal())
 					return
+				} else if errors.Is(err, ErrAccountLocked) {
+					w.WriteHeader(http.StatusTooManyRequests)
+					w.Write(ErrAccountLocked.Marshal())
+					return
+				} else if errors.Is(err, ErrForbidden) {
+					w.WriteHeader(http.StatusForbidden)
+					w.Write(ErrForbidden.Marshal())
+					return
 				}
 				err := err.(*AppError)
 				w.WriteHeader(http.StatusBadRequest)
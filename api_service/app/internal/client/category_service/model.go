@@ -1,5 +1,17 @@
 package category_service
 
+// Category is a node of the user's category tree
+type Category struct {
+	UUID       string     `json:"uuid"`
+	Name       string     `json:"name"`
+	ParentUUID string     `json:"parent_uuid,omitempty"`
+	Children   []Category `json:"children,omitempty"`
+}
+
+type DeleteResult struct {
+	Deleted int `json:"deleted"`
+}
+
 type CreateCategoryDTO struct {
 	Name       string `json:"name"`
 	UserUuid   string `json:"user_uuid"`
@@ -17,3 +29,13 @@ type DeleteCategoryDTO struct {
 	Uuid     string `json:"uuid"`
 	UserUuid string `json:"user_uuid"`
 }
+
+// UUIDs returns uuids of the categories and all their subcategories
+func UUIDs(categories []Category) []string {
+	var uuids []string
+	for _, c := range categories {
+		uuids = append(uuids, c.UUID)
+		uuids = append(uuids, UUIDs(c.Children)...)
+	}
+	return uuids
+}
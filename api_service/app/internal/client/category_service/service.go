@@ -37,6 +37,7 @@ type CategoryService interface {
 	CreateCategory(ctx context.Context, dto CreateCategoryDTO) (string, error)
 	UpdateCategory(ctx context.Context, uuid string, dto UpdateCategoryDTO) error
 	DeleteCategory(ctx context.Context, dto DeleteCategoryDTO) error
+	DeleteUserCategories(ctx context.Context, userUuid string) (int, error)
 }
 
 func (c *client) GetUserCategories(ctx context.Context, userUuid string) ([]byte, error) {
@@ -80,6 +81,9 @@ func (c *client) GetUserCategories(ctx context.Context, userUuid string) ([]byte
 		}
 		return categories, nil
 	}
+	if response.StatusCode() == http.StatusNotFound {
+		return nil, apperror.ErrNotFound
+	}
 	return nil, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
 }
 
@@ -207,3 +211,49 @@ func (c *client) DeleteCategory(ctx context.Context, dto DeleteCategoryDTO) erro
 	}
 	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
 }
+
+func (c *client) DeleteUserCategories(ctx context.Context, userUuid string) (int, error) {
+	var result DeleteResult
+
+	c.base.Logger.Debug("add user_uuid to filter options")
+	filters := []rest.FilterOptions{
+		{
+			Field:  "user_uuid",
+			Values: []string{userUuid},
+		},
+	}
+
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.Resource, filters)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodDelete, uri, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request due to error: %v", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&result); err != nil {
+			return 0, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return result.Deleted, nil
+	}
+	if response.StatusCode() == http.StatusNotFound {
+		return 0, apperror.ErrNotFound
+	}
+	return 0, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
@@ -0,0 +1,63 @@
+package file_service
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+type File struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	OriginalName string    `json:"original_name"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"content_type"`
+	SHA256       string    `json:"sha256"`
+	CreatedAt    time.Time `json:"created_at"`
+	// Status is quarantined until the file is scanned for malware, it can't be downloaded until then
+	Status string `json:"status,omitempty"`
+}
+
+// Usage is how many bytes the user stores against the quota of the plan, Quota is 0 when unlimited
+type Usage struct {
+	Plan        string `json:"plan"`
+	Used        int64  `json:"used"`
+	Pending     int64  `json:"pending"`
+	Quota       int64  `json:"quota"`
+	MaxFileSize int64  `json:"max_file_size"`
+}
+
+type SetPlanDTO struct {
+	Plan string `json:"plan"`
+}
+
+type DeleteResult struct {
+	Deleted int `json:"deleted"`
+}
+
+// UploadFileDTO is a multipart/form-data body with the file, it is streamed to file_service, ContentLength is -1 when unknown
+type UploadFileDTO struct {
+	NoteUUID      string
+	UserUUID      string
+	ContentType   string
+	ContentLength int64
+	Body          io.Reader
+}
+
+// FileStream is a file being downloaded from file_service, the caller must close Body
+type FileStream struct {
+	StatusCode int
+	Header     http.Header
+	Body       io.ReadCloser
+}
+
+// ResumableUploadDTO is a request of a resumable upload, tus headers and the chunk in Body are passed to file_service
+type ResumableUploadDTO struct {
+	Method        string
+	UploadID      string
+	NoteUUID      string
+	UserUUID      string
+	Header        http.Header
+	ContentLength int64
+	Body          io.Reader
+}
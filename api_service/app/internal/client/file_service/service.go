@@ -0,0 +1,427 @@
+package file_service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+	"github.com/theartofdevel/notes_system/api_service/pkg/rest"
+	"io"
+	"net/http"
+	"time"
+)
+
+var _ FileService = &client{}
+
+// streamedRequestHeaders are passed to file_service on download, so it can answer conditional and range requests
+var streamedRequestHeaders = []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"}
+
+// uploadRequestHeaders carry the state of a resumable upload
+var uploadRequestHeaders = []string{"Content-Type", "Tus-Resumable", "Upload-Length", "Upload-Metadata", "Upload-Offset"}
+
+const (
+	uploadsResource = "/uploads"
+	usageResource   = "/usage"
+	planResource    = "/usage/plan"
+)
+
+type client struct {
+	base     rest.BaseClient
+	Resource string
+	// stream has no timeout, transfers of big files are bounded by the request context only
+	stream *http.Client
+}
+
+func NewService(baseURL string, resource string, logger logging.Logger) FileService {
+	return &client{
+		Resource: resource,
+		base: rest.BaseClient{
+			BaseURL: baseURL,
+			HTTPClient: &http.Client{
+				Timeout: 10 * time.Second,
+			},
+			Logger: logger,
+		},
+		stream: &http.Client{},
+	}
+}
+
+type FileService interface {
+	GetByNoteUUID(ctx context.Context, noteUUID string) ([]File, error)
+	Download(ctx context.Context, noteUUID, fileID string, header http.Header) (FileStream, error)
+	Thumbnail(ctx context.Context, noteUUID, fileID, size string, header http.Header) (FileStream, error)
+	Upload(ctx context.Context, dto UploadFileDTO) error
+	ResumableUpload(ctx context.Context, dto ResumableUploadDTO) (FileStream, error)
+	Delete(ctx context.Context, noteUUID, fileID string) error
+	DeleteByNoteUUID(ctx context.Context, noteUUID string) (int, error)
+	GetUsage(ctx context.Context, userUUID string) (Usage, error)
+	SetPlan(ctx context.Context, userUUID, plan string) error
+}
+
+func (c *client) GetByNoteUUID(ctx context.Context, noteUUID string) ([]File, error) {
+	var files []File
+
+	c.base.Logger.Debug("add note_uuid to filter options")
+	filters := []rest.FilterOptions{
+		{
+			Field:  "note_uuid",
+			Values: []string{noteUUID},
+		},
+	}
+
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.Resource, filters)
+	if err != nil {
+		return files, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return files, fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return files, fmt.Errorf("failed to send request due to error: %v", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&files); err != nil {
+			return files, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return files, nil
+	}
+	if response.StatusCode() == http.StatusNotFound {
+		return files, apperror.ErrNotFound
+	}
+	return files, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) Download(ctx context.Context, noteUUID, fileID string, header http.Header) (FileStream, error) {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(fmt.Sprintf("%s/%s", c.Resource, fileID), []rest.FilterOptions{
+		{
+			Field:  "note_uuid",
+			Values: []string{noteUUID},
+		},
+	})
+	if err != nil {
+		return FileStream{}, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	return c.get(ctx, uri, header)
+}
+
+func (c *client) Thumbnail(ctx context.Context, noteUUID, fileID, size string, header http.Header) (FileStream, error) {
+	c.base.Logger.Debug("build url with resource and filter")
+	filters := []rest.FilterOptions{
+		{
+			Field:  "note_uuid",
+			Values: []string{noteUUID},
+		},
+	}
+	if size != "" {
+		filters = append(filters, rest.FilterOptions{Field: "size", Values: []string{size}})
+	}
+	uri, err := c.base.BuildURL(fmt.Sprintf("%s/%s/thumbnail", c.Resource, fileID), filters)
+	if err != nil {
+		return FileStream{}, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	return c.get(ctx, uri, header)
+}
+
+// get streams the response of file_service, conditional and range headers of the client are passed with the request
+func (c *client) get(ctx context.Context, uri string, header http.Header) (FileStream, error) {
+	var stream FileStream
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return stream, fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+	for _, name := range streamedRequestHeaders {
+		if value := header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	c.base.Logger.Debug("send request")
+	response, err := c.stream.Do(req)
+	if err != nil {
+		return stream, fmt.Errorf("failed to send request due to error: %v", err)
+	}
+	// 416 carries Content-Range with the size of the file, it is passed to the client as is
+	if response.StatusCode >= http.StatusBadRequest && response.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		defer response.Body.Close()
+		return stream, responseError(response)
+	}
+
+	return FileStream{StatusCode: response.StatusCode, Header: response.Header, Body: response.Body}, nil
+}
+
+func (c *client) Upload(ctx context.Context, dto UploadFileDTO) error {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.Resource, []rest.FilterOptions{
+		{
+			Field:  "note_uuid",
+			Values: []string{dto.NoteUUID},
+		},
+		{
+			Field:  "user_uuid",
+			Values: []string{dto.UserUUID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, dto.Body)
+	if err != nil {
+		return fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+	req.Header.Set("Content-Type", dto.ContentType)
+	req.ContentLength = dto.ContentLength
+
+	c.base.Logger.Debug("send request")
+	response, err := c.stream.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request due to error: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return responseError(response)
+	}
+	return nil
+}
+
+// ResumableUpload passes a request of a resumable upload to file_service, the response is returned
+// with any status because tus clients resume by status and headers, the caller must close Body
+func (c *client) ResumableUpload(ctx context.Context, dto ResumableUploadDTO) (FileStream, error) {
+	var stream FileStream
+
+	c.base.Logger.Debug("build url with resource and filter")
+	resource := uploadsResource
+	if dto.UploadID != "" {
+		resource = fmt.Sprintf("%s/%s", uploadsResource, dto.UploadID)
+	}
+	filters := []rest.FilterOptions{
+		{
+			Field:  "user_uuid",
+			Values: []string{dto.UserUUID},
+		},
+	}
+	if dto.NoteUUID != "" {
+		filters = append(filters, rest.FilterOptions{Field: "note_uuid", Values: []string{dto.NoteUUID}})
+	}
+	uri, err := c.base.BuildURL(resource, filters)
+	if err != nil {
+		return stream, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequestWithContext(ctx, dto.Method, uri, dto.Body)
+	if err != nil {
+		return stream, fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+	for _, name := range uploadRequestHeaders {
+		if value := dto.Header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	req.ContentLength = dto.ContentLength
+
+	c.base.Logger.Debug("send request")
+	response, err := c.stream.Do(req)
+	if err != nil {
+		return stream, fmt.Errorf("failed to send request due to error: %v", err)
+	}
+
+	return FileStream{StatusCode: response.StatusCode, Header: response.Header, Body: response.Body}, nil
+}
+
+func (c *client) Delete(ctx context.Context, noteUUID, fileID string) error {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(fmt.Sprintf("%s/%s", c.Resource, fileID), []rest.FilterOptions{
+		{
+			Field:  "note_uuid",
+			Values: []string{noteUUID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodDelete, uri, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request due to error: %v", err)
+	}
+
+	if response.IsOk {
+		return nil
+	}
+	if response.StatusCode() == http.StatusNotFound {
+		return apperror.ErrNotFound
+	}
+	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) DeleteByNoteUUID(ctx context.Context, noteUUID string) (int, error) {
+	var result DeleteResult
+
+	c.base.Logger.Debug("add note_uuid to filter options")
+	filters := []rest.FilterOptions{
+		{
+			Field:  "note_uuid",
+			Values: []string{noteUUID},
+		},
+	}
+
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.Resource, filters)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodDelete, uri, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request due to error: %v", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&result); err != nil {
+			return 0, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return result.Deleted, nil
+	}
+	return 0, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) GetUsage(ctx context.Context, userUUID string) (Usage, error) {
+	var usage Usage
+
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(usageResource, []rest.FilterOptions{
+		{
+			Field:  "user_uuid",
+			Values: []string{userUUID},
+		},
+	})
+	if err != nil {
+		return usage, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return usage, fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return usage, fmt.Errorf("failed to send request due to error: %v", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&usage); err != nil {
+			return usage, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return usage, nil
+	}
+	return usage, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) SetPlan(ctx context.Context, userUUID, plan string) error {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(planResource, []rest.FilterOptions{
+		{
+			Field:  "user_uuid",
+			Values: []string{userUUID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("marshal dto to bytes")
+	dataBytes, err := json.Marshal(SetPlanDTO{Plan: plan})
+	if err != nil {
+		return fmt.Errorf("failed to marshal dto")
+	}
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodPut, uri, bytes.NewBuffer(dataBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request due to error: %v", err)
+	}
+
+	if response.IsOk {
+		return nil
+	}
+	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+// responseError reads the error of a streamed request, those bypass rest.BaseClient
+func responseError(response *http.Response) error {
+	if response.StatusCode == http.StatusNotFound {
+		return apperror.ErrNotFound
+	}
+	var apiErr rest.APIError
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<16)).Decode(&apiErr); err != nil {
+		return fmt.Errorf("file_service responded with status %d", response.StatusCode)
+	}
+	return apperror.APIError(apiErr.ErrorCode, apiErr.Message, apiErr.DeveloperMessage)
+}
@@ -1,5 +1,15 @@
 package note_service
 
+import "time"
+
+type Note struct {
+	UUID         string    `json:"uuid"`
+	Header       string    `json:"header"`
+	CategoryUUID string    `json:"category_uuid"`
+	Tags         []int     `json:"tags"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
 type CreateNoteDTO struct {
 	Header       string `json:"header"`
 	Body         string `json:"body"`
@@ -14,3 +24,13 @@ type UpdateNoteDTO struct {
 	Tags         []int  `json:"tags,omitempty"`
 	CategoryUUID string `json:"category_uuid,omitempty"`
 }
+
+type TagStats struct {
+	TagID      int       `json:"tag_id"`
+	NotesCount int       `json:"notes_count"`
+	LastUsed   time.Time `json:"last_used"`
+}
+
+type DeleteResult struct {
+	Deleted int64 `json:"deleted"`
+}
@@ -16,6 +16,8 @@ import (
 
 var _ NoteService = &client{}
 
+const tagsStatsResource = "/stats/tags"
+
 type client struct {
 	Resource string
 	base     rest.BaseClient
@@ -40,6 +42,8 @@ type NoteService interface {
 	Create(ctx context.Context, note CreateNoteDTO) (string, error)
 	Update(ctx context.Context, uuid string, note UpdateNoteDTO) error
 	Delete(ctx context.Context, uuid string) error
+	DeleteByCategoryUUID(ctx context.Context, categoryUUID string) (int, error)
+	GetTagsStats(ctx context.Context, tagIDs []int) ([]TagStats, error)
 }
 
 func (c *client) GetByCategoryUUID(ctx context.Context, categoryUUID string) ([]byte, error) {
@@ -83,6 +87,9 @@ func (c *client) GetByCategoryUUID(ctx context.Context, categoryUUID string) ([]
 		}
 		return notes, nil
 	}
+	if response.StatusCode() == http.StatusNotFound {
+		return nil, apperror.ErrNotFound
+	}
 	return nil, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
 }
 
@@ -119,6 +126,9 @@ func (c *client) GetByUUID(ctx context.Context, uuid string) ([]byte, error) {
 		}
 		return note, nil
 	}
+	if response.StatusCode() == http.StatusNotFound {
+		return nil, apperror.ErrNotFound
+	}
 	return nil, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
 }
 
@@ -239,3 +249,89 @@ func (c *client) Delete(ctx context.Context, uuid string) error {
 	}
 	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
 }
+
+func (c *client) GetTagsStats(ctx context.Context, tagIDs []int) ([]TagStats, error) {
+	var stats []TagStats
+
+	c.base.Logger.Debug("add tag_id to filter options")
+	filters := []rest.FilterOptions{
+		{
+			Field:  "tag_id",
+			Values: strings.Split(strings.Trim(fmt.Sprint(tagIDs), "[]"), " "),
+		},
+	}
+
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(tagsStatsResource, filters)
+	if err != nil {
+		return stats, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return stats, fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return stats, fmt.Errorf("failed to send request due to error: %v", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&stats); err != nil {
+			return stats, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return stats, nil
+	}
+	return stats, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) DeleteByCategoryUUID(ctx context.Context, categoryUUID string) (int, error) {
+	var result DeleteResult
+
+	c.base.Logger.Debug("add category_uuid to filter options")
+	filters := []rest.FilterOptions{
+		{
+			Field:  "category_uuid",
+			Values: []string{categoryUUID},
+		},
+	}
+
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.Resource, filters)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodDelete, uri, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request due to error: %v", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&result); err != nil {
+			return 0, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return int(result.Deleted), nil
+	}
+	return 0, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
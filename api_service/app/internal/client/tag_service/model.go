@@ -1,15 +1,26 @@
 package tag_service
 
+type Tag struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Color   string `json:"color"`
+	OwnerID string `json:"owner_id"`
+}
+
 type CreateTagDTO struct {
 	ID       int    `json:"_id,omitempty" bson:"_id"`
 	Name     string `json:"name" bson:"name"`
 	Color    string `json:"color" bson:"color"`
-	UserUUID string `json:"user_uuid" bson:"user_uuid"`
+	UserUUID string `json:"owner_id" bson:"owner_id"`
 }
 
 type UpdateTagDTO struct {
 	ID       int    `json:"_id,omitempty" bson:"_id,omitempty"`
 	Name     string `json:"name,omitempty" bson:"name,omitempty"`
 	Color    string `json:"color,omitempty" bson:"color,omitempty"`
-	UserUUID string `json:"user_uuid,omitempty" bson:"user_uuid,omitempty"`
+	UserUUID string `json:"owner_id,omitempty" bson:"owner_id,omitempty"`
+}
+
+type DeleteResult struct {
+	Deleted int64 `json:"deleted"`
 }
@@ -16,6 +16,8 @@ import (
 
 var _ TagService = &client{}
 
+const paletteResource = "/palette"
+
 type client struct {
 	base     rest.BaseClient
 	resource string
@@ -37,9 +39,12 @@ func NewService(baseURL string, resource string, logger logging.Logger) TagServi
 type TagService interface {
 	GetOne(ctx context.Context, id int) ([]byte, error)
 	GetMany(ctx context.Context, ids []int) ([]byte, error)
+	GetByOwner(ctx context.Context, ownerID string) ([]Tag, error)
+	GetPalette(ctx context.Context) ([]byte, error)
 	Create(ctx context.Context, tag CreateTagDTO) (string, error)
 	Update(ctx context.Context, uuid string, tag UpdateTagDTO) error
 	Delete(ctx context.Context, id string) error
+	DeleteByOwner(ctx context.Context, ownerID string) (int, error)
 }
 
 func (c *client) GetOne(ctx context.Context, id int) ([]byte, error) {
@@ -121,6 +126,84 @@ func (c *client) GetMany(ctx context.Context, ids []int) ([]byte, error) {
 	return nil, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
 }
 
+func (c *client) GetByOwner(ctx context.Context, ownerID string) ([]Tag, error) {
+	var tags []Tag
+
+	filters := []rest.FilterOptions{
+		{
+			Field:  "owner_id",
+			Values: []string{ownerID},
+		},
+	}
+
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.resource, filters)
+	if err != nil {
+		return tags, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return tags, fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return tags, fmt.Errorf("failed to send request due to error: %v", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&tags); err != nil {
+			return tags, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return tags, nil
+	}
+	return tags, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) GetPalette(ctx context.Context) ([]byte, error) {
+	var palette []byte
+
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.resource+paletteResource, nil)
+	if err != nil {
+		return palette, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return palette, fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return palette, fmt.Errorf("failed to send request due to error: %v", err)
+	}
+
+	if response.IsOk {
+		c.base.Logger.Debug("read body")
+		palette, err = response.ReadBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read body")
+		}
+		return palette, nil
+	}
+	return nil, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
 func (c *client) Create(ctx context.Context, tag CreateTagDTO) (string, error) {
 	var tagUUID string
 
@@ -238,3 +321,45 @@ func (c *client) Delete(ctx context.Context, id string) error {
 	}
 	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
 }
+
+func (c *client) DeleteByOwner(ctx context.Context, ownerID string) (int, error) {
+	var result DeleteResult
+
+	filters := []rest.FilterOptions{
+		{
+			Field:  "owner_id",
+			Values: []string{ownerID},
+		},
+	}
+
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.resource, filters)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodDelete, uri, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create new request due to error: %v", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request due to error: %v", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&result); err != nil {
+			return 0, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return int(result.Deleted), nil
+	}
+	return 0, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
@@ -1,9 +1,38 @@
 package user_service
 
+import "time"
+
 type User struct {
-	UUID     string `json:"uuid" bson:"_id"`
-	Email    string `json:"email" bson:"email"`
-	Password string `json:"-" bson:"password,omitempty"`
+	UUID          string       `json:"uuid" bson:"_id"`
+	Email         string       `json:"email" bson:"email"`
+	Password      string       `json:"-" bson:"password,omitempty"`
+	EmailVerified bool         `json:"email_verified" bson:"email_verified"`
+	Roles         []string     `json:"roles" bson:"roles"`
+	MFAEnabled    bool         `json:"mfa_enabled" bson:"mfa_enabled"`
+	DisplayName   string       `json:"display_name,omitempty"`
+	AvatarFileID  string       `json:"avatar_file_id,omitempty"`
+	Locale        string       `json:"locale,omitempty"`
+	Timezone      string       `json:"timezone,omitempty"`
+	Preferences   *Preferences `json:"preferences,omitempty"`
+}
+
+type Preferences struct {
+	DefaultCategoryUUID string `json:"default_category_uuid,omitempty"`
+	NoteSortOrder       string `json:"note_sort_order"`
+}
+
+// UpdateProfileDTO changes only fields which are present, empty string clears a field
+type UpdateProfileDTO struct {
+	DisplayName  *string `json:"display_name,omitempty"`
+	AvatarFileID *string `json:"avatar_file_id,omitempty"`
+	Locale       *string `json:"locale,omitempty"`
+	Timezone     *string `json:"timezone,omitempty"`
+}
+
+// UpdatePreferencesDTO changes only fields which are present, empty string resets a field to default
+type UpdatePreferencesDTO struct {
+	DefaultCategoryUUID *string `json:"default_category_uuid,omitempty"`
+	NoteSortOrder       *string `json:"note_sort_order,omitempty"`
 }
 
 type SigninUserDTO struct {
@@ -11,6 +40,21 @@ type SigninUserDTO struct {
 	Password string `json:"password" bson:"password,omitempty"`
 }
 
+// ExternalLoginDTO carries verified ID token claims of an external identity provider
+type ExternalLoginDTO struct {
+	Issuer        string `json:"issuer"`
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// IdentityLogin is the user of an external identity, TakenOver is set when an unverified account with
+// the same email was reset and linked to the identity
+type IdentityLogin struct {
+	User
+	TakenOver bool `json:"taken_over,omitempty"`
+}
+
 type CreateUserDTO struct {
 	Email          string `json:"email"`
 	Password       string `json:"password"`
@@ -23,3 +67,67 @@ type UpdateUserDTO struct {
 	OldPassword string `json:"old_password,omitempty"`
 	NewPassword string `json:"new_password,omitempty"`
 }
+
+type SetRolesDTO struct {
+	Roles []string `json:"roles"`
+}
+
+type MFACodeDTO struct {
+	UUID string `json:"uuid"`
+	Code string `json:"code"`
+}
+
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRCodePNG  []byte `json:"qr_code_png"`
+}
+
+type MFABackupCodes struct {
+	Codes []string `json:"backup_codes"`
+}
+
+type VerifyEmailDTO struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationDTO struct {
+	Email string `json:"email"`
+}
+
+type ForgotPasswordDTO struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordDTO struct {
+	Token          string `json:"token"`
+	Password       string `json:"password"`
+	RepeatPassword string `json:"repeat_password"`
+}
+
+// APIToken is a personal access token, its secret is returned only once on creation
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserUUID   string     `json:"user_uuid"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Roles      []string   `json:"roles,omitempty"`
+}
+
+type CreatedAPIToken struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+type CreateAPITokenDTO struct {
+	UserUUID string   `json:"user_uuid"`
+	Name     string   `json:"name"`
+	Scopes   []string `json:"scopes"`
+}
+
+type AuthenticateAPITokenDTO struct {
+	Token string `json:"token"`
+}
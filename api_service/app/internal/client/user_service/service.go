@@ -16,6 +16,22 @@ import (
 
 var _ UserService = &client{}
 
+const (
+	authenticateResource   = "/authenticate"
+	identityLoginResource  = "/oidc"
+	rolesResource          = "/roles"
+	mfaEnrollResource      = "/mfa/enroll"
+	mfaConfirmResource     = "/mfa/confirm"
+	mfaVerifyResource      = "/mfa/verify"
+	mfaDisableResource     = "/mfa/disable"
+	verifyEmailResource    = "/verify"
+	resendVerifyResource   = "/verify/resend"
+	forgotPasswordResource = "/password/forgot"
+	resetPasswordResource  = "/password/reset"
+	profileResource        = "/profile"
+	preferencesResource    = "/preferences"
+)
+
 type client struct {
 	base     rest.BaseClient
 	Resource string
@@ -36,35 +52,85 @@ func NewService(baseURL string, resource string, logger logging.Logger) UserServ
 }
 
 type UserService interface {
-	GetByEmailAndPassword(ctx context.Context, email, password string) (User, error)
+	Authenticate(ctx context.Context, email, password string) (User, error)
+	LoginWithIdentity(ctx context.Context, dto ExternalLoginDTO) (IdentityLogin, error)
 	GetByUUID(ctx context.Context, uuid string) (User, error)
+	GetAll(ctx context.Context) ([]User, error)
+	SetRoles(ctx context.Context, uuid string, roles []string) error
 	Create(ctx context.Context, dto CreateUserDTO) (User, error)
 	Update(ctx context.Context, uuid string, dto UpdateUserDTO) error
+	UpdateProfile(ctx context.Context, uuid string, dto UpdateProfileDTO) error
+	UpdatePreferences(ctx context.Context, uuid string, dto UpdatePreferencesDTO) error
 	Delete(ctx context.Context, uuid string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, dto ResetPasswordDTO) (User, error)
+	EnrollMFA(ctx context.Context, uuid string) (MFAEnrollment, error)
+	ConfirmMFA(ctx context.Context, uuid, code string) (MFABackupCodes, error)
+	VerifyMFA(ctx context.Context, uuid, code string) error
+	DisableMFA(ctx context.Context, uuid, code string) error
+	CreateAPIToken(ctx context.Context, dto CreateAPITokenDTO) (CreatedAPIToken, error)
+	GetAPITokens(ctx context.Context, userUUID string) ([]APIToken, error)
+	DeleteAPIToken(ctx context.Context, id, userUUID string) error
+	AuthenticateAPIToken(ctx context.Context, token string) (APIToken, error)
 }
 
-func (c *client) GetByEmailAndPassword(ctx context.Context, email, password string) (u User, err error) {
-	c.base.Logger.Debug("add email and password to filter options")
-	filters := []rest.FilterOptions{
-		{
-			Field:  "email",
-			Values: []string{email},
-		},
-		{
-			Field:  "password",
-			Values: []string{password},
-		},
+func (c *client) Authenticate(ctx context.Context, email, password string) (u User, err error) {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.Resource+authenticateResource, nil)
+	if err != nil {
+		return u, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("marshal dto to bytes")
+	dataBytes, err := json.Marshal(SigninUserDTO{Email: email, Password: password})
+	if err != nil {
+		return u, fmt.Errorf("failed to marshal dto")
+	}
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewBuffer(dataBytes))
+	if err != nil {
+		return u, fmt.Errorf("failed to create new request due to error: %w", err)
 	}
 
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return u, fmt.Errorf("failed to send request due to error: %w", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&u); err != nil {
+			return u, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return u, nil
+	}
+	return u, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) LoginWithIdentity(ctx context.Context, dto ExternalLoginDTO) (u IdentityLogin, err error) {
 	c.base.Logger.Debug("build url with resource and filter")
-	uri, err := c.base.BuildURL(c.Resource, filters)
+	uri, err := c.base.BuildURL(c.Resource+identityLoginResource, nil)
 	if err != nil {
 		return u, fmt.Errorf("failed to build URL. error: %v", err)
 	}
 	c.base.Logger.Tracef("url: %s", uri)
 
+	c.base.Logger.Debug("marshal dto to bytes")
+	dataBytes, err := json.Marshal(dto)
+	if err != nil {
+		return u, fmt.Errorf("failed to marshal dto")
+	}
+
 	c.base.Logger.Debug("create new request")
-	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewBuffer(dataBytes))
 	if err != nil {
 		return u, fmt.Errorf("failed to create new request due to error: %w", err)
 	}
@@ -79,6 +145,7 @@ func (c *client) GetByEmailAndPassword(ctx context.Context, email, password stri
 	}
 
 	if response.IsOk {
+		defer response.Body().Close()
 		if err = json.NewDecoder(response.Body()).Decode(&u); err != nil {
 			return u, fmt.Errorf("failed to decode body due to error %w", err)
 		}
@@ -119,9 +186,82 @@ func (c *client) GetByUUID(ctx context.Context, uuid string) (User, error) {
 		}
 		return u, nil
 	}
+	if response.StatusCode() == http.StatusNotFound {
+		return u, apperror.ErrNotFound
+	}
 	return u, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
 }
 
+func (c *client) GetAll(ctx context.Context) ([]User, error) {
+	var users []User
+
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.Resource, nil)
+	if err != nil {
+		return users, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return users, fmt.Errorf("failed to create new request due to error: %w", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return users, fmt.Errorf("failed to send request due to error: %w", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&users); err != nil {
+			return users, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return users, nil
+	}
+	return users, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) SetRoles(ctx context.Context, uuid string, roles []string) error {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(fmt.Sprintf("%s/%s%s", c.Resource, uuid, rolesResource), nil)
+	if err != nil {
+		return fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("marshal dto to bytes")
+	dataBytes, err := json.Marshal(SetRolesDTO{Roles: roles})
+	if err != nil {
+		return fmt.Errorf("failed to marshal dto")
+	}
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodPut, uri, bytes.NewBuffer(dataBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create new request due to error: %w", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request due to error: %w", err)
+	}
+
+	if response.IsOk {
+		return nil
+	}
+	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
 func (c *client) Create(ctx context.Context, dto CreateUserDTO) (User, error) {
 	var u User
 
@@ -215,6 +355,52 @@ func (c *client) Update(ctx context.Context, uuid string, dto UpdateUserDTO) err
 	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
 }
 
+func (c *client) UpdateProfile(ctx context.Context, uuid string, dto UpdateProfileDTO) error {
+	return c.patch(ctx, fmt.Sprintf("%s/%s%s", c.Resource, uuid, profileResource), dto)
+}
+
+func (c *client) UpdatePreferences(ctx context.Context, uuid string, dto UpdatePreferencesDTO) error {
+	return c.patch(ctx, fmt.Sprintf("%s/%s%s", c.Resource, uuid, preferencesResource), dto)
+}
+
+// patch sends dto as is, so pointer fields set to empty string reach user_service
+func (c *client) patch(ctx context.Context, resource string, dto interface{}) error {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(resource, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("marshal dto to bytes")
+	dataBytes, err := json.Marshal(dto)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dto")
+	}
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodPatch, uri, bytes.NewBuffer(dataBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create new request due to error: %w", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request due to error: %w", err)
+	}
+	if response.IsOk {
+		return nil
+	}
+	if response.StatusCode() == http.StatusNotFound {
+		return apperror.ErrNotFound
+	}
+	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
 func (c *client) Delete(ctx context.Context, uuid string) error {
 	c.base.Logger.Debug("build url with resource and filter")
 	uri, err := c.base.BuildURL(fmt.Sprintf("%s/%s", c.Resource, uuid), nil)
@@ -243,3 +429,208 @@ func (c *client) Delete(ctx context.Context, uuid string) error {
 	}
 	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
 }
+
+func (c *client) VerifyEmail(ctx context.Context, token string) error {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.Resource+verifyEmailResource, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("marshal dto to bytes")
+	dataBytes, err := json.Marshal(VerifyEmailDTO{Token: token})
+	if err != nil {
+		return fmt.Errorf("failed to marshal dto")
+	}
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewBuffer(dataBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create new request due to error: %w", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request due to error: %w", err)
+	}
+
+	if response.IsOk {
+		return nil
+	}
+	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) ResendVerification(ctx context.Context, email string) error {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.Resource+resendVerifyResource, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("marshal dto to bytes")
+	dataBytes, err := json.Marshal(ResendVerificationDTO{Email: email})
+	if err != nil {
+		return fmt.Errorf("failed to marshal dto")
+	}
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewBuffer(dataBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create new request due to error: %w", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request due to error: %w", err)
+	}
+
+	if response.IsOk {
+		return nil
+	}
+	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) ForgotPassword(ctx context.Context, email string) error {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.Resource+forgotPasswordResource, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("marshal dto to bytes")
+	dataBytes, err := json.Marshal(ForgotPasswordDTO{Email: email})
+	if err != nil {
+		return fmt.Errorf("failed to marshal dto")
+	}
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewBuffer(dataBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create new request due to error: %w", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request due to error: %w", err)
+	}
+
+	if response.IsOk {
+		return nil
+	}
+	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) ResetPassword(ctx context.Context, dto ResetPasswordDTO) (u User, err error) {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.Resource+resetPasswordResource, nil)
+	if err != nil {
+		return u, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("marshal dto to bytes")
+	dataBytes, err := json.Marshal(dto)
+	if err != nil {
+		return u, fmt.Errorf("failed to marshal dto")
+	}
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewBuffer(dataBytes))
+	if err != nil {
+		return u, fmt.Errorf("failed to create new request due to error: %w", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return u, fmt.Errorf("failed to send request due to error: %w", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&u); err != nil {
+			return u, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return u, nil
+	}
+	return u, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) EnrollMFA(ctx context.Context, uuid string) (e MFAEnrollment, err error) {
+	err = c.sendMFARequest(ctx, mfaEnrollResource, MFACodeDTO{UUID: uuid}, &e)
+	return e, err
+}
+
+func (c *client) ConfirmMFA(ctx context.Context, uuid, code string) (codes MFABackupCodes, err error) {
+	err = c.sendMFARequest(ctx, mfaConfirmResource, MFACodeDTO{UUID: uuid, Code: code}, &codes)
+	return codes, err
+}
+
+func (c *client) VerifyMFA(ctx context.Context, uuid, code string) error {
+	return c.sendMFARequest(ctx, mfaVerifyResource, MFACodeDTO{UUID: uuid, Code: code}, nil)
+}
+
+func (c *client) DisableMFA(ctx context.Context, uuid, code string) error {
+	return c.sendMFARequest(ctx, mfaDisableResource, MFACodeDTO{UUID: uuid, Code: code}, nil)
+}
+
+// sendMFARequest posts dto to the mfa resource and decodes response body into result if it is not nil
+func (c *client) sendMFARequest(ctx context.Context, resource string, dto MFACodeDTO, result interface{}) error {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(c.Resource+resource, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("marshal dto to bytes")
+	dataBytes, err := json.Marshal(dto)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dto")
+	}
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewBuffer(dataBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create new request due to error: %w", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request due to error: %w", err)
+	}
+
+	if response.IsOk {
+		if result == nil {
+			return nil
+		}
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(result); err != nil {
+			return fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return nil
+	}
+	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
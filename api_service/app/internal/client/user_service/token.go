@@ -0,0 +1,173 @@
+package user_service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/pkg/rest"
+	"net/http"
+	"time"
+)
+
+const (
+	tokensResource            = "/tokens"
+	authenticateTokenResource = "/tokens/authenticate"
+)
+
+func (c *client) CreateAPIToken(ctx context.Context, dto CreateAPITokenDTO) (t CreatedAPIToken, err error) {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(tokensResource, nil)
+	if err != nil {
+		return t, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("marshal dto to bytes")
+	dataBytes, err := json.Marshal(dto)
+	if err != nil {
+		return t, fmt.Errorf("failed to marshal dto")
+	}
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewBuffer(dataBytes))
+	if err != nil {
+		return t, fmt.Errorf("failed to create new request due to error: %w", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return t, fmt.Errorf("failed to send request due to error: %w", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&t); err != nil {
+			return t, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return t, nil
+	}
+	return t, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) GetAPITokens(ctx context.Context, userUUID string) ([]APIToken, error) {
+	var tokens []APIToken
+
+	filters := []rest.FilterOptions{
+		{
+			Field:  "user_uuid",
+			Values: []string{userUUID},
+		},
+	}
+
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(tokensResource, filters)
+	if err != nil {
+		return tokens, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return tokens, fmt.Errorf("failed to create new request due to error: %w", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return tokens, fmt.Errorf("failed to send request due to error: %w", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&tokens); err != nil {
+			return tokens, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return tokens, nil
+	}
+	return tokens, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) DeleteAPIToken(ctx context.Context, id, userUUID string) error {
+	filters := []rest.FilterOptions{
+		{
+			Field:  "user_uuid",
+			Values: []string{userUUID},
+		},
+	}
+
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(fmt.Sprintf("%s/%s", tokensResource, id), filters)
+	if err != nil {
+		return fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodDelete, uri, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create new request due to error: %w", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request due to error: %w", err)
+	}
+
+	if response.IsOk {
+		return nil
+	}
+	return apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
+
+func (c *client) AuthenticateAPIToken(ctx context.Context, token string) (t APIToken, err error) {
+	c.base.Logger.Debug("build url with resource and filter")
+	uri, err := c.base.BuildURL(authenticateTokenResource, nil)
+	if err != nil {
+		return t, fmt.Errorf("failed to build URL. error: %v", err)
+	}
+	c.base.Logger.Tracef("url: %s", uri)
+
+	c.base.Logger.Debug("marshal dto to bytes")
+	dataBytes, err := json.Marshal(AuthenticateAPITokenDTO{Token: token})
+	if err != nil {
+		return t, fmt.Errorf("failed to marshal dto")
+	}
+
+	c.base.Logger.Debug("create new request")
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewBuffer(dataBytes))
+	if err != nil {
+		return t, fmt.Errorf("failed to create new request due to error: %w", err)
+	}
+
+	c.base.Logger.Debug("send request")
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req = req.WithContext(reqCtx)
+	response, err := c.base.SendRequest(req)
+	if err != nil {
+		return t, fmt.Errorf("failed to send request due to error: %w", err)
+	}
+
+	if response.IsOk {
+		defer response.Body().Close()
+		if err = json.NewDecoder(response.Body()).Decode(&t); err != nil {
+			return t, fmt.Errorf("failed to decode body due to error %w", err)
+		}
+		return t, nil
+	}
+	return t, apperror.APIError(response.Error.ErrorCode, response.Error.Message, response.Error.DeveloperMessage)
+}
@@ -9,8 +9,42 @@ import (
 type Config struct {
 	IsDebug *bool `yaml:"is_debug"`
 	JWT     struct {
-		Secret string `yaml:"secret" env-required:"true"`
+		// Algorithm is RS256 or EdDSA
+		Algorithm string `yaml:"algorithm" env-default:"EdDSA"`
+		KeysDir   string `yaml:"keys_dir" env-default:"data/keys"`
+		// KeyRotation is signing key lifetime in seconds
+		KeyRotation int `yaml:"key_rotation" env-default:"604800"`
+		// RefreshTTL is refresh token lifetime in seconds
+		RefreshTTL int `yaml:"refresh_ttl" env-default:"2592000"`
 	}
+	RefreshTokenStore struct {
+		// Type is "memory" or "bolt", bolt keeps refresh tokens in a file so they survive restarts.
+		// bolt locks the file exclusively, so it supports a single instance of the service only.
+		Type string `yaml:"type" env-default:"memory"`
+		Path string `yaml:"path" env-default:"data/refresh_tokens.db"`
+	} `yaml:"refresh_token_store"`
+	Auth struct {
+		RequireVerifiedEmail bool `yaml:"require_verified_email" env-default:"false"`
+		// TrustForwardedFor takes client IP from X-Forwarded-For, enable it only behind a proxy
+		TrustForwardedFor bool `yaml:"trust_forwarded_for" env-default:"false"`
+		Lockout           struct {
+			AccountMaxAttempts int `yaml:"account_max_attempts" env-default:"5"`
+			IPMaxAttempts      int `yaml:"ip_max_attempts" env-default:"20"`
+			// BaseLockout, MaxLockout and Window are in seconds
+			BaseLockout int `yaml:"base_lockout" env-default:"30"`
+			MaxLockout  int `yaml:"max_lockout" env-default:"3600"`
+			Window      int `yaml:"window" env-default:"900"`
+		} `yaml:"lockout"`
+		// OIDC enables login through an external OpenID Connect provider
+		OIDC struct {
+			Enabled      bool     `yaml:"enabled" env-default:"false"`
+			Issuer       string   `yaml:"issuer"`
+			ClientID     string   `yaml:"client_id"`
+			ClientSecret string   `yaml:"client_secret"`
+			RedirectURL  string   `yaml:"redirect_url"`
+			Scopes       []string `yaml:"scopes" env-default:"openid,email"`
+		} `yaml:"oidc"`
+	} `yaml:"auth"`
 	Listen struct {
 		Type   string `yaml:"type" env-default:"port"`
 		BindIP string `yaml:"bind_ip" env-default:"localhost"`
@@ -28,6 +62,12 @@ type Config struct {
 	TagService struct {
 		URL string `yaml:"url" env-required:"true"`
 	} `yaml:"tag_service" env-required:"true"`
+	FileService struct {
+		URL string `yaml:"url" env-required:"true"`
+	} `yaml:"file_service" env-required:"true"`
+	TagsStats struct {
+		CacheTTL int `yaml:"cache_ttl" env-default:"0"`
+	} `yaml:"tags_stats"`
 }
 
 var instance *Config
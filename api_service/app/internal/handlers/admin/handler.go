@@ -0,0 +1,106 @@
+package admin
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/file_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/user_service"
+	"github.com/theartofdevel/notes_system/api_service/pkg/jwt"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+	"net/http"
+)
+
+const (
+	usersURL     = "/api/admin/users"
+	userRolesURL = "/api/admin/users/:uuid/roles"
+	userPlanURL  = "/api/admin/users/:uuid/plan"
+)
+
+type Handler struct {
+	Logger      logging.Logger
+	UserService user_service.UserService
+	FileService file_service.FileService
+	JWTHelper   jwt.Helper
+}
+
+func (h *Handler) Register(router *httprouter.Router) {
+	adminOnly := jwt.RequireRole(jwt.RoleAdmin)
+	router.HandlerFunc(http.MethodGet, usersURL, jwt.Middleware(adminOnly(apperror.Middleware(h.GetUsers))))
+	router.HandlerFunc(http.MethodPut, userRolesURL, jwt.Middleware(adminOnly(apperror.Middleware(h.SetUserRoles))))
+	router.HandlerFunc(http.MethodPut, userPlanURL, jwt.Middleware(adminOnly(apperror.Middleware(h.SetUserPlan))))
+}
+
+func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	users, err := h.UserService.GetAll(r.Context())
+	if err != nil {
+		return err
+	}
+
+	usersBytes, err := json.Marshal(users)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(usersBytes)
+
+	return nil
+}
+
+func (h *Handler) SetUserRoles(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	userUUID := params.ByName("uuid")
+
+	defer r.Body.Close()
+	var dto user_service.SetRolesDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	adminUUID := r.Context().Value("user_uuid").(string)
+	if userUUID == adminUUID && !jwt.HasRole(dto.Roles, jwt.RoleAdmin) {
+		return apperror.BadRequestError("you can not revoke admin role from yourself")
+	}
+
+	if err := h.UserService.SetRoles(r.Context(), userUUID, dto.Roles); err != nil {
+		return err
+	}
+	// roles are baked into tokens, make the user log in again to get new ones
+	revoked := h.JWTHelper.RevokeUserRefreshTokens(userUUID)
+	h.Logger.Infof("audit: admin %s set roles %v to user %s, %d sessions revoked", adminUUID, dto.Roles, userUUID, revoked)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// SetUserPlan moves the user to a storage plan of file_service, an empty plan is the default quota
+func (h *Handler) SetUserPlan(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	userUUID := params.ByName("uuid")
+
+	defer r.Body.Close()
+	var dto file_service.SetPlanDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	if _, err := h.UserService.GetByUUID(r.Context(), userUUID); err != nil {
+		return err
+	}
+	if err := h.FileService.SetPlan(r.Context(), userUUID, dto.Plan); err != nil {
+		return err
+	}
+	h.Logger.Infof("audit: admin %s set plan %q to user %s", r.Context().Value("user_uuid"), dto.Plan, userUUID)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
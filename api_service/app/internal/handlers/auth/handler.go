@@ -2,29 +2,83 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/julienschmidt/httprouter"
 	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
 	"github.com/theartofdevel/notes_system/api_service/internal/client/user_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/lockout"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache"
 	"github.com/theartofdevel/notes_system/api_service/pkg/jwt"
 	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+	"github.com/theartofdevel/notes_system/api_service/pkg/oidc"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	authURL   = "/api/auth"
-	signupURL = "/api/signup"
+	authURL         = "/api/auth"
+	signupURL       = "/api/signup"
+	verifyURL       = "/api/verify"
+	resendVerifyURL = "/api/verify/resend"
+
+	logoutURL    = "/api/auth/logout"
+	logoutAllURL = "/api/auth/logout-all"
+	sessionsURL  = "/api/auth/sessions"
+
+	jwksURL = "/.well-known/jwks.json"
+
+	mfaURL        = "/api/auth/mfa"
+	mfaEnrollURL  = "/api/auth/mfa/enroll"
+	mfaConfirmURL = "/api/auth/mfa/confirm"
+	mfaDisableURL = "/api/auth/mfa/disable"
+
+	oidcURL         = "/api/auth/oidc"
+	oidcLoginURL    = "/api/auth/oidc/login"
+	oidcCallbackURL = "/api/auth/oidc/callback"
+
+	forgotPasswordURL = "/api/password/forgot"
+	resetPasswordURL  = "/api/password/reset"
 )
 
 type Handler struct {
 	Logger      logging.Logger
 	UserService user_service.UserService
 	JWTHelper   jwt.Helper
+	KeySet      jwt.KeySet
+	// RequireVerifiedEmail blocks issuing tokens until user confirms email
+	RequireVerifiedEmail bool
+	AccountLimiter       lockout.Limiter
+	IPLimiter            lockout.Limiter
+	TrustForwardedFor    bool
+	// OIDC is nil when login through an external identity provider is disabled
+	OIDC oidc.Provider
+	// OIDCStates keeps PKCE verifiers and nonces of logins in progress
+	OIDCStates cache.Repository
 }
 
 func (h *Handler) Register(router *httprouter.Router) {
 	router.HandlerFunc(http.MethodPost, authURL, apperror.Middleware(h.Auth))
 	router.HandlerFunc(http.MethodPut, authURL, apperror.Middleware(h.Auth))
 	router.HandlerFunc(http.MethodPost, signupURL, apperror.Middleware(h.Signup))
+	router.HandlerFunc(http.MethodPost, logoutURL, jwt.Middleware(apperror.Middleware(h.Logout)))
+	router.HandlerFunc(http.MethodPost, logoutAllURL, jwt.Middleware(apperror.Middleware(h.LogoutAll)))
+	router.HandlerFunc(http.MethodGet, sessionsURL, jwt.Middleware(apperror.Middleware(h.GetSessions)))
+	router.HandlerFunc(http.MethodPost, mfaURL, apperror.Middleware(h.AuthMFA))
+	router.HandlerFunc(http.MethodPost, mfaEnrollURL, jwt.Middleware(apperror.Middleware(h.EnrollMFA)))
+	router.HandlerFunc(http.MethodPost, mfaConfirmURL, jwt.Middleware(apperror.Middleware(h.ConfirmMFA)))
+	router.HandlerFunc(http.MethodPost, mfaDisableURL, jwt.Middleware(apperror.Middleware(h.DisableMFA)))
+	router.HandlerFunc(http.MethodGet, jwksURL, apperror.Middleware(h.GetJWKS))
+	router.HandlerFunc(http.MethodGet, verifyURL, apperror.Middleware(h.VerifyEmail))
+	router.HandlerFunc(http.MethodPost, resendVerifyURL, apperror.Middleware(h.ResendVerification))
+	router.HandlerFunc(http.MethodPost, forgotPasswordURL, apperror.Middleware(h.ForgotPassword))
+	router.HandlerFunc(http.MethodPost, resetPasswordURL, apperror.Middleware(h.ResetPassword))
+	if h.OIDC != nil {
+		router.HandlerFunc(http.MethodGet, oidcLoginURL, apperror.Middleware(h.OIDCLogin))
+		router.HandlerFunc(http.MethodGet, oidcCallbackURL, apperror.Middleware(h.OIDCCallback))
+	}
 }
 
 func (h *Handler) Signup(w http.ResponseWriter, r *http.Request) error {
@@ -40,7 +94,16 @@ func (h *Handler) Signup(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
-	token, err := h.JWTHelper.GenerateAccessToken(u)
+	if h.RequireVerifiedEmail && !u.EmailVerified {
+		userBytes, err := json.Marshal(u)
+		if err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write(userBytes)
+		return nil
+	}
+	token, err := h.JWTHelper.GenerateAccessToken(u, h.sessionMeta(r))
 	if err != nil {
 		return err
 	}
@@ -63,11 +126,36 @@ func (h *Handler) Auth(w http.ResponseWriter, r *http.Request) error {
 		if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
 			return apperror.BadRequestError("failed to decode data")
 		}
-		u, err := h.UserService.GetByEmailAndPassword(r.Context(), dto.Email, dto.Password)
+		email := strings.ToLower(strings.TrimSpace(dto.Email))
+		ip := h.clientIP(r)
+		if err := h.checkLockout(w, email, ip); err != nil {
+			return err
+		}
+		u, err := h.UserService.Authenticate(r.Context(), dto.Email, dto.Password)
 		if err != nil {
+			var appErr *apperror.AppError
+			if errors.As(err, &appErr) {
+				h.registerFailure(email, ip)
+			}
 			return err
 		}
-		token, err = h.JWTHelper.GenerateAccessToken(u)
+		if h.RequireVerifiedEmail && !u.EmailVerified {
+			return apperror.ErrEmailNotVerified
+		}
+		if u.MFAEnabled {
+			// failures are not reset until the second factor is passed
+			h.Logger.Infof("audit: password of user %s accepted from %s, 2FA required", u.UUID, ip)
+			token, err = h.JWTHelper.GenerateMFAToken(u)
+			if err != nil {
+				return err
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write(token)
+			return nil
+		}
+		h.AccountLimiter.Reset(email)
+		h.Logger.Infof("audit: successful login of user %s from %s", u.UUID, ip)
+		token, err = h.JWTHelper.GenerateAccessToken(u, h.sessionMeta(r))
 		if err != nil {
 			return err
 		}
@@ -77,7 +165,7 @@ func (h *Handler) Auth(w http.ResponseWriter, r *http.Request) error {
 		if err := json.NewDecoder(r.Body).Decode(&rt); err != nil {
 			return apperror.BadRequestError("failed to decode data")
 		}
-		token, err = h.JWTHelper.UpdateRefreshToken(rt)
+		token, err = h.JWTHelper.UpdateRefreshToken(r.Context(), rt, h.sessionMeta(r))
 		if err != nil {
 			return err
 		}
@@ -88,3 +176,328 @@ func (h *Handler) Auth(w http.ResponseWriter, r *http.Request) error {
 
 	return err
 }
+
+// AuthMFA completes login of a user with 2FA enabled
+func (h *Handler) AuthMFA(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	defer r.Body.Close()
+	var dto jwt.MFAChallenge
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	userUUID, err := h.JWTHelper.ParseMFAToken(dto.MFAToken)
+	if err != nil {
+		return err
+	}
+	u, err := h.UserService.GetByUUID(r.Context(), userUUID)
+	if err != nil {
+		return err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(u.Email))
+	ip := h.clientIP(r)
+	if err = h.checkLockout(w, email, ip); err != nil {
+		return err
+	}
+	if err = h.UserService.VerifyMFA(r.Context(), u.UUID, dto.Code); err != nil {
+		var appErr *apperror.AppError
+		if errors.As(err, &appErr) {
+			h.registerFailure(email, ip)
+		}
+		return err
+	}
+	h.AccountLimiter.Reset(email)
+	h.Logger.Infof("audit: successful login of user %s from %s with 2FA", u.UUID, ip)
+
+	token, err := h.JWTHelper.GenerateAccessToken(u, h.sessionMeta(r))
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(token)
+
+	return nil
+}
+
+func (h *Handler) EnrollMFA(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Context().Value("user_uuid") == nil {
+		h.Logger.Error("there is no user_uuid in context")
+		return apperror.UnauthorizedError("")
+	}
+	userUUID := r.Context().Value("user_uuid").(string)
+
+	enrollment, err := h.UserService.EnrollMFA(r.Context(), userUUID)
+	if err != nil {
+		return err
+	}
+	enrollmentBytes, err := json.Marshal(enrollment)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(enrollmentBytes)
+
+	return nil
+}
+
+func (h *Handler) ConfirmMFA(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Context().Value("user_uuid") == nil {
+		h.Logger.Error("there is no user_uuid in context")
+		return apperror.UnauthorizedError("")
+	}
+	userUUID := r.Context().Value("user_uuid").(string)
+
+	defer r.Body.Close()
+	var dto user_service.MFACodeDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	codes, err := h.UserService.ConfirmMFA(r.Context(), userUUID, dto.Code)
+	if err != nil {
+		return err
+	}
+	h.Logger.Infof("audit: user %s enabled 2FA", userUUID)
+	codesBytes, err := json.Marshal(codes)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(codesBytes)
+
+	return nil
+}
+
+func (h *Handler) DisableMFA(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Context().Value("user_uuid") == nil {
+		h.Logger.Error("there is no user_uuid in context")
+		return apperror.UnauthorizedError("")
+	}
+	userUUID := r.Context().Value("user_uuid").(string)
+
+	defer r.Body.Close()
+	var dto user_service.MFACodeDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	if err := h.UserService.DisableMFA(r.Context(), userUUID, dto.Code); err != nil {
+		return err
+	}
+	h.Logger.Infof("audit: user %s disabled 2FA", userUUID)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Context().Value("user_uuid") == nil {
+		h.Logger.Error("there is no user_uuid in context")
+		return apperror.UnauthorizedError("")
+	}
+	userUUID := r.Context().Value("user_uuid").(string)
+	sessionID, _ := r.Context().Value("session_id").(string)
+	if sessionID == "" {
+		return apperror.BadRequestError("token is not bound to a session")
+	}
+
+	if err := h.JWTHelper.RevokeSession(userUUID, sessionID); err != nil {
+		return err
+	}
+	h.Logger.Infof("audit: user %s logged out of session %s", userUUID, sessionID)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Context().Value("user_uuid") == nil {
+		h.Logger.Error("there is no user_uuid in context")
+		return apperror.UnauthorizedError("")
+	}
+	userUUID := r.Context().Value("user_uuid").(string)
+
+	revoked := h.JWTHelper.RevokeUserRefreshTokens(userUUID)
+	h.Logger.Infof("audit: user %s logged out of all sessions, %d revoked", userUUID, revoked)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) GetSessions(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Context().Value("user_uuid") == nil {
+		h.Logger.Error("there is no user_uuid in context")
+		return apperror.UnauthorizedError("")
+	}
+	userUUID := r.Context().Value("user_uuid").(string)
+	sessionID, _ := r.Context().Value("session_id").(string)
+
+	sessionsBytes, err := json.Marshal(h.JWTHelper.GetUserSessions(userUUID, sessionID))
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(sessionsBytes)
+
+	return nil
+}
+
+// GetJWKS publishes public keys so other services can verify access tokens themselves
+func (h *Handler) GetJWKS(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	jwksBytes, err := json.Marshal(h.KeySet.JWKS())
+	if err != nil {
+		return err
+	}
+
+	// verifiers are expected to refetch the set when they meet an unknown kid
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jwksBytes)
+
+	return nil
+}
+
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return apperror.BadRequestError("token query parameter is required")
+	}
+
+	if err := h.UserService.VerifyEmail(r.Context(), token); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// ResendVerification answers the same whether the email is registered or not
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	defer r.Body.Close()
+	var dto user_service.ResendVerificationDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	if err := h.UserService.ResendVerification(r.Context(), dto.Email); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	return nil
+}
+
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	defer r.Body.Close()
+	var dto user_service.ForgotPasswordDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	if err := h.UserService.ForgotPassword(r.Context(), dto.Email); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	return nil
+}
+
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	defer r.Body.Close()
+	var dto user_service.ResetPasswordDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	u, err := h.UserService.ResetPassword(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+
+	revoked := h.JWTHelper.RevokeUserRefreshTokens(u.UUID)
+	h.Logger.Infof("password of user %s was reset, %d refresh tokens revoked", u.UUID, revoked)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) checkLockout(w http.ResponseWriter, email, ip string) error {
+	lockedFor := h.AccountLimiter.Locked(email)
+	if ipLockedFor := h.IPLimiter.Locked(ip); ipLockedFor > lockedFor {
+		lockedFor = ipLockedFor
+	}
+	if lockedFor > 0 {
+		h.Logger.Warnf("audit: rejected login attempt for %s from %s, locked for %s", email, ip, lockedFor.Round(time.Second))
+		w.Header().Set("Retry-After", strconv.Itoa(int(lockedFor.Seconds())+1))
+		return apperror.ErrAccountLocked
+	}
+	return nil
+}
+
+func (h *Handler) registerFailure(email, ip string) {
+	failures, lockedFor := h.AccountLimiter.Fail(email)
+	h.Logger.Warnf("audit: failed login for %s from %s, failure %d", email, ip, failures)
+	if lockedFor > 0 {
+		h.Logger.Warnf("audit: account %s locked for %s", email, lockedFor)
+	}
+
+	failures, lockedFor = h.IPLimiter.Fail(ip)
+	if lockedFor > 0 {
+		h.Logger.Warnf("audit: ip %s locked for %s after %d failures", ip, lockedFor, failures)
+	}
+}
+
+func (h *Handler) sessionMeta(r *http.Request) jwt.SessionMeta {
+	return jwt.SessionMeta{
+		UserAgent: r.UserAgent(),
+		IP:        h.clientIP(r),
+	}
+}
+
+func (h *Handler) clientIP(r *http.Request) string {
+	if h.TrustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
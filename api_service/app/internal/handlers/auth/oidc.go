@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"encoding/json"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/user_service"
+	"github.com/theartofdevel/notes_system/api_service/pkg/oidc"
+	"net/http"
+	"time"
+)
+
+const (
+	oidcStateCookie    = "oidc_state"
+	oidcStateKeyPrefix = "oidc:"
+	// oidcStateTTL is how long the user has to sign in at the provider
+	oidcStateTTL = 10 * time.Minute
+)
+
+// oidcLogin is kept between login and callback, the code verifier never leaves this service
+type oidcLogin struct {
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// OIDCLogin starts authorization code flow with PKCE by redirecting the user to the identity provider
+func (h *Handler) OIDCLogin(w http.ResponseWriter, r *http.Request) error {
+	state, err := oidc.RandomString()
+	if err != nil {
+		return err
+	}
+	login := oidcLogin{}
+	if login.Nonce, err = oidc.RandomString(); err != nil {
+		return err
+	}
+	if login.CodeVerifier, err = oidc.RandomString(); err != nil {
+		return err
+	}
+
+	authURL, err := h.OIDC.AuthCodeURL(r.Context(), state, login.Nonce, login.CodeVerifier)
+	if err != nil {
+		return err
+	}
+	loginBytes, err := json.Marshal(login)
+	if err != nil {
+		return err
+	}
+	if err = h.OIDCStates.Set([]byte(oidcStateKeyPrefix+state), loginBytes, int(oidcStateTTL.Seconds())); err != nil {
+		return err
+	}
+
+	// state is bound to the browser, so a callback started by someone else is rejected
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     oidcURL,
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   h.isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+
+	return nil
+}
+
+// OIDCCallback exchanges the authorization code, verifies the ID token and issues tokens of the linked user
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	q := r.URL.Query()
+	if providerErr := q.Get("error"); providerErr != "" {
+		return apperror.UnauthorizedError("identity provider declined login: " + providerErr)
+	}
+
+	state := q.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		return apperror.BadRequestError("invalid login state")
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: oidcURL, MaxAge: -1, HttpOnly: true})
+
+	stateKey := []byte(oidcStateKeyPrefix + state)
+	loginBytes, err := h.OIDCStates.Get(stateKey)
+	if err != nil {
+		return apperror.BadRequestError("login has expired, start it again")
+	}
+	// state is single use
+	h.OIDCStates.Del(stateKey)
+	var login oidcLogin
+	if err = json.Unmarshal(loginBytes, &login); err != nil {
+		return err
+	}
+
+	rawIDToken, err := h.OIDC.Exchange(r.Context(), q.Get("code"), login.CodeVerifier)
+	if err != nil {
+		h.Logger.Errorf("failed to exchange authorization code: %v", err)
+		return apperror.UnauthorizedError("failed to exchange authorization code")
+	}
+	claims, err := h.OIDC.VerifyIDToken(r.Context(), rawIDToken, login.Nonce)
+	if err != nil {
+		h.Logger.Errorf("failed to verify id token: %v", err)
+		return apperror.UnauthorizedError("invalid id token")
+	}
+
+	identityLogin, err := h.UserService.LoginWithIdentity(r.Context(), user_service.ExternalLoginDTO{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	})
+	if err != nil {
+		return err
+	}
+	u := identityLogin.User
+
+	ip := h.clientIP(r)
+	if identityLogin.TakenOver {
+		// sessions were started with the password of someone who may not own the email
+		revoked := h.JWTHelper.RevokeUserRefreshTokens(u.UUID)
+		h.Logger.Infof("audit: user %s taken over via %s from %s, %d sessions revoked", u.UUID, claims.Issuer, ip, revoked)
+	}
+	if u.MFAEnabled {
+		h.Logger.Infof("audit: user %s signed in at %s from %s, 2FA required", u.UUID, claims.Issuer, ip)
+		token, err := h.JWTHelper.GenerateMFAToken(u)
+		if err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(token)
+		return nil
+	}
+	h.Logger.Infof("audit: successful login of user %s from %s via %s", u.UUID, ip, claims.Issuer)
+	token, err := h.JWTHelper.GenerateAccessToken(u, h.sessionMeta(r))
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(token)
+
+	return nil
+}
+
+func (h *Handler) isHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return h.TrustForwardedFor && r.Header.Get("X-Forwarded-Proto") == "https"
+}
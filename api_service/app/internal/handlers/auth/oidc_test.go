@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/user_service"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache/freecache"
+	"github.com/theartofdevel/notes_system/api_service/pkg/jwt"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+	"github.com/theartofdevel/notes_system/api_service/pkg/oidc"
+	"github.com/theartofdevel/notes_system/api_service/pkg/oidc/mockoidc"
+)
+
+// identityUserService links every identity to the same user, the user is taken over when takenOver is set
+type identityUserService struct {
+	user_service.UserService
+	user      user_service.User
+	takenOver bool
+	logins    []user_service.ExternalLoginDTO
+}
+
+func (s *identityUserService) LoginWithIdentity(_ context.Context, dto user_service.ExternalLoginDTO) (user_service.IdentityLogin, error) {
+	s.logins = append(s.logins, dto)
+	return user_service.IdentityLogin{User: s.user, TakenOver: s.takenOver}, nil
+}
+
+func (s *identityUserService) GetByUUID(_ context.Context, _ string) (user_service.User, error) {
+	return s.user, nil
+}
+
+func newOIDCTestServer(t *testing.T) (*httptest.Server, *mockoidc.Server, *identityUserService, jwt.Helper) {
+	logger := logging.Logger{Entry: logrus.NewEntry(logrus.New())}
+
+	mock, err := mockoidc.New("notes", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := httptest.NewServer(mock)
+	t.Cleanup(idp.Close)
+	mock.Issuer = idp.URL
+
+	keySet, err := jwt.NewKeySet(jwt.KeySetConfig{
+		Algorithm: "EdDSA",
+		Dir:       t.TempDir(),
+		Rotation:  time.Hour,
+		Retention: time.Hour,
+	}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := &identityUserService{user: user_service.User{UUID: "6083e6f2c238914ea1862f70", Email: "mock@example.com"}}
+	router := httprouter.New()
+	api := httptest.NewServer(router)
+	t.Cleanup(api.Close)
+
+	h := Handler{
+		Logger:      logger,
+		UserService: users,
+		JWTHelper:   jwt.NewHelper(keySet, freecache.NewCacheRepo(1048576), users, time.Hour, logger),
+		KeySet:      keySet,
+		OIDC: oidc.NewProvider(oidc.Config{
+			Issuer:       idp.URL,
+			ClientID:     "notes",
+			ClientSecret: "secret",
+			RedirectURL:  api.URL + oidcCallbackURL,
+		}, logger),
+		OIDCStates: freecache.NewCacheRepo(1048576),
+	}
+	h.Register(router)
+
+	return api, mock, users, h.JWTHelper
+}
+
+func noRedirectClient() *http.Client {
+	return &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+}
+
+// login walks the flow like a browser: login redirect, provider redirect, callback with the state cookie
+func login(t *testing.T, api *httptest.Server) (*http.Response, *url.URL, *http.Cookie) {
+	client := noRedirectClient()
+	resp, err := client.Get(api.URL + oidcLoginURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !assert.Equal(t, http.StatusFound, resp.StatusCode) {
+		t.FailNow()
+	}
+	cookies := resp.Cookies()
+	if !assert.Len(t, cookies, 1) {
+		t.FailNow()
+	}
+
+	authURL, _ := resp.Location()
+	assert.Equal(t, "S256", authURL.Query().Get("code_challenge_method"))
+	resp, err = client.Get(authURL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callbackURL, _ := resp.Location()
+
+	req, _ := http.NewRequest(http.MethodGet, callbackURL.String(), nil)
+	req.AddCookie(cookies[0])
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, callbackURL, cookies[0]
+}
+
+func TestOIDCLogin(t *testing.T) {
+	api, mock, users, _ := newOIDCTestServer(t)
+
+	// Test scenario:
+	// 1. user signs in at the provider and gets tokens of the linked user
+	// 2. user_service receives verified claims of the ID token
+	resp, callbackURL, cookie := login(t, api)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var token map[string]string
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&token))
+	assert.NotEmpty(t, token["token"])
+	assert.NotEmpty(t, token["refresh_token"])
+
+	if assert.Len(t, users.logins, 1) {
+		assert.Equal(t, user_service.ExternalLoginDTO{
+			Issuer:        mock.Issuer,
+			Subject:       mock.User.Subject,
+			Email:         mock.User.Email,
+			EmailVerified: true,
+		}, users.logins[0])
+	}
+
+	// Test scenario:
+	// 1. the callback can not be replayed
+	req, _ := http.NewRequest(http.MethodGet, callbackURL.String(), nil)
+	req.AddCookie(cookie)
+	replay, err := noRedirectClient().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, replay.StatusCode)
+	assert.Len(t, users.logins, 1)
+}
+
+func TestOIDCCallbackRequiresStateCookie(t *testing.T) {
+	api, _, users, _ := newOIDCTestServer(t)
+	client := noRedirectClient()
+
+	// Test scenario:
+	// 1. callback of a login started in another browser is rejected
+	resp, err := client.Get(api.URL + oidcLoginURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	authURL, _ := resp.Location()
+	resp, err = client.Get(authURL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callbackURL, _ := resp.Location()
+
+	resp, err = client.Get(callbackURL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Empty(t, users.logins)
+}
+
+func TestOIDCLoginTakeOverRevokesSessions(t *testing.T) {
+	api, _, users, helper := newOIDCTestServer(t)
+
+	// Test scenario:
+	// 1. sessions started with the password of an unverified account are revoked when
+	// the owner of the email takes the account over
+	// 2. the owner gets a new session
+	_, err := helper.GenerateAccessToken(users.user, jwt.SessionMeta{UserAgent: "signup"})
+	assert.NoError(t, err)
+	users.takenOver = true
+
+	resp, _, _ := login(t, api)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	sessions := helper.GetUserSessions(users.user.UUID, "")
+	if assert.Len(t, sessions, 1) {
+		assert.NotEqual(t, "signup", sessions[0].UserAgent)
+	}
+}
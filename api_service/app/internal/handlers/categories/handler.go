@@ -22,10 +22,11 @@ type Handler struct {
 }
 
 func (h *Handler) Register(router *httprouter.Router) {
+	canWrite := jwt.RequireRole(jwt.RoleUser, jwt.RoleAdmin)
 	router.HandlerFunc(http.MethodGet, categoriesURL, jwt.Middleware(apperror.Middleware(h.GetCategories)))
-	router.HandlerFunc(http.MethodPost, categoriesURL, jwt.Middleware(apperror.Middleware(h.CreateCategory)))
-	router.HandlerFunc(http.MethodPatch, categoryURL, jwt.Middleware(apperror.Middleware(h.PartiallyUpdateCategory)))
-	router.HandlerFunc(http.MethodDelete, categoryURL, jwt.Middleware(apperror.Middleware(h.DeleteCategory)))
+	router.HandlerFunc(http.MethodPost, categoriesURL, jwt.Middleware(canWrite(apperror.Middleware(h.CreateCategory))))
+	router.HandlerFunc(http.MethodPatch, categoryURL, jwt.Middleware(canWrite(apperror.Middleware(h.PartiallyUpdateCategory))))
+	router.HandlerFunc(http.MethodDelete, categoryURL, jwt.Middleware(canWrite(apperror.Middleware(h.DeleteCategory))))
 }
 
 func (h *Handler) GetCategories(w http.ResponseWriter, r *http.Request) error {
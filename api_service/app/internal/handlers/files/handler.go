@@ -0,0 +1,385 @@
+package files
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/category_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/file_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/note_service"
+	"github.com/theartofdevel/notes_system/api_service/pkg/jwt"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+)
+
+const (
+	filesURL     = "/api/files"
+	fileURL      = "/api/files/:id"
+	thumbnailURL = "/api/files/:id/thumbnail"
+	uploadsURL   = "/api/uploads"
+	uploadURL    = "/api/uploads/:id"
+
+	// usageID is the id of GET /api/files/usage, httprouter can't register the static path next to fileURL
+	usageID = "usage"
+
+	// maxFieldSize limits the note_uuid field of an upload
+	maxFieldSize = 1024
+)
+
+// streamedResponseHeaders are passed from file_service to the client on download
+var streamedResponseHeaders = []string{
+	"Content-Type", "Content-Length", "Content-Disposition", "Content-Range",
+	"Accept-Ranges", "ETag", "Last-Modified", "Cache-Control",
+}
+
+// uploadResponseHeaders tell a tus client where the upload is and how to resume it
+var uploadResponseHeaders = []string{
+	"Content-Type", "Content-Length", "Cache-Control", "Location", "Tus-Resumable",
+	"Upload-Offset", "Upload-Length", "Upload-Chunk-Size", "Upload-Expires",
+}
+
+type Handler struct {
+	Logger          logging.Logger
+	FileService     file_service.FileService
+	NoteService     note_service.NoteService
+	CategoryService category_service.CategoryService
+}
+
+func (h *Handler) Register(router *httprouter.Router) {
+	canWrite := jwt.RequireRole(jwt.RoleUser, jwt.RoleAdmin)
+	router.HandlerFunc(http.MethodGet, filesURL, jwt.Middleware(apperror.Middleware(h.GetFiles)))
+	router.HandlerFunc(http.MethodPost, filesURL, jwt.Middleware(canWrite(apperror.Middleware(h.UploadFile))))
+	router.HandlerFunc(http.MethodGet, fileURL, jwt.Middleware(apperror.Middleware(h.getFileOrUsage)))
+	router.HandlerFunc(http.MethodGet, thumbnailURL, jwt.Middleware(apperror.Middleware(h.GetThumbnail)))
+	router.HandlerFunc(http.MethodDelete, fileURL, jwt.Middleware(canWrite(apperror.Middleware(h.DeleteFile))))
+
+	router.HandlerFunc(http.MethodPost, uploadsURL, jwt.Middleware(canWrite(apperror.Middleware(h.CreateUpload))))
+	router.HandlerFunc(http.MethodHead, uploadURL, jwt.Middleware(apperror.Middleware(h.ResumeUpload)))
+	router.HandlerFunc(http.MethodPatch, uploadURL, jwt.Middleware(canWrite(apperror.Middleware(h.ResumeUpload))))
+	router.HandlerFunc(http.MethodDelete, uploadURL, jwt.Middleware(canWrite(apperror.Middleware(h.ResumeUpload))))
+}
+
+func (h *Handler) GetFiles(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	noteUUID, err := h.ownedNote(r)
+	if err != nil {
+		return err
+	}
+	files, err := h.FileService.GetByNoteUUID(r.Context(), noteUUID)
+	if err != nil {
+		return err
+	}
+
+	filesBytes, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(filesBytes)
+
+	return nil
+}
+
+// UploadFile streams multipart/form-data body to file_service part by part without buffering the file, note_uuid
+// is taken from the query because the ownership is checked before the body is sent. note_uuid fields are dropped
+// from the body and a field naming another note fails the upload, so the body can't point file_service elsewhere.
+func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	noteUUID, err := h.ownedNote(r)
+	if err != nil {
+		return err
+	}
+	contentType := r.Header.Get("Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return apperror.BadRequestError("multipart/form-data body is required")
+	}
+
+	defer r.Body.Close()
+	body, filtered := filterNoteUUID(r.Body, params["boundary"], noteUUID)
+	defer body.Close()
+	err = h.FileService.Upload(r.Context(), file_service.UploadFileDTO{
+		NoteUUID:    noteUUID,
+		UserUUID:    r.Context().Value("user_uuid").(string),
+		ContentType: contentType,
+		// the length changes when a field is dropped
+		ContentLength: -1,
+		Body:          body,
+	})
+	if err != nil {
+		// a failed copy is the cause, file_service only sees the body cut
+		select {
+		case filterErr := <-filtered:
+			if filterErr == errForeignNote {
+				h.Logger.Warnf("audit: user %s uploaded a file to note %s with a body for another note", r.Context().Value("user_uuid"), noteUUID)
+			}
+			if filterErr != nil {
+				return filterErr
+			}
+		default:
+		}
+		return err
+	}
+	h.Logger.Infof("audit: user %s uploaded a file to note %s", r.Context().Value("user_uuid"), noteUUID)
+
+	w.WriteHeader(http.StatusCreated)
+
+	return nil
+}
+
+var errForeignNote = apperror.BadRequestError("note_uuid field differs from the query")
+
+// filterNoteUUID copies the multipart body with the same boundary without note_uuid fields, a field naming another
+// note than noteUUID fails the copy. The result of the copy is sent before the returned reader ends.
+func filterNoteUUID(body io.Reader, boundary, noteUUID string) (io.ReadCloser, <-chan error) {
+	reader, writer := io.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		err := copyParts(body, writer, boundary, noteUUID)
+		result <- err
+		writer.CloseWithError(err)
+	}()
+	return reader, result
+}
+
+func copyParts(body io.Reader, w io.Writer, boundary, noteUUID string) error {
+	reader := multipart.NewReader(body, boundary)
+	writer := multipart.NewWriter(w)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return apperror.BadRequestError("invalid multipart boundary")
+	}
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			return writer.Close()
+		}
+		if err != nil {
+			return apperror.BadRequestError("failed to read multipart body")
+		}
+		if part.FormName() == "note_uuid" {
+			value, err := ioutil.ReadAll(io.LimitReader(part, maxFieldSize))
+			if err != nil {
+				return apperror.BadRequestError("failed to read note_uuid")
+			}
+			if string(value) != noteUUID {
+				return errForeignNote
+			}
+			continue
+		}
+		dst, err := writer.CreatePart(part.Header)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(dst, part); err != nil {
+			return err
+		}
+	}
+}
+
+func (h *Handler) getFileOrUsage(w http.ResponseWriter, r *http.Request) error {
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	if params.ByName("id") == usageID {
+		return h.GetUsage(w, r)
+	}
+	return h.DownloadFile(w, r)
+}
+
+// GetUsage returns how many bytes the user stores against the quota of the plan
+func (h *Handler) GetUsage(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	usage, err := h.FileService.GetUsage(r.Context(), r.Context().Value("user_uuid").(string))
+	if err != nil {
+		return err
+	}
+
+	usageBytes, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(usageBytes)
+
+	return nil
+}
+
+func (h *Handler) DownloadFile(w http.ResponseWriter, r *http.Request) error {
+	noteUUID, err := h.ownedNote(r)
+	if err != nil {
+		return err
+	}
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	stream, err := h.FileService.Download(r.Context(), noteUUID, params.ByName("id"), r.Header)
+	if err != nil {
+		return err
+	}
+	defer stream.Body.Close()
+
+	h.writeStream(w, stream, params.ByName("id"))
+
+	return nil
+}
+
+// GetThumbnail returns JPEG preview of an image attached to a note of the user
+func (h *Handler) GetThumbnail(w http.ResponseWriter, r *http.Request) error {
+	noteUUID, err := h.ownedNote(r)
+	if err != nil {
+		return err
+	}
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	size := r.URL.Query().Get("size")
+	stream, err := h.FileService.Thumbnail(r.Context(), noteUUID, params.ByName("id"), size, r.Header)
+	if err != nil {
+		return err
+	}
+	defer stream.Body.Close()
+
+	h.writeStream(w, stream, params.ByName("id"))
+
+	return nil
+}
+
+func (h *Handler) writeStream(w http.ResponseWriter, stream file_service.FileStream, fileID string) {
+	for _, name := range streamedResponseHeaders {
+		if value := stream.Header.Get(name); value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+	w.WriteHeader(stream.StatusCode)
+	if _, err := io.Copy(w, stream.Body); err != nil {
+		// headers are sent already, the client sees a truncated body
+		h.Logger.Warnf("failed to stream file %s: %v", fileID, err)
+	}
+}
+
+func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	noteUUID, err := h.ownedNote(r)
+	if err != nil {
+		return err
+	}
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	if err = h.FileService.Delete(r.Context(), noteUUID, params.ByName("id")); err != nil {
+		return err
+	}
+	h.Logger.Infof("audit: user %s deleted file %s of note %s", r.Context().Value("user_uuid"), params.ByName("id"), noteUUID)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// CreateUpload starts a resumable upload to a note of the user
+func (h *Handler) CreateUpload(w http.ResponseWriter, r *http.Request) error {
+	noteUUID, err := h.ownedNote(r)
+	if err != nil {
+		return err
+	}
+	userUUID := r.Context().Value("user_uuid").(string)
+
+	stream, err := h.FileService.ResumableUpload(r.Context(), file_service.ResumableUploadDTO{
+		Method:   http.MethodPost,
+		NoteUUID: noteUUID,
+		UserUUID: userUUID,
+		Header:   r.Header,
+	})
+	if err != nil {
+		return err
+	}
+	defer stream.Body.Close()
+	if stream.StatusCode == http.StatusCreated {
+		h.Logger.Infof("audit: user %s started an upload to note %s", userUUID, noteUUID)
+	}
+
+	h.writeUploadResponse(w, stream)
+
+	return nil
+}
+
+// ResumeUpload passes offset requests, chunks and aborts to file_service, which finds only uploads of the user,
+// so the note ownership is checked once when the upload is created
+func (h *Handler) ResumeUpload(w http.ResponseWriter, r *http.Request) error {
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+
+	dto := file_service.ResumableUploadDTO{
+		Method:   r.Method,
+		UploadID: params.ByName("id"),
+		UserUUID: r.Context().Value("user_uuid").(string),
+		Header:   r.Header,
+	}
+	if r.Method == http.MethodPatch {
+		defer r.Body.Close()
+		dto.Body = r.Body
+		dto.ContentLength = r.ContentLength
+	}
+
+	stream, err := h.FileService.ResumableUpload(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+	defer stream.Body.Close()
+
+	h.writeUploadResponse(w, stream)
+
+	return nil
+}
+
+func (h *Handler) writeUploadResponse(w http.ResponseWriter, stream file_service.FileStream) {
+	for _, name := range uploadResponseHeaders {
+		if value := stream.Header.Get(name); value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+	w.WriteHeader(stream.StatusCode)
+	if _, err := io.Copy(w, stream.Body); err != nil {
+		h.Logger.Warnf("failed to pass upload response: %v", err)
+	}
+}
+
+// ownedNote returns note_uuid of the request if the note is in a category of the user,
+// notes of other users are reported as not found
+func (h *Handler) ownedNote(r *http.Request) (string, error) {
+	noteUUID := r.URL.Query().Get("note_uuid")
+	if noteUUID == "" {
+		return "", apperror.BadRequestError("note_uuid query parameter is required")
+	}
+
+	noteBytes, err := h.NoteService.GetByUUID(r.Context(), noteUUID)
+	if err != nil {
+		return "", err
+	}
+	var note note_service.Note
+	if err = json.Unmarshal(noteBytes, &note); err != nil {
+		return "", err
+	}
+
+	userUUID := r.Context().Value("user_uuid").(string)
+	categoriesBytes, err := h.CategoryService.GetUserCategories(r.Context(), userUUID)
+	if err != nil {
+		return "", err
+	}
+	var categories []category_service.Category
+	if err = json.Unmarshal(categoriesBytes, &categories); err != nil {
+		return "", err
+	}
+	for _, categoryUUID := range category_service.UUIDs(categories) {
+		if categoryUUID == note.CategoryUUID {
+			return noteUUID, nil
+		}
+	}
+
+	h.Logger.Warnf("audit: user %s requested files of note %s of another user", userUUID, noteUUID)
+	return "", apperror.ErrNotFound
+}
@@ -0,0 +1,219 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/category_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/file_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/note_service"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+)
+
+type fakeNotes struct {
+	note_service.NoteService
+}
+
+func (f *fakeNotes) GetByUUID(_ context.Context, uuid string) ([]byte, error) {
+	if uuid == "own" {
+		return []byte(`{"uuid":"own","category_uuid":"child"}`), nil
+	}
+	return []byte(`{"uuid":"foreign","category_uuid":"other"}`), nil
+}
+
+type fakeCategories struct {
+	category_service.CategoryService
+}
+
+func (f *fakeCategories) GetUserCategories(context.Context, string) ([]byte, error) {
+	return []byte(`[{"uuid":"root","children":[{"uuid":"child"}]}]`), nil
+}
+
+type fakeFiles struct {
+	file_service.FileService
+	downloaded []string
+	uploads    []file_service.ResumableUploadDTO
+	// uploaded are bodies of uploads read to the end
+	uploaded []string
+}
+
+func (f *fakeFiles) Upload(_ context.Context, dto file_service.UploadFileDTO) error {
+	body, err := ioutil.ReadAll(dto.Body)
+	if err != nil {
+		return err
+	}
+	f.uploaded = append(f.uploaded, dto.NoteUUID+":"+string(body))
+	return nil
+}
+
+func (f *fakeFiles) ResumableUpload(_ context.Context, dto file_service.ResumableUploadDTO) (file_service.FileStream, error) {
+	f.uploads = append(f.uploads, dto)
+	return file_service.FileStream{
+		StatusCode: http.StatusCreated,
+		Header:     http.Header{"Location": {"/api/uploads/upload"}, "Upload-Offset": {"0"}, "X-Internal": {"1"}},
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}, nil
+}
+
+func (f *fakeFiles) GetUsage(_ context.Context, userUUID string) (file_service.Usage, error) {
+	return file_service.Usage{Plan: userUUID + "-plan", Used: 10, Quota: 100}, nil
+}
+
+func (f *fakeFiles) Download(_ context.Context, noteUUID, fileID string, header http.Header) (file_service.FileStream, error) {
+	f.downloaded = append(f.downloaded, noteUUID+"/"+fileID)
+	return file_service.FileStream{
+		StatusCode: http.StatusPartialContent,
+		Header:     http.Header{"Content-Type": {"text/plain"}, "Content-Range": {"bytes 0-4/11"}},
+		Body:       ioutil.NopCloser(strings.NewReader("hello")),
+	}, nil
+}
+
+func download(h *Handler, noteUUID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/files/file?note_uuid="+noteUUID, nil)
+	ctx := context.WithValue(req.Context(), "user_uuid", "user")
+	ctx = context.WithValue(ctx, httprouter.ParamsKey, httprouter.Params{{Key: "id", Value: "file"}})
+	w := httptest.NewRecorder()
+	apperror.Middleware(h.DownloadFile)(w, req.WithContext(ctx))
+	return w
+}
+
+func TestDownloadFile(t *testing.T) {
+	files := &fakeFiles{}
+	h := &Handler{
+		Logger:          logging.Logger{Entry: logrus.NewEntry(logrus.New())},
+		FileService:     files,
+		NoteService:     &fakeNotes{},
+		CategoryService: &fakeCategories{},
+	}
+
+	// Test scenario:
+	// 1. file of a note in a subcategory of the user is streamed with status and headers of file_service
+	w := download(h, "own")
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "bytes 0-4/11", w.Header().Get("Content-Range"))
+	body, _ := ioutil.ReadAll(w.Body)
+	assert.Equal(t, "hello", string(body))
+
+	// Test scenario:
+	// 1. file of a note of another user is not found and file_service is not asked for it
+	w = download(h, "foreign")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, []string{"own/file"}, files.downloaded)
+}
+
+func TestCreateUpload(t *testing.T) {
+	files := &fakeFiles{}
+	h := &Handler{
+		Logger:          logging.Logger{Entry: logrus.NewEntry(logrus.New())},
+		FileService:     files,
+		NoteService:     &fakeNotes{},
+		CategoryService: &fakeCategories{},
+	}
+	create := func(noteUUID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/uploads?note_uuid="+noteUUID, nil)
+		req.Header.Set("Upload-Length", "100")
+		w := httptest.NewRecorder()
+		apperror.Middleware(h.CreateUpload)(w, req.WithContext(context.WithValue(req.Context(), "user_uuid", "user")))
+		return w
+	}
+
+	// Test scenario:
+	// 1. upload to a note of the user is created in file_service on behalf of the user
+	// 2. tus headers of file_service are passed to the client, others are not
+	w := create("own")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/api/uploads/upload", w.Header().Get("Location"))
+	assert.Equal(t, "0", w.Header().Get("Upload-Offset"))
+	assert.Equal(t, "", w.Header().Get("X-Internal"))
+	assert.Equal(t, 1, len(files.uploads))
+	assert.Equal(t, "user", files.uploads[0].UserUUID)
+	assert.Equal(t, "own", files.uploads[0].NoteUUID)
+	assert.Equal(t, "100", files.uploads[0].Header.Get("Upload-Length"))
+
+	// Test scenario:
+	// 1. upload to a note of another user is not found and file_service is not asked for it
+	w = create("foreign")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, 1, len(files.uploads))
+}
+
+// uploadBody is a multipart body with the note_uuid field before the file, no field is sent when it is empty
+func uploadBody(field string) (string, string) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if field != "" {
+		writer.WriteField("note_uuid", field)
+	}
+	part, _ := writer.CreateFormFile("file", "notes.txt")
+	part.Write([]byte("notes"))
+	writer.Close()
+	return body.String(), writer.FormDataContentType()
+}
+
+func TestUploadFile(t *testing.T) {
+	files := &fakeFiles{}
+	h := &Handler{
+		Logger:          logging.Logger{Entry: logrus.NewEntry(logrus.New())},
+		FileService:     files,
+		NoteService:     &fakeNotes{},
+		CategoryService: &fakeCategories{},
+	}
+	upload := func(noteUUID, field string) *httptest.ResponseRecorder {
+		body, contentType := uploadBody(field)
+		req := httptest.NewRequest(http.MethodPost, "/api/files?note_uuid="+noteUUID, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		apperror.Middleware(h.UploadFile)(w, req.WithContext(context.WithValue(req.Context(), "user_uuid", "user")))
+		return w
+	}
+
+	// Test scenario:
+	// 1. upload to a note of the user with a body naming a note of another user fails
+	// 2. upload to a note of another user is not found
+	assert.Equal(t, http.StatusBadRequest, upload("own", "foreign").Code)
+	assert.Equal(t, http.StatusNotFound, upload("foreign", "foreign").Code)
+	assert.Empty(t, files.uploaded)
+
+	// Test scenario:
+	// 1. note_uuid field of the same note is dropped, the file is passed as is
+	assert.Equal(t, http.StatusCreated, upload("own", "own").Code)
+	assert.Equal(t, http.StatusCreated, upload("own", "").Code)
+	if assert.Len(t, files.uploaded, 2) {
+		for _, uploaded := range files.uploaded {
+			assert.True(t, strings.HasPrefix(uploaded, "own:"))
+			assert.NotContains(t, uploaded, `name="note_uuid"`)
+			assert.Contains(t, uploaded, "notes.txt\"\r\nContent-Type: application/octet-stream\r\n\r\nnotes\r\n")
+		}
+	}
+}
+
+// Test scenario:
+// 1. usage shares the route with files and is returned for the user of the token
+func TestGetUsage(t *testing.T) {
+	files := &fakeFiles{}
+	h := &Handler{
+		Logger:          logging.Logger{Entry: logrus.NewEntry(logrus.New())},
+		FileService:     files,
+		NoteService:     &fakeNotes{},
+		CategoryService: &fakeCategories{},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/files/usage", nil)
+	ctx := context.WithValue(req.Context(), "user_uuid", "user")
+	ctx = context.WithValue(ctx, httprouter.ParamsKey, httprouter.Params{{Key: "id", Value: "usage"}})
+	w := httptest.NewRecorder()
+	apperror.Middleware(h.getFileOrUsage)(w, req.WithContext(ctx))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"plan":"user-plan","used":10,"pending":0,"quota":100,"max_file_size":0}`, w.Body.String())
+	assert.Equal(t, 0, len(files.downloaded))
+}
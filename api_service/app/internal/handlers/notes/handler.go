@@ -6,6 +6,8 @@ import (
 	"github.com/julienschmidt/httprouter"
 	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
 	"github.com/theartofdevel/notes_system/api_service/internal/client/note_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/handlers/tags"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache"
 	"github.com/theartofdevel/notes_system/api_service/pkg/jwt"
 	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
 	"net/http"
@@ -19,14 +21,17 @@ const (
 type Handler struct {
 	Logger      logging.Logger
 	NoteService note_service.NoteService
+	// TagsStatsCache is the cache of tags handler, stats of the user are dropped from it when notes change
+	TagsStatsCache cache.Repository
 }
 
 func (h *Handler) Register(router *httprouter.Router) {
+	canWrite := jwt.RequireRole(jwt.RoleUser, jwt.RoleAdmin)
 	router.HandlerFunc(http.MethodGet, notesURL, jwt.Middleware(apperror.Middleware(h.GetNotes)))
-	router.HandlerFunc(http.MethodPost, notesURL, jwt.Middleware(apperror.Middleware(h.CreateNote)))
+	router.HandlerFunc(http.MethodPost, notesURL, jwt.Middleware(canWrite(apperror.Middleware(h.CreateNote))))
 	router.HandlerFunc(http.MethodGet, noteURL, jwt.Middleware(apperror.Middleware(h.GetNoteByUuid)))
-	router.HandlerFunc(http.MethodPatch, noteURL, jwt.Middleware(apperror.Middleware(h.PartiallyUpdateNote)))
-	router.HandlerFunc(http.MethodDelete, noteURL, jwt.Middleware(apperror.Middleware(h.DeleteNote)))
+	router.HandlerFunc(http.MethodPatch, noteURL, jwt.Middleware(canWrite(apperror.Middleware(h.PartiallyUpdateNote))))
+	router.HandlerFunc(http.MethodDelete, noteURL, jwt.Middleware(canWrite(apperror.Middleware(h.DeleteNote))))
 }
 
 func (h *Handler) GetNotes(w http.ResponseWriter, r *http.Request) error {
@@ -57,6 +62,7 @@ func (h *Handler) CreateNote(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
+	h.invalidateTagsStats(r)
 
 	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Location", fmt.Sprintf("%s/%s", notesURL, noteUUID))
@@ -95,6 +101,7 @@ func (h *Handler) PartiallyUpdateNote(w http.ResponseWriter, r *http.Request) er
 	if err := h.NoteService.Update(r.Context(), noteUUID, dto); err != nil {
 		return err
 	}
+	h.invalidateTagsStats(r)
 	w.WriteHeader(http.StatusNoContent)
 
 	return nil
@@ -108,7 +115,18 @@ func (h *Handler) DeleteNote(w http.ResponseWriter, r *http.Request) error {
 	if err := h.NoteService.Delete(r.Context(), noteUUID); err != nil {
 		return err
 	}
+	h.invalidateTagsStats(r)
 	w.WriteHeader(http.StatusNoContent)
 
 	return nil
 }
+
+// invalidateTagsStats drops cached tags stats of the user, they count notes by tags
+func (h *Handler) invalidateTagsStats(r *http.Request) {
+	if h.TagsStatsCache == nil {
+		return
+	}
+	if userUUID, ok := r.Context().Value("user_uuid").(string); ok {
+		h.TagsStatsCache.Del(tags.StatsCacheKey(userUUID))
+	}
+}
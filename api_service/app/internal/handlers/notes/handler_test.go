@@ -0,0 +1,88 @@
+package notes
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/note_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/handlers/tags"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache/freecache"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+)
+
+type fakeNotes struct {
+	note_service.NoteService
+	err error
+}
+
+func (f *fakeNotes) Create(context.Context, note_service.CreateNoteDTO) (string, error) {
+	return "60697c345ab2b15a8409fd5f", f.err
+}
+
+func (f *fakeNotes) Update(context.Context, string, note_service.UpdateNoteDTO) error {
+	return f.err
+}
+
+func (f *fakeNotes) Delete(context.Context, string) error {
+	return f.err
+}
+
+func serve(handler func(http.ResponseWriter, *http.Request) error, method, body string) int {
+	req := httptest.NewRequest(method, noteURL, bytes.NewBufferString(body))
+	ctx := context.WithValue(req.Context(), "user_uuid", "user")
+	ctx = context.WithValue(ctx, httprouter.ParamsKey, httprouter.Params{{Key: "uuid", Value: "60697c345ab2b15a8409fd5f"}})
+	w := httptest.NewRecorder()
+	apperror.Middleware(handler)(w, req.WithContext(ctx))
+	return w.Code
+}
+
+func TestWritesInvalidateTagsStats(t *testing.T) {
+	notes := &fakeNotes{}
+	statsCache := freecache.NewCacheRepo(1 << 20)
+	h := &Handler{
+		Logger:         logging.Logger{Entry: logrus.NewEntry(logrus.New())},
+		NoteService:    notes,
+		TagsStatsCache: statsCache,
+	}
+	writes := []struct {
+		name    string
+		handler func(http.ResponseWriter, *http.Request) error
+		method  string
+		code    int
+	}{
+		{"create", h.CreateNote, http.MethodPost, http.StatusCreated},
+		{"update", h.PartiallyUpdateNote, http.MethodPatch, http.StatusNoContent},
+		{"delete", h.DeleteNote, http.MethodDelete, http.StatusNoContent},
+	}
+
+	// Test scenario:
+	// 1. every write of a note drops cached tags stats of its user, stats of other users are kept
+	for _, write := range writes {
+		statsCache.Set(tags.StatsCacheKey("user"), []byte("[]"), 60)
+		statsCache.Set(tags.StatsCacheKey("other"), []byte("[]"), 60)
+
+		assert.Equal(t, write.code, serve(write.handler, write.method, `{"header":"note"}`), write.name)
+		_, err := statsCache.Get(tags.StatsCacheKey("user"))
+		assert.Error(t, err, write.name)
+		_, err = statsCache.Get(tags.StatsCacheKey("other"))
+		assert.NoError(t, err, write.name)
+	}
+
+	// Test scenario:
+	// 1. failed writes keep the stats
+	notes.err = apperror.ErrNotFound
+	for _, write := range writes {
+		statsCache.Set(tags.StatsCacheKey("user"), []byte("[]"), 60)
+
+		assert.Equal(t, http.StatusNotFound, serve(write.handler, write.method, `{"header":"note"}`), write.name)
+		_, err := statsCache.Get(tags.StatsCacheKey("user"))
+		assert.NoError(t, err, write.name)
+	}
+}
@@ -5,30 +5,51 @@ import (
 	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/note_service"
 	"github.com/theartofdevel/notes_system/api_service/internal/client/tag_service"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache"
 	"github.com/theartofdevel/notes_system/api_service/pkg/jwt"
 	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
 	tagsURL = "/api/tags"
 	tagURL  = "/api/tags/:id"
+
+	// httprouter doesn't allow static segments next to :id, so GetTag dispatches them
+	statsSegment   = "stats"
+	paletteSegment = "palette"
+
+	statsCacheKeyPrefix = "tags_stats:"
 )
 
 type Handler struct {
-	Logger     logging.Logger
-	TagService tag_service.TagService
+	Logger      logging.Logger
+	TagService  tag_service.TagService
+	NoteService note_service.NoteService
+	// StatsCache is optional, stats are computed on every request without it
+	StatsCache    cache.Repository
+	StatsCacheTTL int
+}
+
+type TagStats struct {
+	tag_service.Tag
+	NotesCount int        `json:"notes_count"`
+	LastUsed   *time.Time `json:"last_used"`
 }
 
 func (h *Handler) Register(router *httprouter.Router) {
+	canWrite := jwt.RequireRole(jwt.RoleUser, jwt.RoleAdmin)
 	router.HandlerFunc(http.MethodGet, tagURL, jwt.Middleware(apperror.Middleware(h.GetTag)))
 	router.HandlerFunc(http.MethodGet, tagsURL, jwt.Middleware(apperror.Middleware(h.GetManyTags)))
-	router.HandlerFunc(http.MethodPost, tagsURL, jwt.Middleware(apperror.Middleware(h.CreateTag)))
-	router.HandlerFunc(http.MethodPatch, tagURL, jwt.Middleware(apperror.Middleware(h.PartiallyUpdateTag)))
-	router.HandlerFunc(http.MethodDelete, tagURL, jwt.Middleware(apperror.Middleware(h.DeleteTag)))
+	router.HandlerFunc(http.MethodPost, tagsURL, jwt.Middleware(canWrite(apperror.Middleware(h.CreateTag))))
+	router.HandlerFunc(http.MethodPatch, tagURL, jwt.Middleware(canWrite(apperror.Middleware(h.PartiallyUpdateTag))))
+	router.HandlerFunc(http.MethodDelete, tagURL, jwt.Middleware(canWrite(apperror.Middleware(h.DeleteTag))))
 }
 
 func (h *Handler) GetTag(w http.ResponseWriter, r *http.Request) error {
@@ -36,6 +57,12 @@ func (h *Handler) GetTag(w http.ResponseWriter, r *http.Request) error {
 
 	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
 	tagIDStr := params.ByName("id")
+	switch tagIDStr {
+	case statsSegment:
+		return h.GetTagsStats(w, r)
+	case paletteSegment:
+		return h.GetPalette(w, r)
+	}
 	id, err := strconv.Atoi(tagIDStr)
 	if err != nil {
 		return apperror.BadRequestError("invalid id")
@@ -105,6 +132,7 @@ func (h *Handler) CreateTag(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
+	h.invalidateStats(userUUID)
 
 	w.Header().Set("Location", fmt.Sprintf("%s/%s", tagsURL, tagID))
 	w.WriteHeader(http.StatusCreated)
@@ -133,6 +161,7 @@ func (h *Handler) PartiallyUpdateTag(w http.ResponseWriter, r *http.Request) err
 	if err := h.TagService.Update(r.Context(), tagId, dto); err != nil {
 		return err
 	}
+	h.invalidateStats(userUUID)
 
 	w.WriteHeader(http.StatusNoContent)
 
@@ -146,13 +175,112 @@ func (h *Handler) DeleteTag(w http.ResponseWriter, r *http.Request) error {
 		h.Logger.Error("there is no user_uuid in context")
 		return apperror.UnauthorizedError("")
 	}
+	userUUID := r.Context().Value("user_uuid").(string)
 
 	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
 	tagId := params.ByName("id")
 	if err := h.TagService.Delete(r.Context(), tagId); err != nil {
 		return err
 	}
+	h.invalidateStats(userUUID)
 	w.WriteHeader(http.StatusNoContent)
 
 	return nil
 }
+
+func (h *Handler) GetTagsStats(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Context().Value("user_uuid") == nil {
+		h.Logger.Error("there is no user_uuid in context")
+		return apperror.UnauthorizedError("")
+	}
+	userUUID := r.Context().Value("user_uuid").(string)
+
+	if h.StatsCache != nil {
+		if statsBytes, err := h.StatsCache.Get(StatsCacheKey(userUUID)); err == nil {
+			h.Logger.Debug("tags stats found in cache")
+			w.WriteHeader(http.StatusOK)
+			w.Write(statsBytes)
+			return nil
+		}
+	}
+
+	tags, err := h.TagService.GetByOwner(r.Context(), userUUID)
+	if err != nil {
+		return err
+	}
+
+	stats := make([]TagStats, 0, len(tags))
+	if len(tags) > 0 {
+		tagIDs := make([]int, 0, len(tags))
+		for _, t := range tags {
+			tagIDs = append(tagIDs, t.ID)
+		}
+
+		notesStats, err := h.NoteService.GetTagsStats(r.Context(), tagIDs)
+		if err != nil {
+			return err
+		}
+		usage := make(map[int]note_service.TagStats, len(notesStats))
+		for _, ns := range notesStats {
+			usage[ns.TagID] = ns
+		}
+
+		for _, t := range tags {
+			ts := TagStats{Tag: t}
+			if u, ok := usage[t.ID]; ok {
+				ts.NotesCount = u.NotesCount
+				if !u.LastUsed.IsZero() {
+					lastUsed := u.LastUsed
+					ts.LastUsed = &lastUsed
+				}
+			}
+			stats = append(stats, ts)
+		}
+		sort.SliceStable(stats, func(i, j int) bool {
+			return stats[i].NotesCount > stats[j].NotesCount
+		})
+	}
+
+	statsBytes, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags stats. error: %w", err)
+	}
+
+	if h.StatsCache != nil {
+		if err = h.StatsCache.Set(StatsCacheKey(userUUID), statsBytes, h.StatsCacheTTL); err != nil {
+			h.Logger.Errorf("failed to cache tags stats due to error %v", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(statsBytes)
+
+	return nil
+}
+
+func (h *Handler) GetPalette(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	palette, err := h.TagService.GetPalette(r.Context())
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(palette)
+
+	return nil
+}
+
+func (h *Handler) invalidateStats(userUUID string) {
+	if h.StatsCache != nil {
+		h.StatsCache.Del(StatsCacheKey(userUUID))
+	}
+}
+
+// StatsCacheKey is the key of cached tags stats of the user, notes handlers drop it when notes change
+func StatsCacheKey(userUUID string) []byte {
+	return []byte(statsCacheKeyPrefix + userUUID)
+}
@@ -0,0 +1,91 @@
+package tags
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/note_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/tag_service"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache/freecache"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+)
+
+type fakeTags struct {
+	tag_service.TagService
+	tags []tag_service.Tag
+}
+
+func (f *fakeTags) GetByOwner(context.Context, string) ([]tag_service.Tag, error) {
+	return f.tags, nil
+}
+
+func (f *fakeTags) Create(_ context.Context, dto tag_service.CreateTagDTO) (string, error) {
+	f.tags = append(f.tags, tag_service.Tag{ID: len(f.tags) + 1, Name: dto.Name, OwnerID: dto.UserUUID})
+	return "3", nil
+}
+
+type fakeNotes struct {
+	note_service.NoteService
+	calls int
+}
+
+func (f *fakeNotes) GetTagsStats(_ context.Context, tagIDs []int) ([]note_service.TagStats, error) {
+	f.calls++
+	return []note_service.TagStats{{TagID: 2, NotesCount: 5, LastUsed: time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)}}, nil
+}
+
+func stats(h *Handler) []TagStats {
+	req := httptest.NewRequest(http.MethodGet, "/api/tags/stats", nil)
+	ctx := context.WithValue(req.Context(), "user_uuid", "user")
+	ctx = context.WithValue(ctx, httprouter.ParamsKey, httprouter.Params{{Key: "id", Value: statsSegment}})
+	w := httptest.NewRecorder()
+	apperror.Middleware(h.GetTag)(w, req.WithContext(ctx))
+
+	var result []TagStats
+	json.NewDecoder(w.Body).Decode(&result)
+	return result
+}
+
+func TestGetTagsStats(t *testing.T) {
+	tags := &fakeTags{tags: []tag_service.Tag{{ID: 1, Name: "unused"}, {ID: 2, Name: "work"}}}
+	notes := &fakeNotes{}
+	h := &Handler{
+		Logger:        logging.Logger{Entry: logrus.NewEntry(logrus.New())},
+		TagService:    tags,
+		NoteService:   notes,
+		StatsCache:    freecache.NewCacheRepo(1 << 20),
+		StatsCacheTTL: 60,
+	}
+
+	// Test scenario:
+	// 1. tags of the user are sorted by usage, unused tags have no last use
+	result := stats(h)
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, "work", result[0].Name)
+	assert.Equal(t, 5, result[0].NotesCount)
+	assert.NotNil(t, result[0].LastUsed)
+	assert.Nil(t, result[1].LastUsed)
+
+	// Test scenario:
+	// 1. stats are served from cache until the tags of the user change
+	stats(h)
+	assert.Equal(t, 1, notes.calls)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tags", bytes.NewBufferString(`{"name":"new"}`))
+	w := httptest.NewRecorder()
+	apperror.Middleware(h.CreateTag)(w, req.WithContext(context.WithValue(req.Context(), "user_uuid", "user")))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	result = stats(h)
+	assert.Equal(t, 2, notes.calls)
+	assert.Equal(t, 3, len(result))
+}
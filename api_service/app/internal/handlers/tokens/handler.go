@@ -0,0 +1,103 @@
+package tokens
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/user_service"
+	"github.com/theartofdevel/notes_system/api_service/pkg/jwt"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+	"net/http"
+	"strings"
+)
+
+const (
+	tokensURL = "/api/tokens"
+	tokenURL  = "/api/tokens/:id"
+)
+
+type Handler struct {
+	Logger      logging.Logger
+	UserService user_service.UserService
+}
+
+func (h *Handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, tokensURL, jwt.Middleware(apperror.Middleware(h.GetTokens)))
+	router.HandlerFunc(http.MethodPost, tokensURL, jwt.Middleware(apperror.Middleware(h.CreateToken)))
+	router.HandlerFunc(http.MethodDelete, tokenURL, jwt.Middleware(apperror.Middleware(h.DeleteToken)))
+}
+
+func (h *Handler) GetTokens(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	userUUID := r.Context().Value("user_uuid").(string)
+	tokens, err := h.UserService.GetAPITokens(r.Context(), userUUID)
+	if err != nil {
+		return err
+	}
+
+	tokensBytes, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(tokensBytes)
+
+	return nil
+}
+
+func (h *Handler) CreateToken(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	defer r.Body.Close()
+	var dto user_service.CreateAPITokenDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+	dto.UserUUID = r.Context().Value("user_uuid").(string)
+
+	roles, _ := r.Context().Value("roles").([]string)
+	if jwt.HasRole(roles, jwt.RoleReadOnly) {
+		for _, scope := range dto.Scopes {
+			if strings.HasSuffix(scope, ":write") {
+				return apperror.ErrForbidden
+			}
+		}
+	}
+
+	token, err := h.UserService.CreateAPIToken(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+	h.Logger.Infof("audit: user %s created api token %s with scopes %v", dto.UserUUID, token.ID, token.Scopes)
+
+	tokenBytes, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%s", tokensURL, token.ID))
+	w.WriteHeader(http.StatusCreated)
+	w.Write(tokenBytes)
+
+	return nil
+}
+
+func (h *Handler) DeleteToken(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	id := params.ByName("id")
+	userUUID := r.Context().Value("user_uuid").(string)
+
+	if err := h.UserService.DeleteAPIToken(r.Context(), id, userUUID); err != nil {
+		return err
+	}
+	h.Logger.Infof("audit: user %s revoked api token %s", userUUID, id)
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
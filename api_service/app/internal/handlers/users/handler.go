@@ -0,0 +1,185 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"github.com/theartofdevel/notes_system/api_service/internal/account"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/category_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/user_service"
+	"github.com/theartofdevel/notes_system/api_service/pkg/jwt"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+	"net/http"
+)
+
+const (
+	meURL            = "/api/users/me"
+	meProfileURL     = "/api/users/me/profile"
+	mePreferencesURL = "/api/users/me/preferences"
+)
+
+// DeleteMeDTO confirms deletion of the account with the password, or with a 2FA code when 2FA is enabled
+type DeleteMeDTO struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type Handler struct {
+	Logger          logging.Logger
+	UserService     user_service.UserService
+	CategoryService category_service.CategoryService
+	JWTHelper       jwt.Helper
+	Deleter         *account.Deleter
+}
+
+func (h *Handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, meURL, jwt.Middleware(apperror.Middleware(h.GetMe)))
+	router.HandlerFunc(http.MethodPatch, meProfileURL, jwt.Middleware(apperror.Middleware(h.UpdateProfile)))
+	router.HandlerFunc(http.MethodPatch, mePreferencesURL, jwt.Middleware(apperror.Middleware(h.UpdatePreferences)))
+	router.HandlerFunc(http.MethodDelete, meURL, jwt.Middleware(apperror.Middleware(h.DeleteMe)))
+}
+
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	userUUID := r.Context().Value("user_uuid").(string)
+	u, err := h.UserService.GetByUUID(r.Context(), userUUID)
+	if err != nil {
+		return err
+	}
+
+	userBytes, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(userBytes)
+
+	return nil
+}
+
+func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	defer r.Body.Close()
+	var dto user_service.UpdateProfileDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	userUUID := r.Context().Value("user_uuid").(string)
+	if err := h.UserService.UpdateProfile(r.Context(), userUUID, dto); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) UpdatePreferences(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	defer r.Body.Close()
+	var dto user_service.UpdatePreferencesDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	userUUID := r.Context().Value("user_uuid").(string)
+	if dto.DefaultCategoryUUID != nil && *dto.DefaultCategoryUUID != "" {
+		owned, err := h.ownsCategory(r, userUUID, *dto.DefaultCategoryUUID)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return apperror.BadRequestError("default category is not found")
+		}
+	}
+
+	if err := h.UserService.UpdatePreferences(r.Context(), userUUID, dto); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// DeleteMe deletes the account with all its data. Partially deleted account answers 502 with the report
+// and can be deleted again, the user is removed only after everything else is gone.
+func (h *Handler) DeleteMe(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	defer r.Body.Close()
+	var dto DeleteMeDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	userUUID := r.Context().Value("user_uuid").(string)
+	if err := h.confirmUser(r.Context(), userUUID, dto); err != nil {
+		h.Logger.Warnf("audit: deletion of account of user %s is not confirmed: %v", userUUID, err)
+		return err
+	}
+	report := h.Deleter.Delete(r.Context(), userUUID)
+
+	status := http.StatusBadGateway
+	if report.Completed() {
+		revoked := h.JWTHelper.RevokeUserRefreshTokens(userUUID)
+		h.Logger.Infof("audit: user %s deleted the account, %d sessions revoked", userUUID, revoked)
+		status = http.StatusOK
+	} else {
+		h.Logger.Warnf("audit: account of user %s is deleted partially", userUUID)
+	}
+
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(status)
+	w.Write(reportBytes)
+
+	return nil
+}
+
+// confirmUser checks that the request is made by the user and not only with a token of the user
+func (h *Handler) confirmUser(ctx context.Context, userUUID string, dto DeleteMeDTO) error {
+	u, err := h.UserService.GetByUUID(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+	if u.MFAEnabled {
+		if dto.Code == "" {
+			return apperror.BadRequestError("2FA code is required")
+		}
+		return h.UserService.VerifyMFA(ctx, u.UUID, dto.Code)
+	}
+	if dto.Password == "" {
+		return apperror.BadRequestError("password is required")
+	}
+	_, err = h.UserService.Authenticate(ctx, u.Email, dto.Password)
+	return err
+}
+
+func (h *Handler) ownsCategory(r *http.Request, userUUID, categoryUUID string) (bool, error) {
+	categoriesBytes, err := h.CategoryService.GetUserCategories(r.Context(), userUUID)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	var categories []category_service.Category
+	if err = json.Unmarshal(categoriesBytes, &categories); err != nil {
+		return false, err
+	}
+	for _, uuid := range category_service.UUIDs(categories) {
+		if uuid == categoryUUID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,121 @@
+package users
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/theartofdevel/notes_system/api_service/internal/account"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/category_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/tag_service"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/user_service"
+	"github.com/theartofdevel/notes_system/api_service/pkg/jwt"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+)
+
+// fakeUsers has the password "Notes2021" and the 2FA code "123456"
+type fakeUsers struct {
+	user_service.UserService
+	user    user_service.User
+	deleted bool
+}
+
+func (f *fakeUsers) GetByUUID(context.Context, string) (user_service.User, error) {
+	return f.user, nil
+}
+
+func (f *fakeUsers) Authenticate(_ context.Context, email, password string) (user_service.User, error) {
+	if email != f.user.Email || password != "Notes2021" {
+		return user_service.User{}, apperror.UnauthorizedError("invalid email or password")
+	}
+	return f.user, nil
+}
+
+func (f *fakeUsers) VerifyMFA(_ context.Context, _, code string) error {
+	if code != "123456" {
+		return apperror.UnauthorizedError("invalid email or password")
+	}
+	return nil
+}
+
+func (f *fakeUsers) Delete(context.Context, string) error {
+	f.deleted = true
+	return nil
+}
+
+type noCategories struct {
+	category_service.CategoryService
+}
+
+func (noCategories) GetUserCategories(context.Context, string) ([]byte, error) {
+	return nil, apperror.ErrNotFound
+}
+
+func (noCategories) DeleteUserCategories(context.Context, string) (int, error) {
+	return 0, nil
+}
+
+type noTags struct {
+	tag_service.TagService
+}
+
+func (noTags) DeleteByOwner(context.Context, string) (int, error) {
+	return 0, nil
+}
+
+type fakeJWTHelper struct {
+	jwt.Helper
+}
+
+func (fakeJWTHelper) RevokeUserRefreshTokens(string) int {
+	return 0
+}
+
+func deleteMe(h *Handler, body string) int {
+	req := httptest.NewRequest(http.MethodDelete, meURL, strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), "user_uuid", "user"))
+	w := httptest.NewRecorder()
+	apperror.Middleware(h.DeleteMe)(w, req)
+	return w.Code
+}
+
+func TestDeleteMe(t *testing.T) {
+	tests := []struct {
+		name       string
+		mfa        bool
+		body       string
+		wantStatus int
+	}{
+		{"no body", false, "", http.StatusBadRequest},
+		{"no password", false, `{}`, http.StatusBadRequest},
+		{"wrong password", false, `{"password": "wrong"}`, http.StatusBadRequest},
+		{"password", false, `{"password": "Notes2021"}`, http.StatusOK},
+		{"2FA enabled, password only", true, `{"password": "Notes2021"}`, http.StatusBadRequest},
+		{"2FA enabled, wrong code", true, `{"code": "000000"}`, http.StatusBadRequest},
+		{"2FA enabled, code", true, `{"code": "123456"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeUsers{user: user_service.User{UUID: "user", Email: "jane@example.com", MFAEnabled: tt.mfa}}
+			h := &Handler{
+				Logger:      logging.Logger{Entry: logrus.NewEntry(logrus.New())},
+				UserService: users,
+				JWTHelper:   fakeJWTHelper{},
+				Deleter: &account.Deleter{
+					CategoryService: noCategories{},
+					TagService:      noTags{},
+					UserService:     users,
+					Logger:          logging.Logger{Entry: logrus.NewEntry(logrus.New())},
+				},
+			}
+
+			assert.Equal(t, tt.wantStatus, deleteMe(h, tt.body))
+			assert.Equal(t, tt.wantStatus == http.StatusOK, users.deleted)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package lockout
+
+import (
+	"encoding/json"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// stripes is a number of locks keys are spread over
+const stripes = 64
+
+type Config struct {
+	// MaxAttempts is a number of failures allowed before the key is locked
+	MaxAttempts int
+	// BaseLockout is doubled for every failure after MaxAttempts up to MaxLockout
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// Window is how long failures are remembered since the last one
+	Window time.Duration
+}
+
+type state struct {
+	Failures    int   `json:"failures"`
+	LockedUntil int64 `json:"locked_until"`
+}
+
+type Limiter interface {
+	// Locked returns remaining lockout duration, zero if the key isn't locked
+	Locked(key string) time.Duration
+	// Fail registers failed attempt and returns failures count and lockout duration, zero if the key isn't locked
+	Fail(key string) (failures int, lockedFor time.Duration)
+	Reset(key string)
+}
+
+var _ Limiter = &limiter{}
+
+type limiter struct {
+	cache  cache.Repository
+	prefix string
+	cfg    Config
+	// locks serialize reading and writing counters of a key, so concurrent failures are never lost
+	locks [stripes]sync.Mutex
+}
+
+// NewLimiter creates limiter storing counters in cache with keys prefixed by prefix
+func NewLimiter(cache cache.Repository, prefix string, cfg Config) Limiter {
+	return &limiter{cache: cache, prefix: prefix, cfg: cfg}
+}
+
+func (l *limiter) Locked(key string) time.Duration {
+	st := l.get(key)
+	if st.LockedUntil == 0 {
+		return 0
+	}
+	remaining := time.Until(time.Unix(st.LockedUntil, 0))
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+func (l *limiter) Fail(key string) (int, time.Duration) {
+	mu := l.lock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	st := l.get(key)
+	st.Failures++
+
+	var lockedFor time.Duration
+	if st.Failures >= l.cfg.MaxAttempts {
+		lockedFor = l.cfg.BaseLockout
+		for i := l.cfg.MaxAttempts; i < st.Failures && lockedFor < l.cfg.MaxLockout; i++ {
+			lockedFor *= 2
+		}
+		if lockedFor > l.cfg.MaxLockout {
+			lockedFor = l.cfg.MaxLockout
+		}
+		st.LockedUntil = time.Now().Add(lockedFor).Unix()
+	}
+
+	expireIn := l.cfg.Window
+	if lockedFor > expireIn {
+		expireIn = lockedFor
+	}
+	if stateBytes, err := json.Marshal(st); err == nil {
+		l.cache.Set(l.key(key), stateBytes, int(expireIn.Seconds()))
+	}
+	return st.Failures, lockedFor
+}
+
+func (l *limiter) Reset(key string) {
+	mu := l.lock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	l.cache.Del(l.key(key))
+}
+
+func (l *limiter) lock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &l.locks[h.Sum32()%stripes]
+}
+
+func (l *limiter) get(key string) (st state) {
+	stateBytes, err := l.cache.Get(l.key(key))
+	if err != nil {
+		return st
+	}
+	json.Unmarshal(stateBytes, &st)
+	return st
+}
+
+func (l *limiter) key(key string) []byte {
+	return []byte(l.prefix + key)
+}
@@ -0,0 +1,85 @@
+package lockout
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache/freecache"
+)
+
+var cfg = Config{
+	MaxAttempts: 3,
+	BaseLockout: 10 * time.Second,
+	MaxLockout:  30 * time.Second,
+	Window:      time.Minute,
+}
+
+// Test scenario:
+// 1. Fail below MaxAttempts, key must not be locked
+// 2. Fail MaxAttempts time, key must be locked for BaseLockout
+// 3. Every next failure doubles lockout up to MaxLockout
+// 4. Reset unlocks the key
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(freecache.NewCacheRepo(1048576), "test:", cfg)
+
+	for i := 1; i < cfg.MaxAttempts; i++ {
+		failures, lockedFor := l.Fail("user")
+		assert.Equal(t, i, failures)
+		assert.Zero(t, lockedFor)
+	}
+	assert.Zero(t, l.Locked("user"))
+
+	_, lockedFor := l.Fail("user")
+	assert.Equal(t, cfg.BaseLockout, lockedFor)
+	assert.True(t, l.Locked("user") > 0)
+	assert.Zero(t, l.Locked("another user"))
+
+	_, lockedFor = l.Fail("user")
+	assert.Equal(t, 2*cfg.BaseLockout, lockedFor)
+
+	_, lockedFor = l.Fail("user")
+	assert.Equal(t, cfg.MaxLockout, lockedFor)
+
+	l.Reset("user")
+	assert.Zero(t, l.Locked("user"))
+}
+
+// slowCache delays reads, so concurrent failures overlap between reading and writing the counter
+type slowCache struct {
+	cache.Repository
+}
+
+func (c slowCache) Get(key []byte) ([]byte, error) {
+	val, err := c.Repository.Get(key)
+	time.Sleep(time.Millisecond)
+	return val, err
+}
+
+// Test scenario:
+// 1. Fail the key concurrently, no failure must be lost
+// 2. Only MaxAttempts-1 attempts must pass without the lockout
+func TestLimiterConcurrentFail(t *testing.T) {
+	l := NewLimiter(slowCache{freecache.NewCacheRepo(1048576)}, "test:", cfg)
+	const attempts = 50
+
+	var wg sync.WaitGroup
+	unlocked := make(chan int, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if failures, lockedFor := l.Fail("user"); lockedFor == 0 {
+				unlocked <- failures
+			}
+		}()
+	}
+	wg.Wait()
+	close(unlocked)
+
+	assert.Equal(t, cfg.MaxAttempts-1, len(unlocked))
+	failures, _ := l.Fail("user")
+	assert.Equal(t, attempts+1, failures)
+}
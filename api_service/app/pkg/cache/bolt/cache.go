@@ -0,0 +1,210 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache"
+	bolt "go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// expireAtSize is a length of expiration timestamp stored before every value
+	expireAtSize = 8
+	// purgeInterval is how often expired entries which are never read are removed
+	purgeInterval = time.Minute
+)
+
+var (
+	bucketName = []byte("cache")
+
+	ErrNotFound = errors.New("entry not found")
+)
+
+type repository struct {
+	db        *bolt.DB
+	hitCount  int64
+	missCount int64
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+// NewCacheRepo opens or creates the bbolt file at path. Entries survive restarts,
+// expired ones are removed on open, when they are read and every purgeInterval until Close.
+func NewCacheRepo(path string) (cache.Repository, error) {
+	return newCacheRepo(path, purgeInterval)
+}
+
+func newCacheRepo(path string, interval time.Duration) (*repository, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir. error: %w", err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache file. error: %w", err)
+	}
+	r := &repository{db: db, stop: make(chan struct{}), stopped: make(chan struct{})}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucketName)
+		if err != nil {
+			return err
+		}
+		return r.purgeExpired(b)
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init cache bucket. error: %w", err)
+	}
+	go r.purge(interval)
+	return r, nil
+}
+
+func (r *repository) EntryCount() (entryCount int64) {
+	now := time.Now().Unix()
+	r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).ForEach(func(k, v []byte) error {
+			if !isExpired(v, now) {
+				entryCount++
+			}
+			return nil
+		})
+	})
+	return entryCount
+}
+
+func (r *repository) HitCount() int64 {
+	return atomic.LoadInt64(&r.hitCount)
+}
+
+func (r *repository) MissCount() int64 {
+	return atomic.LoadInt64(&r.missCount)
+}
+
+// GetIterator iterates over a snapshot of entries taken at the time of the call
+func (r *repository) GetIterator() cache.Iterator {
+	var entries []*cache.Entry
+	now := time.Now().Unix()
+	r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).ForEach(func(k, v []byte) error {
+			if isExpired(v, now) {
+				return nil
+			}
+			entries = append(entries, &cache.Entry{
+				Key:   append([]byte(nil), k...),
+				Value: append([]byte(nil), v[expireAtSize:]...),
+			})
+			return nil
+		})
+	})
+	return &iterator{entries: entries}
+}
+
+func (r *repository) Get(uuid []byte) (got []byte, err error) {
+	var expired bool
+	now := time.Now().Unix()
+	err = r.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketName).Get(uuid)
+		if v == nil {
+			return ErrNotFound
+		}
+		if isExpired(v, now) {
+			expired = true
+			return ErrNotFound
+		}
+		got = append([]byte(nil), v[expireAtSize:]...)
+		return nil
+	})
+	if expired {
+		r.Del(uuid)
+	}
+	if err != nil {
+		atomic.AddInt64(&r.missCount, 1)
+		return nil, err
+	}
+	atomic.AddInt64(&r.hitCount, 1)
+	return got, nil
+}
+
+func (r *repository) Set(key, val []byte, expireIn int) error {
+	var expireAt int64
+	if expireIn > 0 {
+		expireAt = time.Now().Unix() + int64(expireIn)
+	}
+	v := make([]byte, expireAtSize+len(val))
+	binary.BigEndian.PutUint64(v, uint64(expireAt))
+	copy(v[expireAtSize:], val)
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Put(key, v)
+	})
+}
+
+func (r *repository) Del(key []byte) (affected bool) {
+	now := time.Now().Unix()
+	r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketName)
+		v := b.Get(key)
+		if v == nil {
+			return nil
+		}
+		affected = !isExpired(v, now)
+		return b.Delete(key)
+	})
+	return affected
+}
+
+// Close stops purging and releases the file lock
+func (r *repository) Close() error {
+	close(r.stop)
+	<-r.stopped
+	return r.db.Close()
+}
+
+// purge removes expired entries every interval until Close
+func (r *repository) purge(interval time.Duration) {
+	defer close(r.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.db.Update(func(tx *bolt.Tx) error {
+				return r.purgeExpired(tx.Bucket(bucketName))
+			})
+		}
+	}
+}
+
+func (r *repository) purgeExpired(b *bolt.Bucket) error {
+	var expired [][]byte
+	now := time.Now().Unix()
+	err := b.ForEach(func(k, v []byte) error {
+		if isExpired(v, now) {
+			expired = append(expired, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range expired {
+		if err = b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isExpired(v []byte, now int64) bool {
+	if len(v) < expireAtSize {
+		return true
+	}
+	expireAt := int64(binary.BigEndian.Uint64(v[:expireAtSize]))
+	return expireAt > 0 && expireAt <= now
+}
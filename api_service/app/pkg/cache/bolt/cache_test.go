@@ -0,0 +1,76 @@
+package bolt
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache/cachetest"
+	bolt "go.etcd.io/bbolt"
+)
+
+func newTestRepo(t *testing.T, path string) cache.Repository {
+	repo, err := NewCacheRepo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.(io.Closer).Close() })
+	return repo
+}
+
+func TestRepository(t *testing.T) {
+	cachetest.Run(t, func(t *testing.T) cache.Repository {
+		return newTestRepo(t, filepath.Join(t.TempDir(), "cache.db"))
+	})
+}
+
+// Test scenario:
+// 1. Set some data and close the repository
+// 2. Open the same file again
+// 3. Get data
+func TestRepositoryReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	repo, err := NewCacheRepo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, repo.Set([]byte("key"), []byte("value"), 60))
+	assert.NoError(t, repo.(io.Closer).Close())
+
+	repo = newTestRepo(t, path)
+	entry, err := repo.Get([]byte("key"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, entry, []byte("value"))
+	}
+}
+
+// Test scenario:
+// 1. Set a key expiring soon and never read it
+// 2. The key must be removed from the file once it expires
+func TestRepositoryPurge(t *testing.T) {
+	repo, err := newCacheRepo(filepath.Join(t.TempDir(), "cache.db"), 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	assert.NoError(t, repo.Set([]byte("expiring"), []byte("value"), 1))
+	assert.NoError(t, repo.Set([]byte("key"), []byte("value"), 60))
+
+	stored := func(key string) (ok bool) {
+		repo.db.View(func(tx *bolt.Tx) error {
+			ok = tx.Bucket(bucketName).Get([]byte(key)) != nil
+			return nil
+		})
+		return ok
+	}
+	assert.True(t, stored("expiring"))
+	for deadline := time.Now().Add(3 * time.Second); stored("expiring") && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.False(t, stored("expiring"))
+	assert.True(t, stored("key"))
+}
@@ -0,0 +1,20 @@
+package bolt
+
+import (
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache"
+)
+
+type iterator struct {
+	entries []*cache.Entry
+	pos     int
+}
+
+func (i *iterator) Next() *cache.Entry {
+	if i.pos >= len(i.entries) {
+		return nil
+	}
+	entry := i.entries[i.pos]
+	i.pos++
+
+	return entry
+}
@@ -0,0 +1,163 @@
+// Package cachetest is a conformance test suite every cache.Repository implementation must pass.
+package cachetest
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache"
+)
+
+var uuid = []byte("Lorem ipsum")
+var data = []byte(`Lorem ipsum dolor sit amet, consectetur adipiscing elit. 
+Nulla id tincidunt urna. Proin auctor pretium ornare. Donec vitae felis est.
+Sed sed venenatis ex. Nunc semper vel quam sit amet molestie.
+Quisque lacus tortor, convallis eget ante tristique, interdum auctor massa.
+Praesent a lacus tristique, facilisis tellus eget, malesuada urna.
+Vivamus hendrerit posuere mauris, nec rhoncus turpis suscipit et. Proin at risus ac odio laoreet imperdiet.
+Phasellus ex nulla, sagittis sed tempus maximus, sagittis at dolor.
+Aliquam erat volutpat. In tincidunt eros quis pharetra efficitur. 
+Phasellus facilisis sagittis porta. Curabitur sed dui non ligula malesuada aliquet eget sed lectus. 
+Interdum et malesuada fames ac ante ipsum primis in faucibus. Nunc eget auctor felis. 
+Vestibulum vel metus eu velit molestie hendrerit. Sed pharetra vel arcu et efficitur. 
+Maecenas enim mauris, efficitur venenatis libero sit amet, finibus vulputate est. 
+Sed augue ex, viverra a pretium malesuada, elementum vitae aenean. `)
+
+// Run runs the suite, newRepo must return an empty repository on every call
+func Run(t *testing.T, newRepo func(t *testing.T) cache.Repository) {
+	t.Run("Repository", func(t *testing.T) { testRepository(t, newRepo(t)) })
+	t.Run("Load", func(t *testing.T) { testLoad(t, newRepo(t)) })
+	t.Run("Overwrite", func(t *testing.T) { testOverwrite(t, newRepo(t)) })
+	t.Run("Expiration", func(t *testing.T) { testExpiration(t, newRepo(t)) })
+	t.Run("Iterator", func(t *testing.T) { testIterator(t, newRepo(t)) })
+}
+
+// Test scenario:
+// 1. Set some data
+// 2. Check entry count == 1
+// 3. Get data
+// 4. Check hit count == 1
+// 5. Get data with invalid key
+// 6. Check miss count == 1
+// 7. Delete data using valid key
+// 8. Delete data using invalid key (must fail)
+func testRepository(t *testing.T, repo cache.Repository) {
+	err := repo.Set(uuid, data, -1)
+	assert.NoError(t, err, "failed to set data in cache")
+
+	entryCount := repo.EntryCount()
+	assert.Equal(t, entryCount, int64(1))
+
+	entry, err := repo.Get(uuid)
+	if assert.NoError(t, err, "failed to get entry from cache") {
+		assert.Equal(t, entry, data)
+	}
+
+	hitCount := repo.HitCount()
+	assert.Equal(t, hitCount, int64(1))
+
+	entry, err = repo.Get([]byte("invalid key"))
+	if assert.Error(t, err, "failed to get entry from cache") {
+		assert.Nil(t, entry)
+	}
+
+	missCount := repo.MissCount()
+	assert.Equal(t, missCount, int64(1))
+
+	affected := repo.Del(uuid)
+	assert.Equal(t, affected, true, "failed to delete entry from cache")
+
+	affected = repo.Del([]byte("invalid key"))
+	assert.Equal(t, affected, false)
+}
+
+func testLoad(t *testing.T, repo cache.Repository) {
+	entryNum := 1000
+	keys := [][]byte{}
+	for i := 0; i < entryNum; i++ {
+		keys = append(keys, []byte(strconv.FormatInt(int64(i), 10)))
+	}
+
+	var err error
+
+	for i := 0; i < entryNum; i++ {
+		err = repo.Set(keys[i], data, -1)
+		if err != nil {
+			t.Errorf("failed to set entry to cache: %v", err)
+		}
+	}
+
+	var entry []byte
+
+	for i := 0; i < entryNum; i++ {
+		entry, err = repo.Get(keys[i])
+		if assert.NoError(t, err) {
+			assert.Equal(t, entry, data)
+		}
+	}
+
+	var affected bool
+
+	for i := 0; i < entryNum; i++ {
+		affected = repo.Del(keys[i])
+		assert.Equal(t, affected, true)
+	}
+}
+
+// Test scenario:
+// 1. Set some data
+// 2. Set other data with the same key
+// 3. Get returns the latest data and entry count == 1
+func testOverwrite(t *testing.T, repo cache.Repository) {
+	assert.NoError(t, repo.Set(uuid, data, -1))
+	assert.NoError(t, repo.Set(uuid, []byte("new value"), -1))
+
+	entry, err := repo.Get(uuid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, entry, []byte("new value"))
+	}
+	assert.Equal(t, repo.EntryCount(), int64(1))
+}
+
+// Test scenario:
+// 1. Set data expiring in 1 second and data without expiration
+// 2. Wait until the first one expires
+// 3. Get expired data (must fail), get the other one
+func testExpiration(t *testing.T, repo cache.Repository) {
+	assert.NoError(t, repo.Set([]byte("expiring"), data, 1))
+	assert.NoError(t, repo.Set(uuid, data, 0))
+
+	time.Sleep(2 * time.Second)
+
+	entry, err := repo.Get([]byte("expiring"))
+	if assert.Error(t, err) {
+		assert.Nil(t, entry)
+	}
+	entry, err = repo.Get(uuid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, entry, data)
+	}
+}
+
+// Test scenario:
+// 1. Set several entries
+// 2. Iterate over the cache
+// 3. Check every entry is returned once with its value
+func testIterator(t *testing.T, repo cache.Repository) {
+	keys := []string{"a", "b", "c"}
+	for _, k := range keys {
+		assert.NoError(t, repo.Set([]byte(k), []byte("value "+k), -1))
+	}
+
+	var got []string
+	iter := repo.GetIterator()
+	for entry := iter.Next(); entry != nil; entry = iter.Next() {
+		assert.Equal(t, entry.Value, []byte("value "+string(entry.Key)))
+		got = append(got, string(entry.Key))
+	}
+	sort.Strings(got)
+	assert.Equal(t, got, keys)
+}
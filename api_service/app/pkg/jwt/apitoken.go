@@ -0,0 +1,75 @@
+package jwt
+
+import (
+	"context"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/user_service"
+	"net/http"
+	"strings"
+)
+
+// APITokenPrefix marks personal access tokens, they are checked by user_service instead of signature
+const APITokenPrefix = "nst_"
+
+type APITokenAuthenticator interface {
+	AuthenticateAPIToken(ctx context.Context, token string) (user_service.APIToken, error)
+}
+
+var apiTokens APITokenAuthenticator
+
+// SetAPITokenAuthenticator enables personal access tokens in Middleware
+func SetAPITokenAuthenticator(a APITokenAuthenticator) {
+	apiTokens = a
+}
+
+// apiTokenMiddleware authenticates a personal access token. Tokens are scoped to resources,
+// the resource is taken from the path (/api/<resource>/...) and everything else is denied,
+// so tokens can't be used to manage sessions, tokens or users.
+func apiTokenMiddleware(h http.HandlerFunc, w http.ResponseWriter, r *http.Request, rawToken string) {
+	if apiTokens == nil {
+		unauthorized(w, apperror.UnauthorizedError("personal access tokens are disabled"))
+		return
+	}
+	t, err := apiTokens.AuthenticateAPIToken(r.Context(), rawToken)
+	if err != nil {
+		unauthorized(w, err)
+		return
+	}
+	if !ScopeAllows(t.Scopes, r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write(apperror.ErrForbidden.Marshal())
+		return
+	}
+
+	roles := t.Roles
+	if len(roles) == 0 {
+		roles = []string{RoleUser}
+	}
+	ctx := context.WithValue(r.Context(), "user_uuid", t.UserUUID)
+	ctx = context.WithValue(ctx, "api_token_id", t.ID)
+	ctx = context.WithValue(ctx, "roles", roles)
+	h(w, r.WithContext(ctx))
+}
+
+// ScopeAllows reports whether scopes grant access to the resource of the request,
+// safe methods need <resource>:read or <resource>:write, others need <resource>:write
+func ScopeAllows(scopes []string, r *http.Request) bool {
+	segments := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if len(segments) < 2 || segments[0] != "api" {
+		return false
+	}
+	resource := segments[1]
+
+	write := true
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		write = false
+	}
+	for _, scope := range scopes {
+		if scope == resource+":write" || (!write && scope == resource+":read") {
+			return true
+		}
+	}
+	return false
+}
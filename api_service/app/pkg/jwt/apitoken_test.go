@@ -0,0 +1,30 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		method string
+		path   string
+		allow  bool
+	}{
+		{[]string{"notes:read"}, http.MethodGet, "/api/notes/1", true},
+		{[]string{"notes:read"}, http.MethodPost, "/api/notes", false},
+		{[]string{"notes:write"}, http.MethodGet, "/api/notes", true},
+		{[]string{"notes:write"}, http.MethodDelete, "/api/notes/1", true},
+		{[]string{"notes:write"}, http.MethodGet, "/api/tags", false},
+		{[]string{"notes:write"}, http.MethodGet, "/metric", false},
+		{nil, http.MethodGet, "/api/notes", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		assert.Equal(t, tt.allow, ScopeAllows(tt.scopes, req), "%v %s %s", tt.scopes, tt.method, tt.path)
+	}
+}
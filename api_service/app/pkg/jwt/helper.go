@@ -1,89 +1,317 @@
 package jwt
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/cristalhq/jwt/v3"
 	"github.com/google/uuid"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
 	"github.com/theartofdevel/notes_system/api_service/internal/client/user_service"
-	"github.com/theartofdevel/notes_system/api_service/internal/config"
 	"github.com/theartofdevel/notes_system/api_service/pkg/cache"
 	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+	"sort"
+	"sync"
 	"time"
 )
 
 var _ Helper = &helper{}
 
+const (
+	AccessTokenTTL = time.Hour
+	// MFATokenTTL is how long the user has to enter a 2FA code after password check
+	MFATokenTTL = 5 * time.Minute
+
+	accessTokenAudience = "users"
+	mfaTokenAudience    = "mfa"
+)
+
+const (
+	refreshTokenKeyPrefix = "rt:"
+	sessionKeyPrefix      = "session:"
+	// userSessionsKeyPrefix keys ids of sessions of the user, some of them may be expired or revoked already
+	userSessionsKeyPrefix = "user_sessions:"
+
+	// refreshUserTimeout limits loading of the user when tokens are refreshed
+	refreshUserTimeout = 5 * time.Second
+)
+
+// UserGetter loads the user on refresh, so roles changed and users deleted since login are seen by new tokens
+type UserGetter interface {
+	GetByUUID(ctx context.Context, uuid string) (user_service.User, error)
+}
+
 type UserClaims struct {
 	jwt.RegisteredClaims
-	Email string `json:"email"`
+	Email     string   `json:"email"`
+	Roles     []string `json:"roles,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
 }
 
 type RT struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// MFAChallenge exchanges a token issued after password check and a 2FA code for tokens
+type MFAChallenge struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+// SessionMeta describes a client the tokens are issued to
+type SessionMeta struct {
+	UserAgent string
+	IP        string
+}
+
+// Session is a family of refresh tokens issued after a single login.
+// Only the latest token of the family is valid, presenting an older one revokes the whole session.
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	// Current is set by GetUserSessions for the session the request was made from
+	Current bool `json:"current"`
+}
+
+// sessionRecord is Session as it is stored in cache
+type sessionRecord struct {
+	Session
+	User         user_service.User `json:"user"`
+	CurrentToken string            `json:"current_token"`
+}
+
 type helper struct {
-	Logger  logging.Logger
-	RTCache cache.Repository
+	sync.Mutex
+	Logger     logging.Logger
+	Keys       KeySet
+	RTCache    cache.Repository
+	Users      UserGetter
+	RefreshTTL time.Duration
 }
 
-func NewHelper(RTCache cache.Repository, logger logging.Logger) Helper {
-	return &helper{RTCache: RTCache, Logger: logger}
+func NewHelper(keys KeySet, RTCache cache.Repository, users UserGetter, refreshTTL time.Duration, logger logging.Logger) Helper {
+	return &helper{Keys: keys, RTCache: RTCache, Users: users, RefreshTTL: refreshTTL, Logger: logger}
 }
 
 type Helper interface {
-	GenerateAccessToken(u user_service.User) ([]byte, error)
-	UpdateRefreshToken(rt RT) ([]byte, error)
+	GenerateAccessToken(u user_service.User, meta SessionMeta) ([]byte, error)
+	UpdateRefreshToken(ctx context.Context, rt RT, meta SessionMeta) ([]byte, error)
+	GetUserSessions(userUUID, currentSessionID string) []Session
+	RevokeSession(userUUID, sessionID string) error
+	RevokeUserRefreshTokens(userUUID string) int
+	// GenerateMFAToken issues a challenge token which can only be exchanged for tokens with a valid 2FA code
+	GenerateMFAToken(u user_service.User) ([]byte, error)
+	// ParseMFAToken returns uuid of the user the challenge token was issued to
+	ParseMFAToken(token string) (string, error)
 }
 
-func (h *helper) UpdateRefreshToken(rt RT) ([]byte, error) {
-	defer h.RTCache.Del([]byte(rt.RefreshToken))
-
-	userBytes, err := h.RTCache.Get([]byte(rt.RefreshToken))
+func (h *helper) UpdateRefreshToken(ctx context.Context, rt RT, meta SessionMeta) ([]byte, error) {
+	s, err := h.currentSession(rt)
 	if err != nil {
 		return nil, err
 	}
-	var u user_service.User
-	err = json.Unmarshal(userBytes, &u)
+
+	// the user is loaded without the lock, the session is checked again after that
+	ctx, cancel := context.WithTimeout(ctx, refreshUserTimeout)
+	defer cancel()
+	u, err := h.Users.GetByUUID(ctx, s.User.UUID)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			h.Logger.Infof("user %s is deleted, session %s revoked", s.User.UUID, s.ID)
+			h.RevokeUserRefreshTokens(s.User.UUID)
+			return nil, apperror.UnauthorizedError("user does not exist")
+		}
+		return nil, fmt.Errorf("failed to get user. error: %w", err)
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	s, err = h.checkSession(rt)
 	if err != nil {
 		return nil, err
 	}
-	return h.GenerateAccessToken(u)
+	s.User = u
+	s.UserAgent = meta.UserAgent
+	s.IP = meta.IP
+	return h.issueTokens(s)
+}
+
+func (h *helper) currentSession(rt RT) (sessionRecord, error) {
+	h.Lock()
+	defer h.Unlock()
+	return h.checkSession(rt)
 }
 
-func (h *helper) GenerateAccessToken(u user_service.User) ([]byte, error) {
-	key := []byte(config.GetConfig().JWT.Secret)
-	signer, err := jwt.NewSignerHS(jwt.HS256, key)
+// checkSession returns the session of the refresh token if the token is the latest one of the session,
+// presenting an older token revokes the session
+func (h *helper) checkSession(rt RT) (s sessionRecord, err error) {
+	sessionID, err := h.RTCache.Get([]byte(refreshTokenKeyPrefix + rt.RefreshToken))
 	if err != nil {
-		return nil, err
+		return s, apperror.UnauthorizedError("invalid refresh token")
+	}
+	s, err = h.getSession(string(sessionID))
+	if err != nil {
+		return s, apperror.UnauthorizedError("session expired or revoked")
 	}
-	builder := jwt.NewBuilder(signer)
+	if s.CurrentToken != rt.RefreshToken {
+		h.Logger.Warnf("refresh token reuse detected, session %s of user %s revoked", s.ID, s.User.UUID)
+		h.deleteSession(s.User.UUID, s.ID)
+		return s, apperror.UnauthorizedError("refresh token has already been used")
+	}
+	return s, nil
+}
+
+func (h *helper) GenerateAccessToken(u user_service.User, meta SessionMeta) ([]byte, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	now := time.Now().UTC()
+	s := sessionRecord{
+		Session: Session{
+			ID:        uuid.New().String(),
+			UserAgent: meta.UserAgent,
+			IP:        meta.IP,
+			CreatedAt: now,
+		},
+		User: u,
+	}
+	return h.issueTokens(s)
+}
 
+// GetUserSessions returns active sessions of the user
+func (h *helper) GetUserSessions(userUUID, currentSessionID string) []Session {
+	h.Lock()
+	defer h.Unlock()
+
+	sessions := []Session{}
+	for _, s := range h.findSessions(userUUID) {
+		s.Current = s.ID == currentSessionID
+		sessions = append(sessions, s.Session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions
+}
+
+// RevokeSession invalidates all refresh tokens of the session
+func (h *helper) RevokeSession(userUUID, sessionID string) error {
+	h.Lock()
+	defer h.Unlock()
+
+	s, err := h.getSession(sessionID)
+	if err != nil || s.User.UUID != userUUID {
+		return apperror.ErrNotFound
+	}
+	h.deleteSession(userUUID, sessionID)
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes all sessions of the user and returns their count
+func (h *helper) RevokeUserRefreshTokens(userUUID string) int {
+	h.Lock()
+	defer h.Unlock()
+
+	var revoked int
+	for _, s := range h.findSessions(userUUID) {
+		if h.RTCache.Del([]byte(sessionKeyPrefix + s.ID)) {
+			revoked++
+		}
+	}
+	h.RTCache.Del([]byte(userSessionsKeyPrefix + userUUID))
+	return revoked
+}
+
+func (h *helper) GenerateMFAToken(u user_service.User) ([]byte, error) {
+	signer, kid, err := h.Keys.Signer()
+	if err != nil {
+		return nil, err
+	}
 	claims := UserClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        u.UUID,
-			Audience:  []string{"users"},
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 60)),
+			Audience:  []string{mfaTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(MFATokenTTL)),
 		},
 		Email: u.Email,
 	}
+	token, err := jwt.NewBuilder(signer, jwt.WithKeyID(kid)).Build(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"mfa_required": true,
+		"mfa_token":    token.String(),
+	})
+}
+
+func (h *helper) ParseMFAToken(token string) (string, error) {
+	uc, err := parseToken(h.Keys, token, mfaTokenAudience)
+	if err != nil {
+		h.Logger.Error(err)
+		return "", apperror.UnauthorizedError("invalid or expired mfa token")
+	}
+	return uc.ID, nil
+}
+
+func (h *helper) issueTokens(s sessionRecord) ([]byte, error) {
+	signer, kid, err := h.Keys.Signer()
+	if err != nil {
+		return nil, err
+	}
+	builder := jwt.NewBuilder(signer, jwt.WithKeyID(kid))
+
+	claims := UserClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        s.User.UUID,
+			Audience:  []string{accessTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
+		},
+		Email:     s.User.Email,
+		Roles:     s.User.Roles,
+		SessionID: s.ID,
+	}
 	token, err := builder.Build(claims)
 	if err != nil {
 		return nil, err
 	}
 
 	h.Logger.Info("create refresh token")
-	refreshTokenUuid := uuid.New()
-	userBytes, _ := json.Marshal(u)
-	err = h.RTCache.Set([]byte(refreshTokenUuid.String()), userBytes, 0)
+	refreshToken := uuid.New().String()
+	ttl := int(h.RefreshTTL.Seconds())
+
+	// previous tokens stay in cache until they expire to detect their reuse
+	err = h.RTCache.Set([]byte(refreshTokenKeyPrefix+refreshToken), []byte(s.ID), ttl)
 	if err != nil {
 		h.Logger.Error(err)
 		return nil, err
 	}
 
+	s.CurrentToken = refreshToken
+	s.LastUsedAt = time.Now().UTC()
+	sessionBytes, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	err = h.RTCache.Set([]byte(sessionKeyPrefix+s.ID), sessionBytes, ttl)
+	if err != nil {
+		h.Logger.Error(err)
+		return nil, err
+	}
+	if err = h.indexSession(s.User.UUID, s.ID); err != nil {
+		h.Logger.Error(err)
+		return nil, err
+	}
+
 	jsonBytes, err := json.Marshal(map[string]string{
 		"token":         token.String(),
-		"refresh_token": refreshTokenUuid.String(),
+		"refresh_token": refreshToken,
 	})
 	if err != nil {
 		return nil, err
@@ -91,3 +319,86 @@ func (h *helper) GenerateAccessToken(u user_service.User) ([]byte, error) {
 
 	return jsonBytes, nil
 }
+
+func (h *helper) getSession(sessionID string) (s sessionRecord, err error) {
+	sessionBytes, err := h.RTCache.Get([]byte(sessionKeyPrefix + sessionID))
+	if err != nil {
+		return s, err
+	}
+	if err = json.Unmarshal(sessionBytes, &s); err != nil {
+		return s, fmt.Errorf("failed to unmarshal session. error: %w", err)
+	}
+	return s, nil
+}
+
+// findSessions returns active sessions of the user, ids of expired and revoked ones are removed from the index
+func (h *helper) findSessions(userUUID string) []sessionRecord {
+	var sessions []sessionRecord
+	ids := h.getSessionIDs(userUUID)
+	active := make([]string, 0, len(ids))
+	for _, id := range ids {
+		s, err := h.getSession(id)
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, s)
+		active = append(active, id)
+	}
+	if len(active) != len(ids) {
+		if err := h.setSessionIDs(userUUID, active); err != nil {
+			h.Logger.Error(err)
+		}
+	}
+	return sessions
+}
+
+// indexSession adds the session to the index of the user, the index lives as long as the latest session
+func (h *helper) indexSession(userUUID, sessionID string) error {
+	ids := h.getSessionIDs(userUUID)
+	for _, id := range ids {
+		if id == sessionID {
+			return h.setSessionIDs(userUUID, ids)
+		}
+	}
+	return h.setSessionIDs(userUUID, append(ids, sessionID))
+}
+
+// deleteSession revokes the session and removes it from the index of the user
+func (h *helper) deleteSession(userUUID, sessionID string) {
+	h.RTCache.Del([]byte(sessionKeyPrefix + sessionID))
+	ids := h.getSessionIDs(userUUID)
+	for i, id := range ids {
+		if id == sessionID {
+			if err := h.setSessionIDs(userUUID, append(ids[:i], ids[i+1:]...)); err != nil {
+				h.Logger.Error(err)
+			}
+			return
+		}
+	}
+}
+
+func (h *helper) getSessionIDs(userUUID string) []string {
+	idsBytes, err := h.RTCache.Get([]byte(userSessionsKeyPrefix + userUUID))
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	if err = json.Unmarshal(idsBytes, &ids); err != nil {
+		h.Logger.Errorf("failed to unmarshal sessions of user %s. error: %v", userUUID, err)
+		return nil
+	}
+	return ids
+}
+
+func (h *helper) setSessionIDs(userUUID string, ids []string) error {
+	key := []byte(userSessionsKeyPrefix + userUUID)
+	if len(ids) == 0 {
+		h.RTCache.Del(key)
+		return nil
+	}
+	idsBytes, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	return h.RTCache.Set(key, idsBytes, int(h.RefreshTTL.Seconds()))
+}
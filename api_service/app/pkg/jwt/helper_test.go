@@ -0,0 +1,127 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/api_service/internal/client/user_service"
+	"github.com/theartofdevel/notes_system/api_service/pkg/cache/freecache"
+)
+
+// fakeUsers returns users by uuid, missing users are not found
+type fakeUsers struct {
+	sync.Mutex
+	users map[string]user_service.User
+}
+
+func (f *fakeUsers) GetByUUID(_ context.Context, uuid string) (user_service.User, error) {
+	f.Lock()
+	defer f.Unlock()
+	u, ok := f.users[uuid]
+	if !ok {
+		return u, apperror.ErrNotFound
+	}
+	return u, nil
+}
+
+func (f *fakeUsers) set(u user_service.User) {
+	f.Lock()
+	defer f.Unlock()
+	f.users[u.UUID] = u
+}
+
+func (f *fakeUsers) delete(uuid string) {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.users, uuid)
+}
+
+type issuedTokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func newTestHelper(t *testing.T, users ...user_service.User) (*helper, *fakeUsers) {
+	fake := &fakeUsers{users: make(map[string]user_service.User)}
+	for _, u := range users {
+		fake.set(u)
+	}
+	keys := newTestKeySet(t, KeySetConfig{Algorithm: "EdDSA", Dir: t.TempDir(), Rotation: time.Hour, Retention: time.Hour})
+	h := NewHelper(keys, freecache.NewCacheRepo(1048576), fake, time.Hour, testLogger).(*helper)
+	return h, fake
+}
+
+func decodeTokens(t *testing.T, tokenBytes []byte) issuedTokens {
+	var tokens issuedTokens
+	require.NoError(t, json.Unmarshal(tokenBytes, &tokens))
+	return tokens
+}
+
+func login(t *testing.T, h *helper, u user_service.User) issuedTokens {
+	tokenBytes, err := h.GenerateAccessToken(u, SessionMeta{UserAgent: "test"})
+	require.NoError(t, err)
+	return decodeTokens(t, tokenBytes)
+}
+
+// Test scenario:
+// 1. Sessions of the user are listed from the index, sessions of other users are not
+// 2. Revoked session is removed from the index, revoking all sessions removes the index
+func TestUserSessionsIndex(t *testing.T) {
+	jane := user_service.User{UUID: "jane", Email: "jane@example.com"}
+	john := user_service.User{UUID: "john", Email: "john@example.com"}
+	h, _ := newTestHelper(t, jane, john)
+
+	login(t, h, jane)
+	login(t, h, jane)
+	login(t, h, john)
+
+	sessions := h.GetUserSessions(jane.UUID, "")
+	require.Len(t, sessions, 2)
+	assert.Equal(t, []string{sessions[1].ID, sessions[0].ID}, h.getSessionIDs(jane.UUID))
+
+	require.NoError(t, h.RevokeSession(jane.UUID, sessions[0].ID))
+	assert.Equal(t, []string{sessions[1].ID}, h.getSessionIDs(jane.UUID))
+	assert.Equal(t, apperror.ErrNotFound, h.RevokeSession(john.UUID, sessions[1].ID))
+
+	assert.Equal(t, 1, h.RevokeUserRefreshTokens(jane.UUID))
+	assert.Empty(t, h.GetUserSessions(jane.UUID, ""))
+	assert.Nil(t, h.getSessionIDs(jane.UUID))
+	assert.Len(t, h.GetUserSessions(john.UUID, ""), 1)
+}
+
+// Test scenario:
+// 1. Refreshed access token has roles the user has now
+// 2. Reuse of an old refresh token revokes the session
+// 3. Refresh of a deleted user fails and revokes the sessions
+func TestUpdateRefreshTokenReloadsUser(t *testing.T) {
+	jane := user_service.User{UUID: "jane", Email: "jane@example.com", Roles: []string{RoleUser}}
+	h, users := newTestHelper(t, jane)
+	ctx := context.Background()
+
+	tokens := login(t, h, jane)
+	jane.Roles = []string{RoleReadOnly}
+	users.set(jane)
+
+	tokenBytes, err := h.UpdateRefreshToken(ctx, RT{RefreshToken: tokens.RefreshToken}, SessionMeta{})
+	require.NoError(t, err)
+	refreshed := decodeTokens(t, tokenBytes)
+	claims, err := parseToken(h.Keys, refreshed.Token, accessTokenAudience)
+	require.NoError(t, err)
+	assert.Equal(t, []string{RoleReadOnly}, claims.Roles)
+
+	_, err = h.UpdateRefreshToken(ctx, RT{RefreshToken: tokens.RefreshToken}, SessionMeta{})
+	assert.Error(t, err)
+	assert.Empty(t, h.GetUserSessions(jane.UUID, ""))
+
+	tokens = login(t, h, jane)
+	users.delete(jane.UUID)
+	_, err = h.UpdateRefreshToken(ctx, RT{RefreshToken: tokens.RefreshToken}, SessionMeta{})
+	assert.Error(t, err)
+	assert.Empty(t, h.GetUserSessions(jane.UUID, ""))
+}
@@ -0,0 +1,342 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/cristalhq/jwt/v3"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ KeySet = &keySet{}
+
+const (
+	keyFileExt       = ".pem"
+	createdAtHeader  = "Created-At"
+	rsaKeyBits       = 2048
+	rotationInterval = time.Minute
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is a signing key pair, the public part is used for verification
+type Key struct {
+	ID        string
+	Algorithm jwt.Algorithm
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type KeySetConfig struct {
+	// Algorithm of new keys, RS256 or EdDSA
+	Algorithm string
+	// Dir keeps keys between restarts, replicas sharing it share keys
+	Dir string
+	// Rotation is a signing key lifetime
+	Rotation time.Duration
+	// Retention is how long a key verifies tokens after it was rotated out,
+	// it must not be shorter than access token lifetime
+	Retention time.Duration
+}
+
+type KeySet interface {
+	// Signer returns a signer of the newest key and its kid
+	Signer() (jwt.Signer, string, error)
+	// Verifier returns a verifier of the key with the given kid
+	Verifier(kid string) (jwt.Verifier, error)
+	// JWKS returns public parts of all keys tokens can be verified with
+	JWKS() JWKS
+	// Rotate generates a new signing key and removes outdated ones
+	Rotate() error
+	// RunRotation rotates keys on schedule until stop is closed
+	RunRotation(stop <-chan struct{})
+}
+
+type keySet struct {
+	sync.RWMutex
+	cfg    KeySetConfig
+	alg    jwt.Algorithm
+	keys   []*Key // newest first
+	logger logging.Logger
+}
+
+func NewKeySet(cfg KeySetConfig, logger logging.Logger) (KeySet, error) {
+	alg := jwt.Algorithm(cfg.Algorithm)
+	if alg != jwt.RS256 && alg != jwt.EdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm %s", cfg.Algorithm)
+	}
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create keys dir. error: %w", err)
+	}
+	ks := &keySet{cfg: cfg, alg: alg, logger: logger}
+	if err := ks.rotateIfNeeded(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+func (ks *keySet) Signer() (jwt.Signer, string, error) {
+	ks.RLock()
+	defer ks.RUnlock()
+
+	if len(ks.keys) == 0 {
+		return nil, "", ErrUnknownKey
+	}
+	k := ks.keys[0]
+	var signer jwt.Signer
+	var err error
+	switch priv := k.Private.(type) {
+	case ed25519.PrivateKey:
+		signer, err = jwt.NewSignerEdDSA(priv)
+	case *rsa.PrivateKey:
+		signer, err = jwt.NewSignerRS(jwt.RS256, priv)
+	default:
+		err = ErrUnknownKey
+	}
+	return signer, k.ID, err
+}
+
+func (ks *keySet) Verifier(kid string) (jwt.Verifier, error) {
+	ks.RLock()
+	defer ks.RUnlock()
+
+	for _, k := range ks.keys {
+		if k.ID != kid {
+			continue
+		}
+		switch pub := k.Private.Public().(type) {
+		case ed25519.PublicKey:
+			return jwt.NewVerifierEdDSA(pub)
+		case *rsa.PublicKey:
+			return jwt.NewVerifierRS(jwt.RS256, pub)
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+func (ks *keySet) JWKS() JWKS {
+	ks.RLock()
+	defer ks.RUnlock()
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, k := range ks.keys {
+		jwk := JWK{Use: "sig", Alg: k.Algorithm.String(), Kid: k.ID}
+		switch pub := k.Private.Public().(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+func (ks *keySet) Rotate() error {
+	ks.Lock()
+	defer ks.Unlock()
+
+	if err := ks.load(); err != nil {
+		return err
+	}
+	if err := ks.generate(); err != nil {
+		return err
+	}
+	ks.prune()
+	return nil
+}
+
+func (ks *keySet) RunRotation(stop <-chan struct{}) {
+	ticker := time.NewTicker(rotationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := ks.rotateIfNeeded(); err != nil {
+				ks.logger.Errorf("failed to rotate signing keys: %v", err)
+			}
+		}
+	}
+}
+
+// rotateIfNeeded reloads keys from disk to pick up ones created by other replicas
+// and generates a new key when the newest one is older than rotation interval
+func (ks *keySet) rotateIfNeeded() error {
+	ks.Lock()
+	defer ks.Unlock()
+
+	if err := ks.load(); err != nil {
+		return err
+	}
+	if len(ks.keys) == 0 || ks.keys[0].Algorithm != ks.alg ||
+		time.Since(ks.keys[0].CreatedAt) >= ks.cfg.Rotation {
+		if err := ks.generate(); err != nil {
+			return err
+		}
+	}
+	ks.prune()
+	return nil
+}
+
+func (ks *keySet) generate() error {
+	ks.logger.Info("generate new signing key")
+	var priv crypto.Signer
+	var err error
+	switch ks.alg {
+	case jwt.EdDSA:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	case jwt.RS256:
+		priv, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate key. error: %w", err)
+	}
+
+	kidBytes := make([]byte, 12)
+	if _, err = rand.Read(kidBytes); err != nil {
+		return err
+	}
+	k := &Key{
+		ID:        base64.RawURLEncoding.EncodeToString(kidBytes),
+		Algorithm: ks.alg,
+		Private:   priv,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return fmt.Errorf("failed to marshal key. error: %w", err)
+	}
+	block := &pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{createdAtHeader: k.CreatedAt.Format(time.RFC3339)},
+		Bytes:   der,
+	}
+	// write to a temp file first so other replicas never read a partial key
+	tmpPath := filepath.Join(ks.cfg.Dir, "."+k.ID)
+	if err = ioutil.WriteFile(tmpPath, pem.EncodeToMemory(block), 0600); err != nil {
+		return fmt.Errorf("failed to write key. error: %w", err)
+	}
+	if err = os.Rename(tmpPath, ks.keyPath(k.ID)); err != nil {
+		return fmt.Errorf("failed to write key. error: %w", err)
+	}
+
+	ks.keys = append([]*Key{k}, ks.keys...)
+	return nil
+}
+
+// prune removes keys which were rotated out longer than retention ago
+func (ks *keySet) prune() {
+	for i := 1; i < len(ks.keys); i++ {
+		retiredAt := ks.keys[i-1].CreatedAt
+		if time.Since(retiredAt) < ks.cfg.Retention {
+			continue
+		}
+		for _, k := range ks.keys[i:] {
+			ks.logger.Infof("remove signing key %s", k.ID)
+			if err := os.Remove(ks.keyPath(k.ID)); err != nil && !os.IsNotExist(err) {
+				ks.logger.Error(err)
+			}
+		}
+		ks.keys = ks.keys[:i]
+		return
+	}
+}
+
+func (ks *keySet) load() error {
+	files, err := ioutil.ReadDir(ks.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read keys dir. error: %w", err)
+	}
+
+	var keys []*Key
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != keyFileExt {
+			continue
+		}
+		k, err := ks.readKey(strings.TrimSuffix(f.Name(), keyFileExt))
+		if err != nil {
+			ks.logger.Errorf("skip key file %s: %v", f.Name(), err)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	ks.keys = keys
+	return nil
+}
+
+func (ks *keySet) readKey(kid string) (*Key, error) {
+	data, err := ioutil.ReadFile(ks.keyPath(kid))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	createdAt, err := time.Parse(time.RFC3339, block.Headers[createdAtHeader])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s header. error: %w", createdAtHeader, err)
+	}
+	priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Key{ID: kid, CreatedAt: createdAt}
+	switch priv := priv.(type) {
+	case ed25519.PrivateKey:
+		k.Algorithm = jwt.EdDSA
+		k.Private = priv
+	case *rsa.PrivateKey:
+		k.Algorithm = jwt.RS256
+		k.Private = priv
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", priv)
+	}
+	return k, nil
+}
+
+func (ks *keySet) keyPath(kid string) string {
+	return filepath.Join(ks.cfg.Dir, kid+keyFileExt)
+}
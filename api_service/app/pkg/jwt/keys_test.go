@@ -0,0 +1,92 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cristalhq/jwt/v3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+)
+
+var testLogger = logging.Logger{Entry: logrus.NewEntry(logrus.New())}
+
+func newTestKeySet(t *testing.T, cfg KeySetConfig) KeySet {
+	ks, err := NewKeySet(cfg, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+func sign(t *testing.T, ks KeySet) (*jwt.Token, string) {
+	signer, kid, err := ks.Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.NewBuilder(signer, jwt.WithKeyID(kid)).Build(jwt.RegisteredClaims{ID: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token, kid
+}
+
+func verify(ks KeySet, token *jwt.Token) error {
+	verifier, err := ks.Verifier(token.Header().KeyID)
+	if err != nil {
+		return err
+	}
+	return verifier.Verify(token.Payload(), token.Signature())
+}
+
+// Test scenario:
+// 1. Sign a token with a new key set
+// 2. Rotate keys, tokens are signed with a new kid
+// 3. Old token is still verified and both keys are published
+// 4. Key set opened from the same dir verifies both tokens
+func TestKeySetRotation(t *testing.T) {
+	for _, alg := range []string{"EdDSA", "RS256"} {
+		t.Run(alg, func(t *testing.T) {
+			cfg := KeySetConfig{Algorithm: alg, Dir: t.TempDir(), Rotation: time.Hour, Retention: time.Hour}
+			ks := newTestKeySet(t, cfg)
+
+			oldToken, oldKid := sign(t, ks)
+			assert.NoError(t, verify(ks, oldToken))
+
+			assert.NoError(t, ks.Rotate())
+			newToken, newKid := sign(t, ks)
+			assert.NotEqual(t, oldKid, newKid)
+			assert.NoError(t, verify(ks, oldToken))
+			assert.NoError(t, verify(ks, newToken))
+
+			jwks := ks.JWKS()
+			if assert.Len(t, jwks.Keys, 2) {
+				assert.Equal(t, newKid, jwks.Keys[0].Kid)
+				assert.Equal(t, alg, jwks.Keys[0].Alg)
+			}
+
+			reopened := newTestKeySet(t, cfg)
+			assert.NoError(t, verify(reopened, oldToken))
+			assert.NoError(t, verify(reopened, newToken))
+		})
+	}
+}
+
+// Test scenario:
+// 1. Sign a token, rotate keys with zero retention
+// 2. Old token can not be verified anymore
+func TestKeySetRetention(t *testing.T) {
+	ks := newTestKeySet(t, KeySetConfig{Algorithm: "EdDSA", Dir: t.TempDir(), Rotation: time.Hour})
+
+	oldToken, _ := sign(t, ks)
+	assert.NoError(t, ks.Rotate())
+
+	assert.Equal(t, ErrUnknownKey, verify(ks, oldToken))
+	assert.Len(t, ks.JWKS().Keys, 1)
+}
+
+func TestKeySetUnsupportedAlgorithm(t *testing.T) {
+	_, err := NewKeySet(KeySetConfig{Algorithm: "HS256", Dir: t.TempDir()}, testLogger)
+	assert.Error(t, err)
+}
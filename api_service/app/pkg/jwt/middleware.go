@@ -3,14 +3,21 @@ package jwt
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/cristalhq/jwt/v3"
-	"github.com/theartofdevel/notes_system/api_service/internal/config"
 	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
 	"net/http"
 	"strings"
 	"time"
 )
 
+var keys KeySet
+
+// SetKeySet sets keys Middleware verifies tokens with
+func SetKeySet(ks KeySet) {
+	keys = ks
+}
+
 func Middleware(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := logging.GetLogger()
@@ -21,39 +28,59 @@ func Middleware(h http.HandlerFunc) http.HandlerFunc {
 			w.Write([]byte("malformed token"))
 			return
 		}
-		logger.Debug("create jwt verifier")
-		jwtToken := authHeader[1]
-		key := []byte(config.GetConfig().JWT.Secret)
-		verifier, err := jwt.NewVerifierHS(jwt.HS256, key)
-		if err != nil {
-			unauthorized(w, err)
+		if strings.HasPrefix(authHeader[1], APITokenPrefix) {
+			logger.Debug("authenticate personal access token")
+			apiTokenMiddleware(h, w, r, authHeader[1])
 			return
 		}
+
 		logger.Debug("parse and verify token")
-		token, err := jwt.ParseAndVerifyString(jwtToken, verifier)
+		uc, err := parseToken(keys, authHeader[1], accessTokenAudience)
 		if err != nil {
 			unauthorized(w, err)
 			return
 		}
 
-		logger.Debug("parse user claims")
-		var uc UserClaims
-		err = json.Unmarshal(token.RawClaims(), &uc)
-		if err != nil {
-			unauthorized(w, err)
-			return
-		}
-		if valid := uc.IsValidAt(time.Now()); !valid {
-			logger.Error("token has been expired")
-			unauthorized(w, err)
-			return
-		}
-
 		ctx := context.WithValue(r.Context(), "user_uuid", uc.ID)
+		ctx = context.WithValue(ctx, "session_id", uc.SessionID)
+		if len(uc.Roles) == 0 {
+			// tokens issued before roles were introduced
+			uc.Roles = []string{RoleUser}
+		}
+		ctx = context.WithValue(ctx, "roles", uc.Roles)
 		h(w, r.WithContext(ctx))
 	}
 }
 
+// parseToken verifies signature with the key from kid header and checks expiration and audience
+func parseToken(ks KeySet, rawToken, audience string) (uc UserClaims, err error) {
+	token, err := jwt.ParseString(rawToken)
+	if err != nil {
+		return uc, err
+	}
+	verifier, err := ks.Verifier(token.Header().KeyID)
+	if err != nil {
+		return uc, err
+	}
+	if token.Header().Algorithm != verifier.Algorithm() {
+		return uc, fmt.Errorf("unexpected signing algorithm %s", token.Header().Algorithm)
+	}
+	if err = verifier.Verify(token.Payload(), token.Signature()); err != nil {
+		return uc, err
+	}
+
+	if err = json.Unmarshal(token.RawClaims(), &uc); err != nil {
+		return uc, err
+	}
+	if !uc.IsValidAt(time.Now()) {
+		return uc, fmt.Errorf("token has been expired")
+	}
+	if !uc.IsForAudience(audience) {
+		return uc, fmt.Errorf("token is not for %s audience", audience)
+	}
+	return uc, nil
+}
+
 func unauthorized(w http.ResponseWriter, err error) {
 	logging.GetLogger().Error(err)
 	w.WriteHeader(http.StatusUnauthorized)
@@ -0,0 +1,43 @@
+package jwt
+
+import (
+	"github.com/theartofdevel/notes_system/api_service/internal/apperror"
+	"net/http"
+)
+
+const (
+	RoleUser     = "user"
+	RoleAdmin    = "admin"
+	RoleReadOnly = "read-only"
+)
+
+// RequireRole lets the request through if the user has any of the roles.
+// It reads roles put in context by Middleware, so it must be wrapped by it:
+//
+//	jwt.Middleware(jwt.RequireRole(jwt.RoleAdmin)(apperror.Middleware(h.Handle)))
+func RequireRole(roles ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			userRoles, _ := r.Context().Value("roles").([]string)
+			for _, role := range roles {
+				if HasRole(userRoles, role) {
+					h(w, r)
+					return
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write(apperror.ErrForbidden.Marshal())
+		}
+	}
+}
+
+// HasRole reports whether role is in roles
+func HasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
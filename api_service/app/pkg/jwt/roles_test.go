@@ -0,0 +1,32 @@
+package jwt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRole(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	handler := RequireRole(RoleUser, RoleAdmin)(ok)
+
+	tests := []struct {
+		roles  []string
+		status int
+	}{
+		{[]string{RoleUser}, http.StatusOK},
+		{[]string{RoleAdmin}, http.StatusOK},
+		{[]string{RoleReadOnly}, http.StatusForbidden},
+		{nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "roles", tt.roles))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		assert.Equal(t, tt.status, rec.Code, "roles %v", tt.roles)
+	}
+}
@@ -0,0 +1,379 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cristalhq/jwt/v3"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ Provider = &provider{}
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// clockSkew is tolerated between this service and the provider when checking token lifetime
+	clockSkew = time.Minute
+	// keysRefetchInterval limits how often unknown kid makes provider keys to be refetched
+	keysRefetchInterval = time.Minute
+)
+
+var ErrUnknownKey = errors.New("unknown key of identity provider")
+
+type Config struct {
+	// Issuer is the provider URL, metadata is discovered at <issuer>/.well-known/openid-configuration
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL must point to /api/auth/oidc/callback of this service
+	RedirectURL string
+	Scopes      []string
+}
+
+// Claims of an ID token this service relies on
+type Claims struct {
+	jwt.StandardClaims
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Nonce         string `json:"nonce"`
+}
+
+type Provider interface {
+	// AuthCodeURL returns the provider URL the user is redirected to, the code verifier is sent as S256 challenge
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	// Exchange redeems the authorization code and returns the raw ID token
+	Exchange(ctx context.Context, code, codeVerifier string) (string, error)
+	// VerifyIDToken checks signature, issuer, audience, lifetime and nonce of the ID token
+	VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (Claims, error)
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type provider struct {
+	sync.Mutex
+	cfg        Config
+	httpClient *http.Client
+	meta       *metadata
+	keys       map[string]crypto.PublicKey
+	keysAt     time.Time
+	logger     logging.Logger
+}
+
+// NewProvider creates a provider, its metadata is discovered on first use so the service starts while the provider is down
+func NewProvider(cfg Config, logger logging.Logger) Provider {
+	return &provider{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+	}
+}
+
+func (p *provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	authURL, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse authorization endpoint. error: %w", err)
+	}
+
+	scopes := []string{"openid"}
+	for _, s := range p.cfg.Scopes {
+		if s != "openid" {
+			scopes = append(scopes, s)
+		}
+	}
+	q := authURL.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallenge(codeVerifier))
+	q.Set("code_challenge_method", "S256")
+	authURL.RawQuery = q.Encode()
+	return authURL.String(), nil
+}
+
+func (p *provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	if code == "" {
+		return "", fmt.Errorf("authorization code is empty")
+	}
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.cfg.ClientID)
+
+	p.logger.Debug("create token request")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request. error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	p.logger.Debug("send token request")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send token request. error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tr tokenResponse
+	if err = json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", fmt.Errorf("failed to decode token response with status %d. error: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed with status %d: %s %s", resp.StatusCode, tr.Error, tr.ErrorDescription)
+	}
+	if tr.IDToken == "" {
+		return "", fmt.Errorf("token response has no id_token")
+	}
+	return tr.IDToken, nil
+}
+
+func (p *provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (c Claims, err error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return c, err
+	}
+	token, err := jwt.ParseString(rawIDToken)
+	if err != nil {
+		return c, err
+	}
+	verifier, err := p.verifier(ctx, token.Header())
+	if err != nil {
+		return c, err
+	}
+	if err = verifier.Verify(token.Payload(), token.Signature()); err != nil {
+		return c, err
+	}
+
+	if err = json.Unmarshal(token.RawClaims(), &c); err != nil {
+		return c, err
+	}
+	now := time.Now()
+	if c.ExpiresAt == nil || !c.IsValidExpiresAt(now.Add(-clockSkew)) || !c.IsValidNotBefore(now.Add(clockSkew)) {
+		return c, fmt.Errorf("id token has been expired")
+	}
+	if c.Issuer != meta.Issuer {
+		return c, fmt.Errorf("id token is issued by %s", c.Issuer)
+	}
+	if !c.IsForAudience(p.cfg.ClientID) {
+		return c, fmt.Errorf("id token is not for %s audience", p.cfg.ClientID)
+	}
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(c.Nonce), []byte(nonce)) != 1 {
+		return c, fmt.Errorf("id token nonce does not match")
+	}
+	if c.Subject == "" {
+		return c, fmt.Errorf("id token has no subject")
+	}
+	return c, nil
+}
+
+func (p *provider) discover(ctx context.Context) (*metadata, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	p.logger.Infof("discover identity provider %s", p.cfg.Issuer)
+	var meta metadata
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.cfg.Issuer, "/")+discoveryPath, &meta); err != nil {
+		return nil, fmt.Errorf("failed to discover identity provider. error: %w", err)
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != strings.TrimSuffix(p.cfg.Issuer, "/") {
+		return nil, fmt.Errorf("identity provider issuer %s does not match configured %s", meta.Issuer, p.cfg.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("identity provider metadata is incomplete")
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// verifier returns a verifier of the key from kid header, keys are refetched when kid is unknown
+// because providers rotate them
+func (p *provider) verifier(ctx context.Context, h jwt.Header) (jwt.Verifier, error) {
+	p.Lock()
+	key, ok := p.keys[h.KeyID]
+	if !ok && time.Since(p.keysAt) >= keysRefetchInterval {
+		if err := p.fetchKeys(ctx); err != nil {
+			p.Unlock()
+			return nil, err
+		}
+		key, ok = p.keys[h.KeyID]
+	}
+	p.Unlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	switch h.Algorithm {
+	case jwt.RS256:
+		if k, ok := key.(*rsa.PublicKey); ok {
+			return jwt.NewVerifierRS(jwt.RS256, k)
+		}
+	case jwt.ES256:
+		if k, ok := key.(*ecdsa.PublicKey); ok {
+			return jwt.NewVerifierES(jwt.ES256, k)
+		}
+	case jwt.EdDSA:
+		if k, ok := key.(ed25519.PublicKey); ok {
+			return jwt.NewVerifierEdDSA(k)
+		}
+	}
+	return nil, fmt.Errorf("unexpected signing algorithm %s", h.Algorithm)
+}
+
+// fetchKeys must be called with the lock held
+func (p *provider) fetchKeys(ctx context.Context) error {
+	p.logger.Debug("fetch identity provider keys")
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.meta.JWKSURI, &set); err != nil {
+		return fmt.Errorf("failed to fetch identity provider keys. error: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			p.logger.Warnf("skip key %s of identity provider: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	p.keys = keys
+	p.keysAt = time.Now()
+	return nil
+}
+
+func (p *provider) getJSON(ctx context.Context, uri string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("point is not on curve")
+		}
+		return pub, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+// RandomString returns a random URL safe string for state, nonce and PKCE code verifier
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge returns S256 PKCE challenge of the code verifier
+func CodeChallenge(codeVerifier string) string {
+	hash := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
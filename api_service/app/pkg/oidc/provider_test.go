@@ -0,0 +1,126 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/theartofdevel/notes_system/api_service/pkg/logging"
+	"github.com/theartofdevel/notes_system/api_service/pkg/oidc/mockoidc"
+)
+
+const redirectURL = "http://localhost:10000/api/auth/oidc/callback"
+
+func newTestProvider(t *testing.T) (Provider, *mockoidc.Server) {
+	mock, err := mockoidc.New("notes", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+	mock.Issuer = srv.URL
+
+	logger := logging.Logger{Entry: logrus.NewEntry(logrus.New())}
+	p := NewProvider(Config{
+		Issuer:       srv.URL,
+		ClientID:     "notes",
+		ClientSecret: "secret",
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email"},
+	}, logger)
+	return p, mock
+}
+
+// authorize follows the provider redirect and returns the callback query
+func authorize(t *testing.T, p Provider, state, nonce, verifier string) url.Values {
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned status %d", resp.StatusCode)
+	}
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query()
+}
+
+func TestProvider(t *testing.T) {
+	p, mock := newTestProvider(t)
+	ctx := context.Background()
+
+	// Test scenario:
+	// 1. authorization code is redirected back with the state
+	// 2. the code is exchanged with the PKCE verifier and ID token claims are verified
+	callback := authorize(t, p, "state", "nonce", "verifier")
+	assert.Equal(t, "state", callback.Get("state"))
+
+	rawIDToken, err := p.Exchange(ctx, callback.Get("code"), "verifier")
+	assert.NoError(t, err)
+	claims, err := p.VerifyIDToken(ctx, rawIDToken, "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, mock.Issuer, claims.Issuer)
+	assert.Equal(t, mock.User.Subject, claims.Subject)
+	assert.Equal(t, mock.User.Email, claims.Email)
+	assert.True(t, claims.EmailVerified)
+
+	// Test scenario:
+	// 1. the code can be exchanged only once
+	_, err = p.Exchange(ctx, callback.Get("code"), "verifier")
+	assert.Error(t, err)
+}
+
+func TestProviderRejectsWrongCodeVerifier(t *testing.T) {
+	p, _ := newTestProvider(t)
+
+	callback := authorize(t, p, "state", "nonce", "verifier")
+	_, err := p.Exchange(context.Background(), callback.Get("code"), "stolen")
+	assert.Error(t, err)
+}
+
+func TestProviderVerifyIDToken(t *testing.T) {
+	p, mock := newTestProvider(t)
+	ctx := context.Background()
+
+	rawIDToken, err := mock.IDToken(mock.User, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.VerifyIDToken(ctx, rawIDToken, "other")
+	assert.Error(t, err, "nonce mismatch")
+
+	mock.ClientID = "other"
+	rawIDToken, err = mock.IDToken(mock.User, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ClientID = "notes"
+	_, err = p.VerifyIDToken(ctx, rawIDToken, "nonce")
+	assert.Error(t, err, "wrong audience")
+
+	otherIssuer, err := mockoidc.New("notes", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherIssuer.Issuer = mock.Issuer
+	rawIDToken, err = otherIssuer.IDToken(mock.User, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.VerifyIDToken(ctx, rawIDToken, "nonce")
+	assert.Error(t, err, "signed by another key")
+}
@@ -92,3 +92,18 @@ class Neo4jCategoryDAO(CategoryDAO):
             DETACH DELETE path
             """
         )
+
+    def delete_user_categories(self, user_uuid: str) -> int:
+        result = self.storage.delete(
+            f"""
+            MATCH (u:User {'{'} id: "{user_uuid}" {'}'})
+            OPTIONAL MATCH (u)-[*]->(c:Category)
+            WITH u, collect(DISTINCT c) AS cs
+            FOREACH (c IN cs | DETACH DELETE c)
+            DETACH DELETE u
+            RETURN size(cs) AS deleted
+            """
+        )
+        if not result:
+            return 0
+        return result[0]["deleted"]
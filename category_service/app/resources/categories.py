@@ -64,3 +64,11 @@ class CategoriesResource(MethodResource, Resource):
         r.headers["Location"] = f"/api/categories/{category.uuid}"
         r.headers["Content-Type"] = "application/json"
         return r
+
+    @marshal_with(None, code=HTTPStatus.OK)
+    def delete(self):
+        user_uuid = request.args.get("user_uuid")
+        deleted = self.service.delete_user_categories(user_uuid=user_uuid)
+        r = make_response(jsonify(deleted=deleted), HTTPStatus.OK)
+        r.headers["Content-Type"] = "application/json"
+        return r
@@ -6,7 +6,7 @@ from injector import inject
 from dao.category.category import CategoryDAO
 from dao.model.dto import CreateCategoryDTO, UpdateCategoryDTO, DeleteCategoryDTO
 from dao.model.model import Category
-from exceptions import AppError, NotFoundException
+from exceptions import AppError, NotFoundException, ValidationException
 
 
 class CategoryService:
@@ -48,3 +48,10 @@ class CategoryService:
         if not is_exist:
             raise NotFoundException(exc_data=AppError.CATEGORY_NOT_FOUND)
         self.category_dao.delete_category(category=category)
+
+    def delete_user_categories(self, user_uuid: str) -> int:
+        if not user_uuid:
+            raise ValidationException(exc_data=AppError.VALIDATION_ERROR, developer_message="user_uuid is required")
+        deleted = self.category_dao.delete_user_categories(user_uuid=user_uuid)
+        self.logger.info(f"deleted {deleted} categories of user {user_uuid}")
+        return deleted
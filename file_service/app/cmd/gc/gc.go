@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/theartofdevel/notes_system/file_service/internal/config"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"github.com/theartofdevel/notes_system/file_service/internal/file/db"
+	"github.com/theartofdevel/notes_system/file_service/internal/file/storage"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	mongo "github.com/theartofdevel/notes_system/file_service/pkg/mongodb"
+	"time"
+)
+
+// gc deletes blobs without references and objects left by failed uploads, it is run next to the service:
+//
+//	docker exec ns-file_service /gc -grace 2h
+//
+// With -migrate it first moves files of note buckets, kept before blobs, into blobs and deletes the buckets.
+func main() {
+	grace := flag.Duration("grace", time.Hour, "keep objects younger than this, they may belong to uploads in progress")
+	migrate := flag.Bool("migrate", false, "import files of note buckets into blobs and delete the buckets")
+	flag.Parse()
+
+	logging.Init()
+	logger := logging.GetLogger()
+	cfg := config.GetConfig()
+	if cfg.Storage.Type == storage.TypeMemory {
+		logger.Fatal("memory storage is only seen by the service process, there is nothing to collect")
+	}
+
+	fileStorage, err := storage.NewStorage(cfg, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	mongoClient, err := mongo.NewClient(context.Background(), cfg.MongoDB.Host, cfg.MongoDB.Port,
+		cfg.MongoDB.Username, cfg.MongoDB.Password, cfg.MongoDB.Database, cfg.MongoDB.AuthDB)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	limits := file.Limits{
+		MaxSize:      cfg.Uploads.MaxSize,
+		ChunkSize:    cfg.Uploads.ChunkSize,
+		UploadTTL:    time.Duration(cfg.Uploads.TTL) * time.Second,
+		UserQuota:    cfg.Quota.PerUser,
+		PlanQuotas:   cfg.Quota.Plans,
+		AllowedTypes: cfg.Uploads.AllowedTypes,
+		DeniedTypes:  cfg.Uploads.DeniedTypes,
+	}
+	fileService, err := file.NewService(fileStorage,
+		db.NewFileStorage(mongoClient, "files", logger),
+		db.NewBlobStorage(mongoClient, "blobs", logger),
+		db.NewUploadStorage(mongoClient, "uploads", logger),
+		db.NewUsageStorage(mongoClient, "usage", logger),
+		nil, limits, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if *migrate {
+		logger.Info("import note buckets")
+		migration, err := fileService.ImportLegacyBuckets(context.Background())
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Infof("imported %d files of %d notes, %d notes failed", migration.Files, migration.Notes, migration.Failed)
+	}
+
+	logger.Infof("collect garbage older than %s", *grace)
+	report, err := fileService.CollectGarbage(context.Background(), *grace)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Infof("deleted %d unreferenced blobs and %d orphans of %d bytes", report.Blobs, report.Orphans, report.OrphanBytes)
+}
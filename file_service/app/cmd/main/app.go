@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"github.com/theartofdevel/notes_system/file_service/internal/config"
 	"github.com/theartofdevel/notes_system/file_service/internal/file"
-	"github.com/theartofdevel/notes_system/file_service/internal/file/storage/minio"
+	"github.com/theartofdevel/notes_system/file_service/internal/file/db"
+	"github.com/theartofdevel/notes_system/file_service/internal/file/scanner"
+	"github.com/theartofdevel/notes_system/file_service/internal/file/storage"
 	"github.com/theartofdevel/notes_system/file_service/pkg/handlers/metric"
 	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	mongo "github.com/theartofdevel/notes_system/file_service/pkg/mongodb"
 	"github.com/theartofdevel/notes_system/file_service/pkg/shutdown"
 	"net"
 	"net/http"
@@ -33,14 +37,39 @@ func main() {
 	metricHandler := metric.Handler{Logger: logger}
 	metricHandler.Register(router)
 
-	fileStorage, err := minio.NewStorage(cfg.MinIO.Endpoint, cfg.MinIO.AccessKey, cfg.MinIO.SecretKey, logger)
+	fileStorage, err := storage.NewStorage(cfg, logger)
 	if err != nil {
 		logger.Fatal(err)
 	}
-	fileService, err := file.NewService(fileStorage, logger)
+	mongoClient, err := mongo.NewClient(context.Background(), cfg.MongoDB.Host, cfg.MongoDB.Port,
+		cfg.MongoDB.Username, cfg.MongoDB.Password, cfg.MongoDB.Database, cfg.MongoDB.AuthDB)
 	if err != nil {
 		logger.Fatal(err)
 	}
+	metaStorage := db.NewFileStorage(mongoClient, "files", logger)
+	blobStorage := db.NewBlobStorage(mongoClient, "blobs", logger)
+	uploadStorage := db.NewUploadStorage(mongoClient, "uploads", logger)
+	usageStorage := db.NewUsageStorage(mongoClient, "usage", logger)
+	limits := file.Limits{
+		MaxSize:      cfg.Uploads.MaxSize,
+		ChunkSize:    cfg.Uploads.ChunkSize,
+		UploadTTL:    time.Duration(cfg.Uploads.TTL) * time.Second,
+		UserQuota:    cfg.Quota.PerUser,
+		PlanQuotas:   cfg.Quota.Plans,
+		AllowedTypes: cfg.Uploads.AllowedTypes,
+		DeniedTypes:  cfg.Uploads.DeniedTypes,
+	}
+	fileScanner, err := scanner.NewScanner(cfg, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	fileService, err := file.NewService(fileStorage, metaStorage, blobStorage, uploadStorage, usageStorage, fileScanner, limits, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	go abortExpiredUploads(fileService, logger)
+	// uploads scan their files themselves, files older than the scan timeout were left by failed scans
+	go rescanQuarantined(fileService, time.Duration(cfg.Scanner.Timeout)*time.Second+time.Minute, logger)
 	filesHandler := file.Handler{
 		Logger:      logger,
 		FileService: fileService,
@@ -51,6 +80,34 @@ func main() {
 	start(router, logger, cfg)
 }
 
+// abortExpiredUploads frees storage of resumable uploads abandoned by clients
+func abortExpiredUploads(fileService file.Service, logger logging.Logger) {
+	for range time.Tick(time.Hour) {
+		aborted, err := fileService.AbortExpiredUploads(context.Background())
+		if err != nil {
+			logger.Errorf("failed to abort expired uploads. err: %v", err)
+			continue
+		}
+		if aborted > 0 {
+			logger.Infof("aborted %d expired uploads", aborted)
+		}
+	}
+}
+
+// rescanQuarantined releases files which stayed in quarantine because the scanner was not available
+func rescanQuarantined(fileService file.Service, grace time.Duration, logger logging.Logger) {
+	for range time.Tick(5 * time.Minute) {
+		scanned, err := fileService.RescanQuarantined(context.Background(), grace)
+		if err != nil {
+			logger.Errorf("failed to scan quarantined files. err: %v", err)
+			continue
+		}
+		if scanned > 0 {
+			logger.Infof("scanned %d quarantined files", scanned)
+		}
+	}
+}
+
 func start(router http.Handler, logger logging.Logger, cfg *config.Config) {
 	var server *http.Server
 	var listener net.Listener
@@ -8,6 +8,6 @@ require (
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/minio/minio-go/v7 v7.0.10
 	github.com/sirupsen/logrus v1.8.1
+	go.mongodb.org/mongo-driver v1.5.0
 	golang.org/x/text v0.3.3
-	pkg.re/essentialkaos/translit.v2 v2.0.1+incompatible // indirect
 )
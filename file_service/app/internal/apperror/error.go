@@ -8,6 +8,12 @@ import (
 var (
 	ErrNotFound = NewAppError("not found", "FS-000010", "")
 	ErrAlreadyExist = NewAppError("already exists", "FS-000011", "")
+	ErrUploadOffsetMismatch = NewAppError("upload offset mismatch", "FS-000012", "get the offset with HEAD and send the chunk from it")
+	ErrQuotaExceeded = NewAppError("storage quota exceeded", "FS-000013", "see GET /api/usage, delete files or move the user to a bigger plan")
+	ErrFileTooLarge = NewAppError("file is too large", "FS-000014", "see max_file_size of GET /api/usage")
+	ErrContentTypeNotAllowed = NewAppError("file type is not allowed", "FS-000015", "")
+	ErrFileInfected = NewAppError("file is infected", "FS-000016", "the file is deleted, the attempt is logged")
+	ErrFileQuarantined = NewAppError("file is being scanned", "FS-000017", "try again later")
 )
 
 type AppError struct {
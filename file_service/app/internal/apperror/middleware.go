@@ -21,6 +21,26 @@ func Middleware(h appHandler) http.HandlerFunc {
 					w.WriteHeader(http.StatusConflict)
 					w.Write(ErrNotFound.Marshal())
 					return
+				} else if errors.Is(err, ErrUploadOffsetMismatch) {
+					w.WriteHeader(http.StatusConflict)
+					w.Write(ErrUploadOffsetMismatch.Marshal())
+					return
+				} else if errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrFileTooLarge) {
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+					w.Write(appErr.Marshal())
+					return
+				} else if errors.Is(err, ErrContentTypeNotAllowed) {
+					w.WriteHeader(http.StatusUnsupportedMediaType)
+					w.Write(appErr.Marshal())
+					return
+				} else if errors.Is(err, ErrFileInfected) {
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					w.Write(appErr.Marshal())
+					return
+				} else if errors.Is(err, ErrFileQuarantined) {
+					w.WriteHeader(http.StatusConflict)
+					w.Write(appErr.Marshal())
+					return
 				}
 				err := err.(*AppError)
 				w.WriteHeader(http.StatusBadRequest)
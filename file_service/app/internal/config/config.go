@@ -13,11 +13,51 @@ type Config struct {
 		BindIP string `yaml:"bind_ip" env-default:"localhost"`
 		Port   string `yaml:"port" env-default:"10002"`
 	}
+	Storage struct {
+		// Type is where file contents are kept: minio, fs or memory, memory is lost on restart
+		Type string `yaml:"type" env-default:"minio"`
+		// Path is the root directory of fs storage
+		Path string `yaml:"path"`
+	} `yaml:"storage"`
 	MinIO struct {
-		Endpoint  string `yaml:"endpoint" env-required:"true"`
-		AccessKey string `yaml:"access_key" env-required:"true"`
-		SecretKey string `yaml:"secret_key" env-required:"true"`
+		Endpoint  string `yaml:"endpoint"`
+		AccessKey string `yaml:"access_key"`
+		SecretKey string `yaml:"secret_key"`
 	} `yaml:"minio"`
+	MongoDB struct {
+		Host     string `yaml:"host" env-required:"true"`
+		Port     string `yaml:"port" env-required:"true"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+		AuthDB   string `yaml:"auth_db" env-required:"true"`
+		Database string `yaml:"database" env-required:"true"`
+	} `yaml:"mongodb" env-required:"true"`
+	Uploads struct {
+		// MaxSize is the largest file in bytes
+		MaxSize int64 `yaml:"max_size" env-default:"1073741824"`
+		// AllowedTypes and DeniedTypes are MIME types like image/png or image/*,
+		// any type which is not denied is allowed when AllowedTypes is empty
+		AllowedTypes []string `yaml:"allowed_types" env-separator:","`
+		DeniedTypes  []string `yaml:"denied_types" env-separator:","`
+		// ChunkSize is the size of every chunk of a resumable upload except the last one, at least 5MB
+		ChunkSize int64 `yaml:"chunk_size" env-default:"8388608"`
+		// TTL is how long an unfinished resumable upload is kept after its last chunk in seconds
+		TTL int `yaml:"ttl" env-default:"86400"`
+	} `yaml:"uploads"`
+	Quota struct {
+		// PerUser is how many bytes a user without a plan can store, 0 is unlimited
+		PerUser int64 `yaml:"per_user" env-default:"0"`
+		// Plans are quotas in bytes by plan name, 0 is unlimited
+		Plans map[string]int64 `yaml:"plans"`
+	} `yaml:"quota"`
+	Scanner struct {
+		// Type is none, clamav or eicar, eicar finds nothing but the EICAR test file and is meant for tests
+		Type string `yaml:"type" env-default:"none"`
+		// Address is host:port of clamd, its StreamMaxLength must be at least uploads.max_size
+		Address string `yaml:"address"`
+		// Timeout of a scan in seconds
+		Timeout int `yaml:"timeout" env-default:"300"`
+	} `yaml:"scanner"`
 }
 
 var instance *Config
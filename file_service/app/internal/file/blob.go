@@ -0,0 +1,166 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Content of files is stored once as a blob named by its SHA-256 in BlobsBucket. Metadata records
+// of files are references to blobs, and a blob is deleted with its last reference. Uploads are written
+// to incoming objects first, because the checksum is known only when the content is read.
+// Thumbnails of a blob are kept next to it and deleted with it.
+const (
+	BlobsBucket    = "blobs"
+	incomingPrefix = "incoming/"
+
+	addRefAttempts = 5
+	addRefDelay    = 100 * time.Millisecond
+)
+
+// ErrBlobBusy is returned by BlobStorage when the blob is being deleted, it is referenced again after
+var ErrBlobBusy = errors.New("blob is being deleted")
+
+// GCReport is the result of garbage collection
+type GCReport struct {
+	// Blobs are blobs deleted because they had no references
+	Blobs int `json:"blobs"`
+	// Orphans are objects without blob records and abandoned incoming objects
+	Orphans int `json:"orphans"`
+	// OrphanBytes is the size of deleted orphans
+	OrphanBytes int64 `json:"orphan_bytes"`
+}
+
+func incomingName(fileID string) string {
+	return incomingPrefix + fileID
+}
+
+// blobOf returns the blob the object belongs to, incoming objects belong to none
+func blobOf(name string) (string, bool) {
+	if strings.HasPrefix(name, incomingPrefix) {
+		return "", false
+	}
+	if strings.HasPrefix(name, thumbnailsPrefix) {
+		return strings.SplitN(strings.TrimPrefix(name, thumbnailsPrefix), "/", 2)[0], true
+	}
+	return name, true
+}
+
+// incomingFile is the object of an upload before it becomes a blob, names and uploaders are in metadata only
+func incomingFile(f *File) *File {
+	return &File{ID: incomingName(f.ID), Size: f.Size, ContentType: f.ContentType, Reader: f.Reader}
+}
+
+// storeBlob turns the incoming object into the blob of its checksum, when the blob exists already
+// the incoming object is dropped. The reference is added first, so the blob is not deleted meanwhile.
+func (s *service) storeBlob(ctx context.Context, incoming, sha256 string, size int64) error {
+	if err := s.addRef(ctx, sha256, size); err != nil {
+		s.deleteIncoming(ctx, incoming)
+		return err
+	}
+
+	exists, err := s.storage.FileExists(ctx, BlobsBucket, sha256)
+	if err == nil && !exists {
+		err = s.storage.MoveFile(ctx, BlobsBucket, incoming, sha256)
+	} else if err == nil {
+		s.logger.Debugf("blob %s is stored already", sha256)
+		s.deleteIncoming(ctx, incoming)
+	}
+	if err != nil {
+		s.deleteIncoming(ctx, incoming)
+		s.releaseBlob(ctx, sha256)
+		return err
+	}
+	return nil
+}
+
+func (s *service) addRef(ctx context.Context, sha256 string, size int64) (err error) {
+	for attempt := 0; attempt < addRefAttempts; attempt++ {
+		err = s.blobs.AddRef(ctx, sha256, size)
+		if !errors.Is(err, ErrBlobBusy) {
+			return err
+		}
+		time.Sleep(addRefDelay)
+	}
+	return err
+}
+
+// releaseBlob drops a reference and deletes the blob with the last one. Failures are only logged,
+// the garbage collection deletes what is left.
+func (s *service) releaseBlob(ctx context.Context, sha256 string) {
+	last, err := s.blobs.ReleaseRef(ctx, sha256)
+	if err != nil {
+		s.logger.Errorf("failed to release blob %s. err: %v", sha256, err)
+		return
+	}
+	if last {
+		if err = s.deleteBlob(ctx, sha256); err != nil {
+			s.logger.Errorf("failed to delete blob %s. err: %v", sha256, err)
+		}
+	}
+}
+
+func (s *service) deleteBlob(ctx context.Context, sha256 string) error {
+	if err := s.storage.DeleteFile(ctx, BlobsBucket, sha256); err != nil {
+		return err
+	}
+	for _, size := range thumbnailSizes {
+		if err := s.storage.DeleteFile(ctx, BlobsBucket, thumbnailName(sha256, size)); err != nil {
+			s.logger.Errorf("failed to delete %s thumbnail of blob %s. err: %v", size.Name, sha256, err)
+		}
+	}
+	return s.blobs.Delete(ctx, sha256)
+}
+
+func (s *service) deleteIncoming(ctx context.Context, incoming string) {
+	if err := s.storage.DeleteFile(ctx, BlobsBucket, incoming); err != nil {
+		s.logger.Errorf("failed to delete incoming file %s. err: %v", incoming, err)
+	}
+}
+
+// CollectGarbage deletes blobs without references and objects left by failed uploads and deletions.
+// Objects younger than grace are kept, they may belong to uploads in progress.
+func (s *service) CollectGarbage(ctx context.Context, grace time.Duration) (GCReport, error) {
+	var report GCReport
+
+	unreferenced, err := s.blobs.ClaimUnreferenced(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, sha256 := range unreferenced {
+		if err = s.deleteBlob(ctx, sha256); err != nil {
+			s.logger.Errorf("failed to delete blob %s. err: %v", sha256, err)
+			continue
+		}
+		report.Blobs++
+	}
+
+	objects, err := s.storage.ListFiles(ctx, BlobsBucket)
+	if err != nil {
+		return report, err
+	}
+	deadline := time.Now().Add(-grace)
+	for _, object := range objects {
+		if object.ModTime.After(deadline) {
+			continue
+		}
+		if sha256, ok := blobOf(object.Name); ok {
+			exists, err := s.blobs.Exists(ctx, sha256)
+			if err != nil {
+				return report, err
+			}
+			if exists {
+				continue
+			}
+		}
+		if err = s.storage.DeleteFile(ctx, BlobsBucket, object.Name); err != nil {
+			s.logger.Errorf("failed to delete orphan %s. err: %v", object.Name, err)
+			continue
+		}
+		report.Orphans++
+		report.OrphanBytes += object.Size
+	}
+
+	return report, nil
+}
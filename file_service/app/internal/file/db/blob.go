@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+var _ file.BlobStorage = &blobDB{}
+
+// blob is the reference count of a blob, a blob with Deleting set is not referenced again until it is deleted
+type blob struct {
+	SHA256    string    `bson:"_id"`
+	Size      int64     `bson:"size"`
+	Refs      int64     `bson:"refs"`
+	Deleting  bool      `bson:"deleting"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+type blobDB struct {
+	collection *mongo.Collection
+	logger     logging.Logger
+}
+
+func NewBlobStorage(storage *mongo.Database, collection string, logger logging.Logger) file.BlobStorage {
+	return &blobDB{
+		collection: storage.Collection(collection),
+		logger:     logger,
+	}
+}
+
+func (s *blobDB) AddRef(ctx context.Context, sha256 string, size int64) error {
+	// the filter doesn't match a blob being deleted, so the upsert fails on its duplicate id
+	filter := bson.M{"_id": sha256, "deleting": bson.M{"$ne": true}}
+	update := bson.M{
+		"$inc":         bson.M{"refs": 1},
+		"$setOnInsert": bson.M{"size": size, "deleting": false, "created_at": time.Now().UTC()},
+	}
+
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.collection.UpdateOne(nCtx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return file.ErrBlobBusy
+		}
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	return nil
+}
+
+func (s *blobDB) ReleaseRef(ctx context.Context, sha256 string) (bool, error) {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var b blob
+	err := s.collection.FindOneAndUpdate(nCtx, bson.M{"_id": sha256}, bson.M{"$inc": bson.M{"refs": -1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&b)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if b.Refs > 0 {
+		return false, nil
+	}
+
+	// only one of concurrent releases marks the blob, it deletes the blob
+	result, err := s.collection.UpdateOne(nCtx,
+		bson.M{"_id": sha256, "refs": bson.M{"$lte": 0}, "deleting": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"deleting": true}})
+	if err != nil {
+		return false, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (s *blobDB) Exists(ctx context.Context, sha256 string) (bool, error) {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	count, err := s.collection.CountDocuments(nCtx, bson.M{"_id": sha256})
+	if err != nil {
+		return false, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *blobDB) Delete(ctx context.Context, sha256 string) error {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := s.collection.DeleteOne(nCtx, bson.M{"_id": sha256}); err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	return nil
+}
+
+func (s *blobDB) ClaimUnreferenced(ctx context.Context) ([]string, error) {
+	nCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	_, err := s.collection.UpdateMany(nCtx,
+		bson.M{"refs": bson.M{"$lte": 0}, "deleting": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"deleting": true}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+
+	cursor, err := s.collection.Find(nCtx, bson.M{"deleting": true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	var blobs []blob
+	if err = cursor.All(nCtx, &blobs); err != nil {
+		return nil, fmt.Errorf("failed to decode documents. error: %w", err)
+	}
+	claimed := make([]string, 0, len(blobs))
+	for _, b := range blobs {
+		claimed = append(claimed, b.SHA256)
+	}
+	return claimed, nil
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+var _ file.MetaStorage = &fileDB{}
+
+type fileDB struct {
+	collection *mongo.Collection
+	logger     logging.Logger
+}
+
+func NewFileStorage(storage *mongo.Database, collection string, logger logging.Logger) file.MetaStorage {
+	s := &fileDB{
+		collection: storage.Collection(collection),
+		logger:     logger,
+	}
+	// files are listed by note, the index keeps listing fast
+	nCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := s.collection.Indexes().CreateOne(nCtx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "note_uuid", Value: 1}, {Key: "created_at", Value: 1}},
+		Options: options.Index().SetName("note_uuid_created_at"),
+	})
+	if err != nil {
+		logger.Errorf("failed to create index of %s collection. error: %v", collection, err)
+	}
+	// quarantined files are looked up to scan them again, the index has only them
+	_, err = s.collection.Indexes().CreateOne(nCtx, mongo.IndexModel{
+		Keys: bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().SetName("quarantined_created_at").
+			SetPartialFilterExpression(bson.M{"status": file.StatusQuarantined}),
+	})
+	if err != nil {
+		logger.Errorf("failed to create index of %s collection. error: %v", collection, err)
+	}
+	return s
+}
+
+func (s *fileDB) Create(ctx context.Context, f *file.File) error {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := s.collection.InsertOne(nCtx, f); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return apperror.ErrAlreadyExist
+		}
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	return nil
+}
+
+func (s *fileDB) FindOne(ctx context.Context, noteUUID, id string) (*file.File, error) {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result := s.collection.FindOne(nCtx, bson.M{"_id": id, "note_uuid": noteUUID})
+	if err := result.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, apperror.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	var f file.File
+	if err := result.Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to decode document. error: %w", err)
+	}
+	return &f, nil
+}
+
+func (s *fileDB) FindByNoteUUID(ctx context.Context, noteUUID string) ([]*file.File, error) {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	cursor, err := s.collection.Find(nCtx, bson.M{"note_uuid": noteUUID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	var files []*file.File
+	if err = cursor.All(nCtx, &files); err != nil {
+		return nil, fmt.Errorf("failed to decode documents. error: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, apperror.ErrNotFound
+	}
+	return files, nil
+}
+
+func (s *fileDB) Delete(ctx context.Context, noteUUID, id string) error {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := s.collection.DeleteOne(nCtx, bson.M{"_id": id, "note_uuid": noteUUID})
+	if err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+func (s *fileDB) DeleteByNoteUUID(ctx context.Context, noteUUID string) (int, error) {
+	nCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	result, err := s.collection.DeleteMany(nCtx, bson.M{"note_uuid": noteUUID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	return int(result.DeletedCount), nil
+}
+
+func (s *fileDB) SetStatus(ctx context.Context, noteUUID, id, status string) error {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := s.collection.UpdateOne(nCtx, bson.M{"_id": id, "note_uuid": noteUUID}, bson.M{"$set": bson.M{"status": status}})
+	if err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+func (s *fileDB) FindQuarantined(ctx context.Context, before time.Time) ([]*file.File, error) {
+	nCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cursor, err := s.collection.Find(nCtx, bson.M{"status": file.StatusQuarantined, "created_at": bson.M{"$lt": before}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	var files []*file.File
+	if err = cursor.All(nCtx, &files); err != nil {
+		return nil, fmt.Errorf("failed to decode documents. error: %w", err)
+	}
+	return files, nil
+}
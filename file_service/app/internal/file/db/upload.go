@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+var _ file.UploadStorage = &uploadDB{}
+
+type uploadDB struct {
+	collection *mongo.Collection
+	logger     logging.Logger
+}
+
+func NewUploadStorage(storage *mongo.Database, collection string, logger logging.Logger) file.UploadStorage {
+	return &uploadDB{
+		collection: storage.Collection(collection),
+		logger:     logger,
+	}
+}
+
+func (s *uploadDB) Create(ctx context.Context, upload file.Upload) error {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := s.collection.InsertOne(nCtx, upload); err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	return nil
+}
+
+func (s *uploadDB) FindOne(ctx context.Context, id string) (u file.Upload, err error) {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result := s.collection.FindOne(nCtx, bson.M{"_id": id})
+	if err = result.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return u, apperror.ErrNotFound
+		}
+		return u, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if err = result.Decode(&u); err != nil {
+		return u, fmt.Errorf("failed to decode document. error: %w", err)
+	}
+	return u, nil
+}
+
+func (s *uploadDB) AddPart(ctx context.Context, id string, offset int64, part file.UploadPart) (bool, error) {
+	filter := bson.M{"_id": id, "offset": offset}
+	update := bson.M{
+		"$inc":  bson.M{"offset": part.Size},
+		"$push": bson.M{"part_etags": part.ETag},
+		"$set": bson.M{
+			"content_type": part.ContentType,
+			"hash_state":   part.HashState,
+			"expires_at":   part.ExpiresAt,
+		},
+	}
+
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := s.collection.UpdateOne(nCtx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (s *uploadDB) Delete(ctx context.Context, id string) error {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := s.collection.DeleteOne(nCtx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+func (s *uploadDB) FindExpired(ctx context.Context, now time.Time) (uploads []file.Upload, err error) {
+	nCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cursor, err := s.collection.Find(nCtx, bson.M{"expires_at": bson.M{"$lt": now}})
+	if err != nil {
+		return uploads, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if err = cursor.All(nCtx, &uploads); err != nil {
+		return uploads, fmt.Errorf("failed to decode documents. error: %w", err)
+	}
+	return uploads, nil
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+var _ file.UsageStorage = &usageDB{}
+
+type usage struct {
+	UserUUID string `bson:"_id"`
+	Used     int64  `bson:"used"`
+	Pending  int64  `bson:"pending"`
+	Plan     string `bson:"plan,omitempty"`
+}
+
+type usageDB struct {
+	collection *mongo.Collection
+	logger     logging.Logger
+}
+
+func NewUsageStorage(storage *mongo.Database, collection string, logger logging.Logger) file.UsageStorage {
+	return &usageDB{
+		collection: storage.Collection(collection),
+		logger:     logger,
+	}
+}
+
+func (s *usageDB) Get(ctx context.Context, userUUID string) (file.Usage, error) {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var u usage
+	err := s.collection.FindOne(nCtx, bson.M{"_id": userUUID}).Decode(&u)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return file.Usage{}, nil
+		}
+		return file.Usage{}, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	return file.Usage{Plan: u.Plan, Used: u.Used, Pending: u.Pending}, nil
+}
+
+func (s *usageDB) Add(ctx context.Context, userUUID string, delta int64) error {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.collection.UpdateOne(nCtx, bson.M{"_id": userUUID}, bson.M{"$inc": bson.M{"used": delta}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	return nil
+}
+
+func (s *usageDB) Reserve(ctx context.Context, userUUID string, size, quota int64) (bool, error) {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	// the document must exist with both fields for the condition, an upsert can't insert what the condition filters
+	_, err := s.collection.UpdateOne(nCtx, bson.M{"_id": userUUID}, bson.M{"$inc": bson.M{"used": 0, "pending": 0}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return false, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	filter := bson.M{"_id": userUUID}
+	if quota > 0 {
+		filter["$expr"] = bson.M{"$lte": bson.A{bson.M{"$add": bson.A{"$used", "$pending", size}}, quota}}
+	}
+	result, err := s.collection.UpdateOne(nCtx, filter, bson.M{"$inc": bson.M{"pending": size}})
+	if err != nil {
+		return false, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (s *usageDB) Settle(ctx context.Context, userUUID string, reserved, stored int64) error {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := s.collection.UpdateOne(nCtx, bson.M{"_id": userUUID}, bson.M{"$inc": bson.M{"pending": -reserved, "used": stored}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	return nil
+}
+
+func (s *usageDB) SetPlan(ctx context.Context, userUUID, plan string) error {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"plan": plan}}
+	if plan == "" {
+		update = bson.M{"$unset": bson.M{"plan": ""}}
+	}
+	_, err := s.collection.UpdateOne(nCtx, bson.M{"_id": userUUID}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	return nil
+}
@@ -6,12 +6,20 @@ import (
 	"github.com/julienschmidt/httprouter"
 	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
 	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"strconv"
 )
 
 const (
-	filesURL = "/api/files"
-	fileURL = "/api/files/:id"
+	filesURL     = "/api/files"
+	fileURL      = "/api/files/:id"
+	thumbnailURL = "/api/files/:id/thumbnail"
+
+	// maxFieldSize limits form fields other than the file
+	maxFieldSize = 1024
 )
 
 type Handler struct {
@@ -21,9 +29,19 @@ type Handler struct {
 
 func (h *Handler) Register(router *httprouter.Router) {
 	router.HandlerFunc(http.MethodGet, fileURL, apperror.Middleware(h.GetFile))
+	router.HandlerFunc(http.MethodGet, thumbnailURL, apperror.Middleware(h.GetThumbnail))
 	router.HandlerFunc(http.MethodGet, filesURL, apperror.Middleware(h.GetFilesByNoteUUID))
 	router.HandlerFunc(http.MethodPost, filesURL, apperror.Middleware(h.CreateFile))
 	router.HandlerFunc(http.MethodDelete, fileURL, apperror.Middleware(h.DeleteFile))
+	router.HandlerFunc(http.MethodDelete, filesURL, apperror.Middleware(h.DeleteFilesByNoteUUID))
+
+	router.HandlerFunc(http.MethodPost, uploadsURL, apperror.Middleware(h.CreateUpload))
+	router.HandlerFunc(http.MethodHead, uploadURL, apperror.Middleware(h.GetUploadOffset))
+	router.HandlerFunc(http.MethodPatch, uploadURL, apperror.Middleware(h.WriteChunk))
+	router.HandlerFunc(http.MethodDelete, uploadURL, apperror.Middleware(h.AbortUpload))
+
+	router.HandlerFunc(http.MethodGet, usageURL, apperror.Middleware(h.GetUsage))
+	router.HandlerFunc(http.MethodPut, planURL, apperror.Middleware(h.SetPlan))
 }
 
 func (h *Handler) GetFile(w http.ResponseWriter, r *http.Request) error {
@@ -39,22 +57,115 @@ func (h *Handler) GetFile(w http.ResponseWriter, r *http.Request) error {
 	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
 	fileId := params.ByName("id")
 
-	f, err := h.FileService.GetFile(r.Context(), noteUUID, fileId)
+	f, err := h.FileService.GetFileInfo(r.Context(), noteUUID, fileId)
+	if err != nil {
+		return err
+	}
+	if f.Quarantined() {
+		return apperror.ErrFileQuarantined
+	}
+
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.Name}))
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", etag(f))
+	if !f.CreatedAt.IsZero() {
+		w.Header().Set("Last-Modified", f.CreatedAt.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, f) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	h.Logger.Debug("get range from headers")
+	rng, err := requestedRange(r, f)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", f.Size))
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return nil
+	}
+
+	reader, err := h.FileService.GetFile(r.Context(), noteUUID, f, rng)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if rng != nil {
+		w.Header().Set("Content-Range", rng.ContentRange(f.Size))
+		w.Header().Set("Content-Length", strconv.FormatInt(rng.Length(), 10))
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.Header().Set("Content-Length", strconv.FormatInt(f.Size, 10))
+		w.WriteHeader(http.StatusOK)
+	}
+
+	h.Logger.Debug("stream file")
+	if _, err = io.Copy(w, reader); err != nil {
+		// headers are sent already, the client sees a truncated body
+		h.Logger.Errorf("failed to stream file %s. err: %v", fileId, err)
+	}
+
+	return nil
+}
+
+func (h *Handler) GetThumbnail(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("GET THUMBNAIL")
+
+	h.Logger.Debug("get note_uuid and size from URL")
+	noteUUID := r.URL.Query().Get("note_uuid")
+	if noteUUID == "" {
+		return apperror.BadRequestError("note_uuid query parameter is required")
+	}
+	size, err := ParseThumbnailSize(r.URL.Query().Get("size"))
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Debug("get fileId from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	fileId := params.ByName("id")
+
+	f, err := h.FileService.GetFileInfo(r.Context(), noteUUID, fileId)
+	if err != nil {
+		return err
+	}
+	if f.Quarantined() {
+		return apperror.ErrFileQuarantined
+	}
+
+	// a thumbnail changes only with the content of the file
+	tag := fmt.Sprintf(`"%s-%s"`, f.SHA256, size.Name)
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	if r.Header.Get("If-None-Match") == tag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	reader, err := h.FileService.GetThumbnail(r.Context(), f, size)
 	if err != nil {
 		return err
 	}
+	defer reader.Close()
 
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", f.Name))
-	w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+	w.Header().Set("Content-Type", thumbnailContentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, reader); err != nil {
+		h.Logger.Errorf("failed to send thumbnail of file %s. err: %v", fileId, err)
+	}
 
-	w.Write(f.Bytes)
-	
 	return nil
 }
 
 func (h *Handler) GetFilesByNoteUUID(w http.ResponseWriter, r *http.Request) error {
 	h.Logger.Info("GET FILES BY NOTE UUID")
-	w.Header().Set("Content-Type", "form/json")
+	w.Header().Set("Content-Type", "application/json")
 
 	h.Logger.Debug("get note_uuid from URL")
 	noteUUID := r.URL.Query().Get("note_uuid")
@@ -78,37 +189,63 @@ func (h *Handler) GetFilesByNoteUUID(w http.ResponseWriter, r *http.Request) err
 	return nil
 }
 
+// CreateFile reads multipart/form-data part by part, the file part is piped to the storage without buffering.
+// note_uuid is taken from the query only, the gateway checks the ownership of that note and passes the body as is,
+// so a note_uuid field naming another note is rejected.
 func (h *Handler) CreateFile(w http.ResponseWriter, r *http.Request) error {
 	h.Logger.Info("CREATE FILE")
-	w.Header().Set("Content-Type", "form/json")
+	w.Header().Set("Content-Type", "application/json")
 
-	// TODO maximum file size
-	err := r.ParseMultipartForm(32 << 20)
-	if err != nil {
-		return err
+	h.Logger.Debug("get user_uuid from URL")
+	userUUID := r.URL.Query().Get("user_uuid")
+	if userUUID == "" {
+		return apperror.BadRequestError("user_uuid query parameter is required")
 	}
 
-	h.Logger.Debug("decode create upload fileInfo dto")
-
-	files, ok := r.MultipartForm.File["file"]
-	if !ok || len(files) == 0 {
-		return apperror.BadRequestError("file required")
-	}
-	fileInfo := files[0]
-	fileReader, err := fileInfo.Open()
-	dto := CreateFileDTO{
-		Name: fileInfo.Filename,
-		Size: fileInfo.Size,
-		Reader: fileReader,
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return apperror.BadRequestError("multipart/form-data body is required")
 	}
 
-	err = h.FileService.Create(r.Context(), r.Form.Get("note_uuid"), dto)
-	if err != nil {
-		return err
+	noteUUID := r.URL.Query().Get("note_uuid")
+	if noteUUID == "" {
+		return apperror.BadRequestError("note_uuid query parameter is required")
 	}
-	w.WriteHeader(http.StatusCreated)
+	h.Logger.Debug("find file part")
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return apperror.BadRequestError("file required")
+		}
+		if err != nil {
+			return apperror.BadRequestError("failed to read multipart body")
+		}
 
-	return nil
+		switch part.FormName() {
+		case "note_uuid":
+			value, err := ioutil.ReadAll(io.LimitReader(part, maxFieldSize))
+			if err != nil {
+				return apperror.BadRequestError("failed to read note_uuid")
+			}
+			if string(value) != noteUUID {
+				h.Logger.Warnf("audit: user %s uploaded a file to note %s with note_uuid field %q", userUUID, noteUUID, value)
+				return apperror.BadRequestError("note_uuid field differs from the query")
+			}
+		case "file":
+			dto := CreateFileDTO{
+				UserUUID:    userUUID,
+				Name:        part.FileName(),
+				Size:        -1,
+				ContentType: part.Header.Get("Content-Type"),
+				Reader:      part,
+			}
+			if err = h.FileService.Create(r.Context(), noteUUID, dto); err != nil {
+				return err
+			}
+			w.WriteHeader(http.StatusCreated)
+			return nil
+		}
+	}
 }
 
 func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) error {
@@ -132,3 +269,29 @@ func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+func (h *Handler) DeleteFilesByNoteUUID(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("DELETE FILES BY NOTE UUID")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("get note_uuid from URL")
+	noteUUID := r.URL.Query().Get("note_uuid")
+	if noteUUID == "" {
+		return apperror.BadRequestError("note_uuid query parameter is required")
+	}
+
+	deleted, err := h.FileService.DeleteFilesByNoteUUID(r.Context(), noteUUID)
+	if err != nil {
+		return err
+	}
+
+	resultBytes, err := json.Marshal(DeleteResult{Deleted: deleted})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultBytes)
+
+	return nil
+}
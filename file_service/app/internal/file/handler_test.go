@@ -0,0 +1,60 @@
+package file
+
+import (
+	"bytes"
+	"github.com/sirupsen/logrus"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// createFile posts the file with the note_uuid field before it, no field is sent when it is empty
+func createFile(h *Handler, query, field string) int {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if field != "" {
+		writer.WriteField("note_uuid", field)
+	}
+	part, _ := writer.CreateFormFile("file", "notes.txt")
+	part.Write([]byte("notes"))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, filesURL+"?user_uuid=user&"+query, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	apperror.Middleware(h.CreateFile)(w, req)
+	return w.Code
+}
+
+// Test scenario:
+// 1. file is attached to the note of the query, a note_uuid field naming another note is rejected
+// 2. note_uuid field alone is not enough, the query is required
+func TestCreateFileNoteUUID(t *testing.T) {
+	s, fakes := newTestService(t, 0)
+	h := &Handler{Logger: logging.Logger{Entry: logrus.NewEntry(logrus.New())}, FileService: s}
+
+	if code := createFile(h, "note_uuid=own", "victim"); code != http.StatusBadRequest {
+		t.Errorf("conflicting field: status = %d, want 400", code)
+	}
+	if code := createFile(h, "", "victim"); code != http.StatusBadRequest {
+		t.Errorf("field without query: status = %d, want 400", code)
+	}
+	if len(fakes.files.files) != 0 {
+		t.Fatalf("%d files are recorded, want none", len(fakes.files.files))
+	}
+
+	if code := createFile(h, "note_uuid=own", "own"); code != http.StatusCreated {
+		t.Errorf("matching field: status = %d, want 201", code)
+	}
+	if code := createFile(h, "note_uuid=own", ""); code != http.StatusCreated {
+		t.Errorf("no field: status = %d, want 201", code)
+	}
+	for _, f := range fakes.files.files {
+		if f.NoteUUID != "own" {
+			t.Errorf("file is attached to note %s", f.NoteUUID)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"io"
+	"regexp"
+)
+
+// Files stored before metadata records and blobs are in a bucket of their note named by the note uuid, which is
+// the hex ObjectID of the note. Objects are named by file ids and keep the name, the uploader and the type in their metadata.
+// They are imported into blobs when their note is read, the bucket is deleted after. The gc command
+// with -migrate imports all buckets at once, so files of notes which are never read are not kept forever.
+
+// LegacyStorage lists files of the note buckets, their content is read with Storage. Only the minio
+// storage has them, other storages were added later.
+type LegacyStorage interface {
+	// ListNoteBuckets returns names of all buckets except BlobsBucket
+	ListNoteBuckets(ctx context.Context) ([]string, error)
+	// ListNoteFiles returns files of the bucket of the note with ID, Name, Size, ContentType, Uploader
+	// and CreatedAt, a missing bucket has none
+	ListNoteFiles(ctx context.Context, noteUUID string) ([]*File, error)
+	// DeleteNoteBucket deletes the bucket with objects left in it, a missing bucket is not an error
+	DeleteNoteBucket(ctx context.Context, noteUUID string) error
+}
+
+// noteBucket matches names of note buckets
+var noteBucket = regexp.MustCompile(`^[0-9a-f]{24}$`)
+
+// MigrationReport is the result of importing all note buckets
+type MigrationReport struct {
+	Notes int `json:"notes"`
+	Files int `json:"files"`
+	// Failed are notes left with their buckets, the import is retried by the next run or when the note is read
+	Failed int `json:"failed"`
+}
+
+// ImportLegacyBuckets imports files of all note buckets and deletes the buckets, empty ones too
+func (s *service) ImportLegacyBuckets(ctx context.Context) (MigrationReport, error) {
+	var report MigrationReport
+	if s.legacy == nil {
+		return report, nil
+	}
+	buckets, err := s.legacy.ListNoteBuckets(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, noteUUID := range buckets {
+		if !noteBucket.MatchString(noteUUID) {
+			s.logger.Warnf("bucket %s is not of a note, it is kept", noteUUID)
+			continue
+		}
+		imported, err := s.importLegacy(ctx, noteUUID)
+		report.Files += imported
+		if err == nil {
+			err = s.legacy.DeleteNoteBucket(ctx, noteUUID)
+		}
+		if err != nil {
+			s.logger.Errorf("failed to import bucket of note %s. err: %v", noteUUID, err)
+			report.Failed++
+			continue
+		}
+		report.Notes++
+	}
+	return report, nil
+}
+
+// importLegacy moves files of the note bucket into blobs and records them, it returns how many files are imported
+func (s *service) importLegacy(ctx context.Context, noteUUID string) (int, error) {
+	// other buckets like BlobsBucket are never taken for one of a note
+	if s.legacy == nil || !noteBucket.MatchString(noteUUID) {
+		return 0, nil
+	}
+	files, err := s.legacy.ListNoteFiles(ctx, noteUUID)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, nil
+	}
+	imported := 0
+	for _, f := range files {
+		if err = s.importLegacyFile(ctx, noteUUID, f); err != nil {
+			return imported, fmt.Errorf("failed to import file %s of note %s. err: %w", f.ID, noteUUID, err)
+		}
+		imported++
+	}
+	if err = s.legacy.DeleteNoteBucket(ctx, noteUUID); err != nil {
+		return imported, err
+	}
+	s.logger.Infof("imported %d files of note %s", imported, noteUUID)
+	return imported, nil
+}
+
+// importLegacyFile copies the object into its blob and records the file with its old id, so links to it keep working.
+// Usage is not added, it is counted since the upload. The object is deleted last, so a failed import is retried.
+func (s *service) importLegacyFile(ctx context.Context, noteUUID string, f *File) error {
+	reader, err := s.storage.GetFile(ctx, noteUUID, f.ID, nil)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			// imported by a concurrent request
+			return nil
+		}
+		return err
+	}
+	defer reader.Close()
+
+	checksum := sha256.New()
+	f.ContentType, _, f.Reader = detectContentType(f.ContentType, io.TeeReader(reader, checksum))
+	incoming := incomingFile(f)
+	if err = s.storage.CreateFile(ctx, BlobsBucket, incoming); err != nil {
+		return err
+	}
+
+	f.NoteUUID = noteUUID
+	f.Name = normalizeName(f.Name)
+	f.SHA256 = hex.EncodeToString(checksum.Sum(nil))
+	f.Reader = nil
+	if err = s.storeBlob(ctx, incoming.ID, f.SHA256, f.Size); err != nil {
+		return err
+	}
+	if err = s.files.Create(ctx, f); err != nil {
+		s.releaseBlob(ctx, f.SHA256)
+		if !errors.Is(err, apperror.ErrAlreadyExist) {
+			return err
+		}
+	}
+	return s.storage.DeleteFile(ctx, noteUUID, f.ID)
+}
+
+// findOne returns the record of the file, files of the note bucket are imported when there is none
+func (s *service) findOne(ctx context.Context, noteUUID, id string) (*File, error) {
+	f, err := s.files.FindOne(ctx, noteUUID, id)
+	if !errors.Is(err, apperror.ErrNotFound) {
+		return f, err
+	}
+	imported, err := s.importLegacy(ctx, noteUUID)
+	if err != nil {
+		return nil, err
+	}
+	if imported == 0 {
+		return nil, apperror.ErrNotFound
+	}
+	return s.files.FindOne(ctx, noteUUID, id)
+}
@@ -0,0 +1,144 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"testing"
+	"time"
+)
+
+// fakeLegacyStorage keeps files of note buckets, their content is in objects of fakeStorage
+type fakeLegacyStorage struct {
+	*fakeStorage
+	files map[string][]*File
+}
+
+func (s *fakeLegacyStorage) ListNoteBuckets(ctx context.Context) (buckets []string, err error) {
+	for noteUUID := range s.files {
+		buckets = append(buckets, noteUUID)
+	}
+	return buckets, nil
+}
+
+func (s *fakeLegacyStorage) ListNoteFiles(ctx context.Context, noteUUID string) (files []*File, err error) {
+	for _, f := range s.files[noteUUID] {
+		if _, ok := s.objects[objectKey(noteUUID, f.ID)]; ok {
+			stored := *f
+			files = append(files, &stored)
+		}
+	}
+	return files, nil
+}
+
+func (s *fakeLegacyStorage) DeleteNoteBucket(ctx context.Context, noteUUID string) error {
+	objects, _ := s.ListFiles(ctx, noteUUID)
+	for _, object := range objects {
+		delete(s.objects, objectKey(noteUUID, object.Name))
+	}
+	delete(s.files, noteUUID)
+	return nil
+}
+
+func (s *fakeLegacyStorage) add(noteUUID string, f *File, content []byte) {
+	f.Size = int64(len(content))
+	s.files[noteUUID] = append(s.files[noteUUID], f)
+	s.objects[objectKey(noteUUID, f.ID)] = fakeObject{content: content, modTime: time.Now()}
+}
+
+// Test scenario:
+// 1. listing a note imports files of its bucket next to the new ones, they keep ids, names and uploaders
+// 2. imported content is in blobs with the sniffed type, the bucket is deleted and usage is not counted again
+// 3. file of another note bucket is deleted without reading it first
+// 4. names which are not note ids are never taken for buckets
+func TestImportLegacy(t *testing.T) {
+	ctx := context.Background()
+	s, fakes := newTestService(t, 0)
+	legacy := &fakeLegacyStorage{fakeStorage: fakes.storage, files: map[string][]*File{}}
+	s.legacy = legacy
+	note := "60697c345ab2b15a8409fd5f"
+	other := "60697c345ab2b15a8409fd60"
+	pdf := []byte("%PDF-1.4 legacy document")
+	legacy.add(note, &File{ID: "f1", Name: "Cafe_notes.pdf", OriginalName: "Cafe_notes.pdf", ContentType: "application/octet-stream", Uploader: "user"}, pdf)
+	legacy.add(note, &File{ID: "f2", Name: "todo.txt", OriginalName: "todo.txt", ContentType: "text/plain", Uploader: "user"}, []byte("buy milk"))
+	legacy.add(other, &File{ID: "f3", Name: "old.txt", OriginalName: "old.txt", ContentType: "text/plain"}, []byte("old"))
+
+	content := []byte("new file")
+	dto := CreateFileDTO{UserUUID: "user", Name: "new.txt", Size: int64(len(content)), Reader: bytes.NewReader(content)}
+	if err := s.Create(ctx, note, dto); err != nil {
+		t.Fatal(err)
+	}
+	files, err := s.GetFilesByNoteUUID(ctx, note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("%d files are listed, want 3", len(files))
+	}
+	f, err := s.GetFileInfo(ctx, note, "f1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name != "Cafe_notes.pdf" || f.Uploader != "user" || f.ContentType != "application/pdf" || f.SHA256 != checksum(pdf) {
+		t.Errorf("imported record = %+v", f)
+	}
+	if objects, _ := fakes.storage.ListFiles(ctx, note); len(objects) != 0 {
+		t.Errorf("bucket of the note has %d objects left", len(objects))
+	}
+	if _, ok := legacy.files[note]; ok {
+		t.Error("bucket of the note is kept")
+	}
+	if used := fakes.usage["user"].Used; used != int64(len(content)) {
+		t.Errorf("usage = %d, want %d", used, len(content))
+	}
+
+	if err = s.Delete(ctx, other, "f3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.GetFileInfo(ctx, other, "f3"); !errors.Is(err, apperror.ErrNotFound) {
+		t.Errorf("deleted file: error = %v, want not found", err)
+	}
+	if _, ok := fakes.blobs[checksum([]byte("old"))]; ok {
+		t.Error("blob of the deleted file is kept")
+	}
+
+	fakes.storage.objects[objectKey(BlobsBucket, "f4")] = fakeObject{content: []byte("blob")}
+	legacy.files[BlobsBucket] = []*File{{ID: "f4"}}
+	if _, err = s.GetFileInfo(ctx, BlobsBucket, "f4"); !errors.Is(err, apperror.ErrNotFound) {
+		t.Errorf("blobs bucket: error = %v, want not found", err)
+	}
+}
+
+// Test scenario:
+// 1. files of all note buckets are imported and the buckets are deleted, empty ones too
+// 2. buckets which are not of notes are kept
+func TestImportLegacyBuckets(t *testing.T) {
+	ctx := context.Background()
+	s, fakes := newTestService(t, 0)
+	legacy := &fakeLegacyStorage{fakeStorage: fakes.storage, files: map[string][]*File{}}
+	s.legacy = legacy
+	note := "60697c345ab2b15a8409fd5f"
+	empty := "60697c345ab2b15a8409fd60"
+	legacy.add(note, &File{ID: "f1", Name: "a.txt", Uploader: "user"}, []byte("a"))
+	legacy.add(note, &File{ID: "f2", Name: "b.txt", Uploader: "user"}, []byte("b"))
+	legacy.files[empty] = nil
+	legacy.add("backups", &File{ID: "dump"}, []byte("dump"))
+
+	report, err := s.ImportLegacyBuckets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (MigrationReport{Notes: 2, Files: 2}) {
+		t.Errorf("report = %+v", report)
+	}
+	if len(fakes.files.files) != 2 || len(fakes.storage.blobNames()) != 2 {
+		t.Errorf("%d records and blobs %v, want 2 of each", len(fakes.files.files), fakes.storage.blobNames())
+	}
+	if _, ok := legacy.files[empty]; ok {
+		t.Error("empty bucket is kept")
+	}
+	if _, ok := fakes.storage.objects[objectKey("backups", "dump")]; !ok {
+		t.Error("bucket which is not of a note is deleted")
+	}
+}
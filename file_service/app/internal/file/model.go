@@ -6,48 +6,82 @@ import (
 	"golang.org/x/text/transform"
 	"golang.org/x/text/unicode/norm"
 	"io"
-	"io/ioutil"
 	"strings"
+	"time"
 	"unicode"
 )
 
+// File is the metadata record of a file, the content is in the storage
 type File struct {
-	ID 	  string `json:"id"`
-	Name  string `json:"name"`
-	Size  int64  `json:"size"`
-	Bytes []byte `json:"file"`
+	ID       string `json:"id" bson:"_id"`
+	NoteUUID string `json:"note_uuid" bson:"note_uuid"`
+	// Name is normalized OriginalName, it is used in Content-Disposition on download
+	Name         string `json:"name" bson:"name"`
+	OriginalName string `json:"original_name" bson:"original_name"`
+	Size         int64  `json:"size" bson:"size"`
+	// ContentType is sniffed from the content, the type sent by the client is kept only when the sniffed one is generic
+	ContentType string `json:"content_type" bson:"content_type"`
+	// SHA256 is the hex checksum of the content, it is the ETag on download
+	SHA256    string    `json:"sha256" bson:"sha256"`
+	Uploader  string    `json:"uploader,omitempty" bson:"uploader,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	// Status is StatusQuarantined until the content is scanned, files stored before scanning have none and are clean
+	Status string `json:"status,omitempty" bson:"status,omitempty"`
+	// Reader is the content of an uploaded file
+	Reader io.Reader `json:"-" bson:"-"`
 }
 
+// Quarantined reports whether the file is not scanned yet, it is not served until then
+func (f *File) Quarantined() bool {
+	return f.Status == StatusQuarantined
+}
+
+// StoredFile is an object in the storage
+type StoredFile struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// DeleteResult is returned by bulk deletion
+type DeleteResult struct {
+	Deleted int `json:"deleted"`
+}
+
+// CreateFileDTO is an upload streamed from the request, Size is -1 when the client didn't send it
 type CreateFileDTO struct {
-	Name     string `json:"name"`
-	Size     int64  `json:"size"`
-	Reader   io.Reader
+	UserUUID    string `json:"user_uuid"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	Reader      io.Reader
 }
 
 func isMn(r rune) bool {
 	return unicode.Is(unicode.Mn, r) // Mn: nonspacing marks
 }
 
-func (d CreateFileDTO) NormalizeName() {
-	d.Name = strings.ReplaceAll(d.Name, " ", "_")
+// normalizeName replaces spaces and strips diacritics, so the name is safe in headers and on disk
+func normalizeName(name string) string {
+	name = strings.ReplaceAll(name, " ", "_")
 	t := transform.Chain(norm.NFD, transform.RemoveFunc(isMn), norm.NFC)
-	d.Name, _, _ = transform.String(t, d.Name)
+	name, _, _ = transform.String(t, name)
+	return name
 }
 
 func NewFile(dto CreateFileDTO) (*File, error) {
-	bytes, err := ioutil.ReadAll(dto.Reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create file model. err: %w", err)
-	}
 	id, err := uuid.NewUUID()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate file id. err: %w", err)
 	}
 
 	return &File{
-		ID:    id.String(),
-		Name:  dto.Name,
-		Size:  dto.Size,
-		Bytes: bytes,
+		ID:           id.String(),
+		Name:         normalizeName(dto.Name),
+		OriginalName: dto.Name,
+		Size:         dto.Size,
+		ContentType:  dto.ContentType,
+		Uploader:     dto.UserUUID,
+		Reader:       dto.Reader,
 	}, nil
 }
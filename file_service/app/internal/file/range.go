@@ -0,0 +1,123 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// ByteRange is an inclusive range of bytes of a file
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+func (r ByteRange) Length() int64 {
+	return r.End - r.Start + 1
+}
+
+func (r ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End, size)
+}
+
+// etag is the quoted checksum, it is a strong validator as the content of a file never changes
+func etag(f *File) string {
+	return `"` + f.SHA256 + `"`
+}
+
+// notModified evaluates If-None-Match and If-Modified-Since, the latter is ignored when the former is sent
+func notModified(r *http.Request, f *File) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag(f) {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !f.CreatedAt.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !f.CreatedAt.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// requestedRange returns the range to send or nil for the whole file. Only a single range is served,
+// multiple ranges and malformed headers are ignored as RFC 7233 allows.
+func requestedRange(r *http.Request, f *File) (*ByteRange, error) {
+	header := r.Header.Get("Range")
+	if header == "" || !rangeIsFresh(r, f) {
+		return nil, nil
+	}
+	return parseRange(header, f.Size)
+}
+
+// rangeIsFresh evaluates If-Range, a stale validator means the client has to download the whole file again
+func rangeIsFresh(r *http.Request, f *File) bool {
+	ifRange := r.Header.Get("If-Range")
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) {
+		// If-Range requires strong comparison
+		return ifRange == etag(f)
+	}
+	t, err := http.ParseTime(ifRange)
+	return err == nil && f.CreatedAt.Truncate(time.Second).Equal(t)
+}
+
+func parseRange(header string, size int64) (*ByteRange, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return nil, nil
+	}
+	spec := strings.TrimSpace(header[len(prefix):])
+	if strings.Contains(spec, ",") {
+		return nil, nil
+	}
+	i := strings.Index(spec, "-")
+	if i < 0 {
+		return nil, nil
+	}
+	startSpec, endSpec := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+
+	if startSpec == "" {
+		// suffix range, the last n bytes
+		n, err := strconv.ParseInt(endSpec, 10, 64)
+		if err != nil || n < 0 {
+			return nil, nil
+		}
+		if n == 0 || size == 0 {
+			return nil, errRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return &ByteRange{Start: size - n, End: size - 1}, nil
+	}
+
+	start, err := strconv.ParseInt(startSpec, 10, 64)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+	end := size - 1
+	if endSpec != "" {
+		end, err = strconv.ParseInt(endSpec, 10, 64)
+		if err != nil || end < start {
+			return nil, nil
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return nil, errRangeNotSatisfiable
+	}
+	return &ByteRange{Start: start, End: end}, nil
+}
@@ -0,0 +1,76 @@
+package file
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header string
+		want   *ByteRange
+		err    error
+	}{
+		{"bytes=0-99", &ByteRange{0, 99}, nil},
+		{"bytes=100-", &ByteRange{100, 999}, nil},
+		{"bytes=900-2000", &ByteRange{900, 999}, nil},
+		{"bytes=-100", &ByteRange{900, 999}, nil},
+		{"bytes=-2000", &ByteRange{0, 999}, nil},
+		{"bytes=1000-", nil, errRangeNotSatisfiable},
+		{"bytes=-0", nil, errRangeNotSatisfiable},
+		{"bytes=0-1,5-6", nil, nil},
+		{"bytes=5-1", nil, nil},
+		{"items=0-1", nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := parseRange(tt.header, 1000)
+		if err != tt.err {
+			t.Errorf("parseRange(%q) error = %v, want %v", tt.header, err, tt.err)
+		}
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("parseRange(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	modified := time.Date(2021, 4, 1, 10, 0, 0, 500, time.UTC)
+	f := &File{Size: 1000, SHA256: "abc", CreatedAt: modified}
+
+	// Test scenario:
+	// 1. cached copy with the same etag or date is not sent again
+	// 2. If-None-Match wins over If-Modified-Since
+	req := httptest.NewRequest(http.MethodGet, "/api/files/1", nil)
+	req.Header.Set("If-None-Match", `W/"old", "abc"`)
+	if !notModified(req, f) {
+		t.Error("matching If-None-Match must be not modified")
+	}
+	req.Header.Set("If-None-Match", `"old"`)
+	req.Header.Set("If-Modified-Since", modified.Format(http.TimeFormat))
+	if notModified(req, f) {
+		t.Error("not matching If-None-Match must be modified")
+	}
+	req.Header.Del("If-None-Match")
+	if !notModified(req, f) {
+		t.Error("If-Modified-Since equal to Last-Modified must be not modified")
+	}
+
+	// Test scenario:
+	// 1. download is resumed only if the file is not changed since the first part
+	req = httptest.NewRequest(http.MethodGet, "/api/files/1", nil)
+	req.Header.Set("Range", "bytes=500-")
+	req.Header.Set("If-Range", `"abc"`)
+	if rng, _ := requestedRange(req, f); rng == nil || *rng != (ByteRange{500, 999}) {
+		t.Errorf("range with fresh If-Range = %v", rng)
+	}
+	req.Header.Set("If-Range", modified.Format(http.TimeFormat))
+	if rng, _ := requestedRange(req, f); rng == nil {
+		t.Error("range with fresh If-Range date must be served")
+	}
+	req.Header.Set("If-Range", `"changed"`)
+	if rng, _ := requestedRange(req, f); rng != nil {
+		t.Errorf("range with stale If-Range = %v, want whole file", rng)
+	}
+}
@@ -0,0 +1,101 @@
+package file
+
+import (
+	"context"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"io"
+	"time"
+)
+
+// Uploaded files are recorded in quarantine and scanned right after they are stored. A clean file is released,
+// an infected one is deleted. When the scanner fails the file stays in quarantine until RescanQuarantined.
+const (
+	StatusQuarantined = "quarantined"
+	StatusClean       = "clean"
+)
+
+// Scanner checks content of uploaded files for malware
+type Scanner interface {
+	Scan(ctx context.Context, reader io.Reader) (ScanResult, error)
+}
+
+// ScanResult has the name of the found threat when the content is infected
+type ScanResult struct {
+	Infected bool
+	Threat   string
+}
+
+// initialStatus is the status of a new file, files are clean from the start when there is no scanner
+func (s *service) initialStatus() string {
+	if s.scanner == nil {
+		return StatusClean
+	}
+	return StatusQuarantined
+}
+
+// scan checks the stored file, ErrFileInfected means the file is deleted
+func (s *service) scan(ctx context.Context, f *File) error {
+	if s.scanner == nil {
+		return nil
+	}
+	reader, err := s.storage.GetFile(ctx, BlobsBucket, f.SHA256, nil)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	start := time.Now()
+	result, err := s.scanner.Scan(ctx, reader)
+	if err != nil {
+		return err
+	}
+	if result.Infected {
+		s.logger.Warnf("file %s %q of note %s uploaded by user %s is infected with %s, deleting it",
+			f.ID, f.OriginalName, f.NoteUUID, f.Uploader, result.Threat)
+		if err = s.files.Delete(ctx, f.NoteUUID, f.ID); err != nil {
+			return err
+		}
+		s.releaseBlob(ctx, f.SHA256)
+		return apperror.ErrFileInfected
+	}
+	s.logger.Debugf("file %s of %d bytes is clean, scanned in %s", f.ID, f.Size, time.Since(start))
+	if err = s.files.SetStatus(ctx, f.NoteUUID, f.ID, StatusClean); err != nil {
+		return err
+	}
+	f.Status = StatusClean
+	return nil
+}
+
+// scanUploaded scans a just uploaded file, failures of the scanner are logged and leave the file in quarantine
+func (s *service) scanUploaded(ctx context.Context, f *File) error {
+	err := s.scan(ctx, f)
+	if err != nil && err != apperror.ErrFileInfected {
+		s.logger.Errorf("failed to scan file %s, it stays in quarantine. err: %v", f.ID, err)
+		return nil
+	}
+	return err
+}
+
+// RescanQuarantined scans files left in quarantine by failures of the scanner, files younger than grace
+// may be being scanned by their uploads and are skipped
+func (s *service) RescanQuarantined(ctx context.Context, grace time.Duration) (int, error) {
+	if s.scanner == nil {
+		return 0, nil
+	}
+	files, err := s.files.FindQuarantined(ctx, time.Now().Add(-grace))
+	if err != nil {
+		return 0, err
+	}
+	scanned := 0
+	for _, f := range files {
+		err = s.scan(ctx, f)
+		if err == apperror.ErrFileInfected {
+			s.releaseUsage(ctx, []*File{f})
+		} else if err != nil {
+			s.logger.Errorf("failed to scan quarantined file %s. err: %v", f.ID, err)
+			continue
+		}
+		scanned++
+	}
+	return scanned, nil
+}
@@ -0,0 +1,124 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// fakeScanner finds the threat in content containing "virus" and fails while err is set
+type fakeScanner struct {
+	err     error
+	scanned int
+}
+
+func (s *fakeScanner) Scan(ctx context.Context, reader io.Reader) (ScanResult, error) {
+	if s.err != nil {
+		return ScanResult{}, s.err
+	}
+	s.scanned++
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	if bytes.Contains(content, []byte("virus")) {
+		return ScanResult{Infected: true, Threat: "Test-Virus"}, nil
+	}
+	return ScanResult{}, nil
+}
+
+func create(s *service, content string) error {
+	dto := CreateFileDTO{UserUUID: "user", Name: "a.txt", Size: -1, Reader: bytes.NewReader([]byte(content))}
+	return s.Create(context.Background(), "note", dto)
+}
+
+// Test scenario:
+// 1. clean file is released from quarantine and counted to usage
+// 2. infected file is rejected, its record and blob are deleted and it is not counted to usage
+func TestScan(t *testing.T) {
+	s, fakes := newTestService(t, 0)
+	s.scanner = &fakeScanner{}
+
+	if err := create(s, "notes"); err != nil {
+		t.Fatal(err)
+	}
+	f := fakes.files.only(t)
+	if f.Status != StatusClean {
+		t.Errorf("status = %q, want clean", f.Status)
+	}
+
+	if err := create(s, "a virus"); !errors.Is(err, apperror.ErrFileInfected) {
+		t.Errorf("infected file: error = %v, want infected", err)
+	}
+	fakes.files.only(t)
+	if len(fakes.blobs) != 1 {
+		t.Errorf("%d blobs are referenced, want 1", len(fakes.blobs))
+	}
+	if fakes.usage["user"].Used != int64(len("notes")) {
+		t.Errorf("usage = %d, want %d", fakes.usage["user"].Used, len("notes"))
+	}
+}
+
+// Test scenario:
+// 1. file uploaded while the scanner fails is accepted and stays in quarantine
+// 2. quarantined files are scanned again when the scanner works, infected ones are deleted and released from usage
+func TestRescanQuarantined(t *testing.T) {
+	ctx := context.Background()
+	s, fakes := newTestService(t, 0)
+	scanner := &fakeScanner{err: errors.New("clamd is down")}
+	s.scanner = scanner
+
+	if err := create(s, "notes"); err != nil {
+		t.Fatal(err)
+	}
+	if err := create(s, "a virus"); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fakes.files.files {
+		if !f.Quarantined() {
+			t.Errorf("file %s is not quarantined", f.ID)
+		}
+	}
+
+	scanned, err := s.RescanQuarantined(ctx, 0)
+	if err != nil || scanned != 0 {
+		t.Errorf("rescan with failing scanner: scanned = %d, error = %v", scanned, err)
+	}
+
+	scanner.err = nil
+	scanned, err = s.RescanQuarantined(ctx, 0)
+	if err != nil || scanned != 2 {
+		t.Fatalf("scanned = %d, error = %v", scanned, err)
+	}
+	if f := fakes.files.only(t); f.Status != StatusClean {
+		t.Errorf("status = %q, want clean", f.Status)
+	}
+	if fakes.usage["user"].Used != int64(len("notes")) {
+		t.Errorf("usage = %d, want %d", fakes.usage["user"].Used, len("notes"))
+	}
+}
+
+// Test scenario:
+// 1. resumable upload of an infected file is rejected with the last chunk and leaves no file
+func TestScanUpload(t *testing.T) {
+	ctx := context.Background()
+	s, fakes := newTestService(t, 0)
+	s.scanner = &fakeScanner{}
+	content := []byte("a virus")
+
+	upload, err := s.CreateUpload(ctx, CreateUploadDTO{NoteUUID: "note", UserUUID: "user", Name: "a.txt", Length: int64(len(content))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dto := UploadChunkDTO{Offset: 0, Size: int64(len(content)), Reader: bytes.NewReader(content)}
+	if _, err = s.WriteChunk(ctx, upload.ID, "user", dto); !errors.Is(err, apperror.ErrFileInfected) {
+		t.Errorf("infected upload: error = %v, want infected", err)
+	}
+	if len(fakes.files.files) != 0 {
+		t.Errorf("%d files are recorded, want none", len(fakes.files.files))
+	}
+}
@@ -0,0 +1,132 @@
+package clamav
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+var _ file.Scanner = &scanner{}
+
+// Content is sent to clamd with INSTREAM: chunks prefixed with their length as 4 byte big endian integer,
+// a chunk of zero length ends the stream. The z prefix makes the command and the reply null terminated.
+const (
+	instreamCommand = "zINSTREAM\x00"
+	chunkSize       = 64 << 10
+	dialTimeout     = 5 * time.Second
+
+	replyOK     = "stream: OK"
+	replyPrefix = "stream: "
+	foundSuffix = " FOUND"
+	errorSuffix = " ERROR"
+)
+
+// scanner sends content to clamd over TCP, clamd drops streams longer than its StreamMaxLength with an error
+type scanner struct {
+	address string
+	timeout time.Duration
+	logger  logging.Logger
+}
+
+func NewScanner(address string, timeout time.Duration, logger logging.Logger) file.Scanner {
+	return &scanner{
+		address: address,
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+func (s *scanner) Scan(ctx context.Context, reader io.Reader) (file.ScanResult, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return file.ScanResult{}, fmt.Errorf("failed to connect to clamd. err: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		return file.ScanResult{}, fmt.Errorf("failed to set deadline. err: %w", err)
+	}
+	// the deadline doesn't follow cancellation of the context, closing the connection does
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err = sendStream(conn, reader); err != nil {
+		// clamd replies and closes the connection when the stream is too long, the reply tells why
+		if reply, replyErr := readReply(conn); replyErr == nil {
+			return parseReply(reply)
+		}
+		return file.ScanResult{}, err
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return file.ScanResult{}, err
+	}
+	return parseReply(reply)
+}
+
+func sendStream(conn net.Conn, reader io.Reader) error {
+	if _, err := io.WriteString(conn, instreamCommand); err != nil {
+		return fmt.Errorf("failed to send command to clamd. err: %w", err)
+	}
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := io.ReadFull(reader, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, writeErr := conn.Write(buf[:4+n]); writeErr != nil {
+				return fmt.Errorf("failed to send content to clamd. err: %w", writeErr)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read content. err: %w", err)
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return fmt.Errorf("failed to end stream to clamd. err: %w", err)
+	}
+	return nil
+}
+
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && (err != io.EOF || reply == "") {
+		return "", fmt.Errorf("failed to read reply of clamd. err: %w", err)
+	}
+	return strings.TrimSpace(strings.TrimRight(reply, "\x00")), nil
+}
+
+// parseReply reads "stream: OK", "stream: <threat> FOUND" or "<reason> ERROR"
+func parseReply(reply string) (file.ScanResult, error) {
+	switch {
+	case reply == replyOK:
+		return file.ScanResult{}, nil
+	case strings.HasSuffix(reply, foundSuffix):
+		threat := strings.TrimSuffix(strings.TrimPrefix(reply, replyPrefix), foundSuffix)
+		return file.ScanResult{Infected: true, Threat: threat}, nil
+	case strings.HasSuffix(reply, errorSuffix):
+		return file.ScanResult{}, fmt.Errorf("clamd failed to scan: %s", strings.TrimSuffix(reply, errorSuffix))
+	}
+	return file.ScanResult{}, fmt.Errorf("unexpected reply of clamd: %q", reply)
+}
@@ -0,0 +1,127 @@
+package clamav
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"github.com/sirupsen/logrus"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd answers INSTREAM like clamd, content containing "virus" is infected, content over maxLength is an error
+func fakeClamd(t *testing.T, maxLength int) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn, maxLength)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serve(conn net.Conn, maxLength int) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	command, err := reader.ReadString(0)
+	if err != nil || command != instreamCommand {
+		io.WriteString(conn, "UNKNOWN COMMAND\x00")
+		return
+	}
+	var content []byte
+	for {
+		var size uint32
+		if err = binary.Read(reader, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		chunk := make([]byte, size)
+		if _, err = io.ReadFull(reader, chunk); err != nil {
+			return
+		}
+		content = append(content, chunk...)
+		if len(content) > maxLength {
+			io.WriteString(conn, "INSTREAM size limit exceeded. ERROR\x00")
+			return
+		}
+	}
+	if bytes.Contains(content, []byte("virus")) {
+		io.WriteString(conn, "stream: Test.Virus FOUND\x00")
+		return
+	}
+	io.WriteString(conn, "stream: OK\x00")
+}
+
+func TestScan(t *testing.T) {
+	address := fakeClamd(t, 1<<20)
+	s := NewScanner(address, time.Second, logging.Logger{Entry: logrus.NewEntry(logrus.New())})
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		content  string
+		infected bool
+		threat   string
+		err      bool
+	}{
+		{"clean", "notes", false, "", false},
+		{"empty", "", false, "", false},
+		{"infected in a later chunk", strings.Repeat("a", chunkSize) + "virus", true, "Test.Virus", false},
+		{"over stream limit", strings.Repeat("a", 2<<20), false, "", true},
+	}
+	for _, tt := range tests {
+		result, err := s.Scan(ctx, strings.NewReader(tt.content))
+		if (err != nil) != tt.err || result.Infected != tt.infected || result.Threat != tt.threat {
+			t.Errorf("%s: result = %+v, error = %v", tt.name, result, err)
+		}
+	}
+}
+
+func TestScanUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	s := NewScanner(address, time.Second, logging.Logger{Entry: logrus.NewEntry(logrus.New())})
+	if _, err = s.Scan(context.Background(), strings.NewReader("notes")); err == nil {
+		t.Error("scan without clamd succeeded")
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		reply    string
+		infected bool
+		threat   string
+		err      bool
+	}{
+		{"stream: OK", false, "", false},
+		{"stream: Win.Test.EICAR_HDB-1 FOUND", true, "Win.Test.EICAR_HDB-1", false},
+		{"INSTREAM size limit exceeded. ERROR", false, "", true},
+		{"PONG", false, "", true},
+	}
+	for _, tt := range tests {
+		result, err := parseReply(tt.reply)
+		if (err != nil) != tt.err || result.Infected != tt.infected || result.Threat != tt.threat {
+			t.Errorf("%q: result = %+v, error = %v", tt.reply, result, err)
+		}
+	}
+}
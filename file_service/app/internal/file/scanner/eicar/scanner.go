@@ -0,0 +1,41 @@
+package eicar
+
+import (
+	"bytes"
+	"context"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"io"
+)
+
+var _ file.Scanner = &scanner{}
+
+// Signature is the EICAR anti-malware test file, antiviruses detect it as a virus though it is harmless.
+// The file starts with the signature which may be followed by whitespace up to maxFileSize bytes.
+const (
+	Signature   = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+	Threat      = "Eicar-Test-Signature"
+	maxFileSize = 128
+)
+
+// scanner finds nothing but the EICAR test file, it checks quarantine of uploads without an antivirus
+type scanner struct{}
+
+func NewScanner() file.Scanner {
+	return &scanner{}
+}
+
+func (s *scanner) Scan(ctx context.Context, reader io.Reader) (file.ScanResult, error) {
+	head := make([]byte, maxFileSize+1)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return file.ScanResult{}, err
+	}
+	head = head[:n]
+	if n > maxFileSize || !bytes.HasPrefix(head, []byte(Signature)) {
+		return file.ScanResult{}, nil
+	}
+	if len(bytes.TrimSpace(head[len(Signature):])) > 0 {
+		return file.ScanResult{}, nil
+	}
+	return file.ScanResult{Infected: true, Threat: Threat}, nil
+}
@@ -0,0 +1,29 @@
+package eicar
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		infected bool
+	}{
+		{"test file", Signature, true},
+		{"test file with trailing whitespace", Signature + "\r\n", true},
+		{"signature in the middle", "notes " + Signature, false},
+		{"signature followed by content", Signature + " notes", false},
+		{"long file", Signature + strings.Repeat(" ", maxFileSize), false},
+		{"clean", "notes", false},
+	}
+	s := NewScanner()
+	for _, tt := range tests {
+		result, err := s.Scan(context.Background(), strings.NewReader(tt.content))
+		if err != nil || result.Infected != tt.infected {
+			t.Errorf("%s: result = %+v, error = %v", tt.name, result, err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package scanner
+
+import (
+	"fmt"
+	"github.com/theartofdevel/notes_system/file_service/internal/config"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"github.com/theartofdevel/notes_system/file_service/internal/file/scanner/clamav"
+	"github.com/theartofdevel/notes_system/file_service/internal/file/scanner/eicar"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"time"
+)
+
+const (
+	TypeNone   = "none"
+	TypeClamAV = "clamav"
+	TypeEICAR  = "eicar"
+)
+
+// NewScanner returns the scanner of uploads selected by the config, it is nil when uploads are not scanned
+func NewScanner(cfg *config.Config, logger logging.Logger) (file.Scanner, error) {
+	switch cfg.Scanner.Type {
+	case TypeNone:
+		logger.Warn("uploaded files are not scanned for malware")
+		return nil, nil
+	case TypeClamAV:
+		if cfg.Scanner.Address == "" {
+			return nil, fmt.Errorf("address is required for %s scanner", TypeClamAV)
+		}
+		return clamav.NewScanner(cfg.Scanner.Address, time.Duration(cfg.Scanner.Timeout)*time.Second, logger), nil
+	case TypeEICAR:
+		logger.Warn("uploaded files are checked for the EICAR test file only")
+		return eicar.NewScanner(), nil
+	}
+	return nil, fmt.Errorf("unknown scanner type %q", cfg.Scanner.Type)
+}
@@ -1,41 +1,103 @@
 package file
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
 	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"hash"
+	"io"
+	"net/http"
+	"time"
 )
 
 var _ Service = &service{}
 
+// sniffLen is how many bytes http.DetectContentType looks at
+const sniffLen = 512
+
 type service struct {
 	storage Storage
+	// legacy is nil when the storage has no note buckets, see legacy.go
+	legacy  LegacyStorage
+	files   MetaStorage
+	blobs   BlobStorage
+	uploads UploadStorage
+	usage   UsageStorage
+	// scanner is nil when uploads are not scanned
+	scanner Scanner
+	limits  Limits
 	logger  logging.Logger
 }
 
-func NewService(noteStorage Storage, logger logging.Logger) (Service, error) {
+func NewService(noteStorage Storage, metaStorage MetaStorage, blobStorage BlobStorage, uploadStorage UploadStorage, usageStorage UsageStorage, scanner Scanner, limits Limits, logger logging.Logger) (Service, error) {
+	if limits.ChunkSize < MinChunkSize {
+		return nil, fmt.Errorf("chunk size must be at least %d bytes", MinChunkSize)
+	}
+	legacy, _ := noteStorage.(LegacyStorage)
 	return &service{
 		storage: noteStorage,
+		legacy:  legacy,
+		files:   metaStorage,
+		blobs:   blobStorage,
+		uploads: uploadStorage,
+		usage:   usageStorage,
+		scanner: scanner,
+		limits:  limits,
 		logger:  logger,
 	}, nil
 }
 
 type Service interface {
-	GetFile(ctx context.Context, noteUUID, fileName string) (f *File, err error)
+	GetFileInfo(ctx context.Context, noteUUID, fileName string) (*File, error)
+	GetFile(ctx context.Context, noteUUID string, f *File, rng *ByteRange) (io.ReadCloser, error)
 	GetFilesByNoteUUID(ctx context.Context, noteUUID string) ([]*File, error)
+	GetThumbnail(ctx context.Context, f *File, size ThumbnailSize) (io.ReadCloser, error)
 	Create(ctx context.Context, noteUUID string, dto CreateFileDTO) error
 	Delete(ctx context.Context, noteUUID, fileName string) error
+	DeleteFilesByNoteUUID(ctx context.Context, noteUUID string) (int, error)
+
+	CreateUpload(ctx context.Context, dto CreateUploadDTO) (Upload, error)
+	GetUpload(ctx context.Context, id, userUUID string) (Upload, error)
+	WriteChunk(ctx context.Context, id, userUUID string, dto UploadChunkDTO) (Upload, error)
+	AbortUpload(ctx context.Context, id, userUUID string) error
+	AbortExpiredUploads(ctx context.Context) (int, error)
+
+	GetUsage(ctx context.Context, userUUID string) (Usage, error)
+	SetPlan(ctx context.Context, userUUID string, dto SetPlanDTO) error
+
+	RescanQuarantined(ctx context.Context, grace time.Duration) (int, error)
+	CollectGarbage(ctx context.Context, grace time.Duration) (GCReport, error)
+	ImportLegacyBuckets(ctx context.Context) (MigrationReport, error)
 }
 
-func (s *service) GetFile(ctx context.Context, noteUUID, fileId string) (f *File, err error) {
-	f, err = s.storage.GetFile(ctx, noteUUID, fileId)
+func (s *service) GetFileInfo(ctx context.Context, noteUUID, fileId string) (*File, error) {
+	f, err := s.findOne(ctx, noteUUID, fileId)
 	if err != nil {
-		return f, err
+		return nil, err
 	}
 	return f, nil
 }
 
+func (s *service) GetFile(ctx context.Context, noteUUID string, f *File, rng *ByteRange) (io.ReadCloser, error) {
+	reader, err := s.storage.GetFile(ctx, BlobsBucket, f.SHA256, rng)
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
 func (s *service) GetFilesByNoteUUID(ctx context.Context, noteUUID string) ([]*File, error) {
-	files, err := s.storage.GetFilesByNoteUUID(ctx, noteUUID)
+	if _, err := s.importLegacy(ctx, noteUUID); err != nil {
+		return nil, err
+	}
+	files, err := s.files.FindByNoteUUID(ctx, noteUUID)
 	if err != nil {
 		return nil, err
 	}
@@ -43,22 +105,393 @@ func (s *service) GetFilesByNoteUUID(ctx context.Context, noteUUID string) ([]*F
 }
 
 func (s *service) Create(ctx context.Context, noteUUID string, dto CreateFileDTO) error {
-	dto.NormalizeName()
+	if dto.UserUUID == "" {
+		return apperror.BadRequestError("user_uuid is required")
+	}
+	reserved, limitErr, err := s.reserveUpload(ctx, dto.UserUUID, dto.Size)
+	if err != nil {
+		return err
+	}
+	var stored int64
+	defer func() { s.settleUpload(dto.UserUUID, reserved, stored) }()
+	// size of a streamed upload is not known until it is read
+	limited := &limitedReader{reader: dto.Reader, left: reserved, err: limitErr}
+	checksum := sha256.New()
+	var sniffed string
+	dto.ContentType, sniffed, dto.Reader = detectContentType(dto.ContentType, io.TeeReader(limited, checksum))
+	if err = s.limits.checkContentType(dto.ContentType, sniffed); err != nil {
+		s.logger.Warnf("user %s uploaded file %q of type %s sniffed as %s", dto.UserUUID, dto.Name, dto.ContentType, sniffed)
+		return err
+	}
+
 	file, err := NewFile(dto)
 	if err != nil {
 		return err
 	}
-	err = s.storage.CreateFile(ctx, noteUUID, file)
+	incoming := incomingFile(file)
+	err = s.storage.CreateFile(ctx, BlobsBucket, incoming)
 	if err != nil {
+		if limited.exceeded() {
+			return limitErr
+		}
+		return err
+	}
+
+	file.NoteUUID = noteUUID
+	file.Size = limited.read
+	file.SHA256 = hex.EncodeToString(checksum.Sum(nil))
+	file.CreatedAt = time.Now().UTC()
+	file.Status = s.initialStatus()
+	if err = s.storeBlob(ctx, incoming.ID, file.SHA256, file.Size); err != nil {
+		return err
+	}
+	if err = s.files.Create(ctx, file); err != nil {
+		s.releaseBlob(ctx, file.SHA256)
+		return err
+	}
+	if err = s.scanUploaded(ctx, file); err != nil {
 		return err
 	}
+	stored = limited.read
 	return nil
 }
 
 func (s *service) Delete(ctx context.Context, noteUUID, fileName string) error {
-	err := s.storage.DeleteFile(ctx, noteUUID, fileName)
+	f, err := s.findOne(ctx, noteUUID, fileName)
 	if err != nil {
 		return err
 	}
+	if err = s.files.Delete(ctx, noteUUID, fileName); err != nil {
+		return err
+	}
+	s.releaseBlob(ctx, f.SHA256)
+	s.releaseUsage(ctx, []*File{f})
 	return nil
-}
\ No newline at end of file
+}
+
+func (s *service) DeleteFilesByNoteUUID(ctx context.Context, noteUUID string) (int, error) {
+	files, err := s.GetFilesByNoteUUID(ctx, noteUUID)
+	if err != nil && err != apperror.ErrNotFound {
+		return 0, err
+	}
+	deleted, err := s.files.DeleteByNoteUUID(ctx, noteUUID)
+	if err != nil {
+		return deleted, err
+	}
+	for _, f := range files {
+		s.releaseBlob(ctx, f.SHA256)
+	}
+	s.releaseUsage(ctx, files)
+	return deleted, nil
+}
+
+// CreateUpload starts a resumable upload, the whole length is reserved in the quota until the upload is finished
+func (s *service) CreateUpload(ctx context.Context, dto CreateUploadDTO) (Upload, error) {
+	if dto.NoteUUID == "" || dto.UserUUID == "" {
+		return Upload{}, apperror.BadRequestError("note_uuid and user_uuid are required")
+	}
+	if dto.Length <= 0 {
+		return Upload{}, apperror.BadRequestError("upload length must be positive")
+	}
+	// the content is checked again with the first chunk
+	if dto.ContentType != "" {
+		if err := s.limits.checkContentType(dto.ContentType, ""); err != nil {
+			return Upload{}, err
+		}
+	}
+	if _, _, err := s.reserveUpload(ctx, dto.UserUUID, dto.Length); err != nil {
+		return Upload{}, err
+	}
+	upload, err := s.createUpload(ctx, dto)
+	if err != nil {
+		s.settleUpload(dto.UserUUID, dto.Length, 0)
+		return Upload{}, err
+	}
+	return upload, nil
+}
+
+// createUpload starts the multipart upload in the storage and records it, the length is reserved by the caller
+func (s *service) createUpload(ctx context.Context, dto CreateUploadDTO) (Upload, error) {
+	fileDTO := CreateFileDTO{UserUUID: dto.UserUUID, Name: dto.Name, Size: dto.Length, ContentType: dto.ContentType}
+	if fileDTO.ContentType == "" {
+		fileDTO.ContentType = "application/octet-stream"
+	}
+	f, err := NewFile(fileDTO)
+	if err != nil {
+		return Upload{}, err
+	}
+	hashState, err := sha256.New().(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return Upload{}, err
+	}
+	storageUploadID, err := s.storage.CreateMultipartUpload(ctx, BlobsBucket, incomingFile(f))
+	if err != nil {
+		return Upload{}, err
+	}
+
+	upload := Upload{
+		ID:              uuid.New().String(),
+		NoteUUID:        dto.NoteUUID,
+		UserUUID:        dto.UserUUID,
+		FileID:          f.ID,
+		Name:            f.OriginalName,
+		ContentType:     f.ContentType,
+		Length:          dto.Length,
+		ChunkSize:       s.limits.ChunkSize,
+		PartETags:       []string{},
+		StorageUploadID: storageUploadID,
+		HashState:       hashState,
+		ExpiresAt:       time.Now().Add(s.limits.UploadTTL),
+		Reserved:        true,
+	}
+	if err = s.uploads.Create(ctx, upload); err != nil {
+		if abortErr := s.storage.AbortMultipartUpload(ctx, BlobsBucket, incomingName(f.ID), storageUploadID); abortErr != nil {
+			s.logger.Errorf("failed to abort upload of file %s. err: %v", f.ID, abortErr)
+		}
+		return Upload{}, err
+	}
+	return upload, nil
+}
+
+// GetUpload returns upload of the user, uploads of other users are not found
+func (s *service) GetUpload(ctx context.Context, id, userUUID string) (Upload, error) {
+	upload, err := s.uploads.FindOne(ctx, id)
+	if err != nil {
+		return Upload{}, err
+	}
+	if upload.UserUUID != userUUID {
+		return Upload{}, apperror.ErrNotFound
+	}
+	return upload, nil
+}
+
+// WriteChunk writes the chunk at the upload offset and finishes the upload with the last chunk.
+// An empty chunk at the end retries finishing if it failed before.
+func (s *service) WriteChunk(ctx context.Context, id, userUUID string, dto UploadChunkDTO) (Upload, error) {
+	upload, err := s.GetUpload(ctx, id, userUUID)
+	if err != nil {
+		return Upload{}, err
+	}
+	if dto.Offset != upload.Offset {
+		return upload, apperror.ErrUploadOffsetMismatch
+	}
+	if upload.Completed() && dto.Size == 0 {
+		return upload, s.finishUpload(ctx, upload)
+	}
+	if expected := upload.nextChunkSize(); dto.Size != expected {
+		return upload, apperror.BadRequestError(fmt.Sprintf("chunk at offset %d must be %d bytes", upload.Offset, expected))
+	}
+
+	checksum, err := restoreChecksum(upload)
+	if err != nil {
+		return upload, err
+	}
+	reader := io.TeeReader(dto.Reader, checksum)
+	contentType := upload.ContentType
+	if upload.Offset == 0 {
+		var sniffed string
+		contentType, sniffed, reader = detectContentType(contentType, reader)
+		if err = s.limits.checkContentType(contentType, sniffed); err != nil {
+			s.logger.Warnf("user %s uploaded file %q of type %s sniffed as %s", userUUID, upload.Name, contentType, sniffed)
+			if abortErr := s.abortUpload(ctx, upload); abortErr != nil {
+				s.logger.Errorf("failed to abort upload %s. err: %v", upload.ID, abortErr)
+			}
+			return upload, err
+		}
+	}
+
+	number := len(upload.PartETags) + 1
+	etag, err := s.storage.PutPart(ctx, BlobsBucket, incomingName(upload.FileID), upload.StorageUploadID, number, reader, dto.Size)
+	if err != nil {
+		return upload, err
+	}
+	hashState, err := checksum.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return upload, err
+	}
+	part := UploadPart{
+		Size:        dto.Size,
+		ETag:        etag,
+		ContentType: contentType,
+		HashState:   hashState,
+		ExpiresAt:   time.Now().Add(s.limits.UploadTTL),
+	}
+	ok, err := s.uploads.AddPart(ctx, upload.ID, upload.Offset, part)
+	if err != nil {
+		return upload, err
+	}
+	if !ok {
+		return upload, apperror.ErrUploadOffsetMismatch
+	}
+	upload.Offset += dto.Size
+	upload.PartETags = append(upload.PartETags, etag)
+	upload.ContentType = contentType
+	upload.HashState = hashState
+
+	if upload.Completed() {
+		return upload, s.finishUpload(ctx, upload)
+	}
+	return upload, nil
+}
+
+func (s *service) AbortUpload(ctx context.Context, id, userUUID string) error {
+	upload, err := s.GetUpload(ctx, id, userUUID)
+	if err != nil {
+		return err
+	}
+	return s.abortUpload(ctx, upload)
+}
+
+// AbortExpiredUploads removes uploads which got no chunks for the upload TTL
+func (s *service) AbortExpiredUploads(ctx context.Context) (int, error) {
+	uploads, err := s.uploads.FindExpired(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	aborted := 0
+	for _, upload := range uploads {
+		if err = s.abortUpload(ctx, upload); err != nil {
+			s.logger.Errorf("failed to abort expired upload %s. err: %v", upload.ID, err)
+			continue
+		}
+		aborted++
+	}
+	return aborted, nil
+}
+
+func (s *service) finishUpload(ctx context.Context, upload Upload) error {
+	checksum, err := restoreChecksum(upload)
+	if err != nil {
+		return err
+	}
+	incoming := incomingName(upload.FileID)
+	err = s.storage.CompleteMultipartUpload(ctx, BlobsBucket, incoming, upload.StorageUploadID, upload.PartETags)
+	if err != nil {
+		return err
+	}
+	f := &File{
+		ID:           upload.FileID,
+		NoteUUID:     upload.NoteUUID,
+		Name:         normalizeName(upload.Name),
+		OriginalName: upload.Name,
+		Size:         upload.Length,
+		ContentType:  upload.ContentType,
+		SHA256:       hex.EncodeToString(checksum.Sum(nil)),
+		Uploader:     upload.UserUUID,
+		CreatedAt:    time.Now().UTC(),
+		Status:       s.initialStatus(),
+	}
+	if err = s.storeBlob(ctx, incoming, f.SHA256, f.Size); err != nil {
+		return err
+	}
+	if err = s.files.Create(ctx, f); err != nil {
+		s.releaseBlob(ctx, f.SHA256)
+		return err
+	}
+	if err = s.uploads.Delete(ctx, upload.ID); err != nil {
+		return err
+	}
+	if err = s.scanUploaded(ctx, f); err != nil {
+		s.settleReserved(upload, 0)
+		return err
+	}
+	s.settleReserved(upload, upload.Length)
+	s.logger.Infof("upload %s of file %s to note %s is finished", upload.ID, upload.FileID, upload.NoteUUID)
+	return nil
+}
+
+func (s *service) abortUpload(ctx context.Context, upload Upload) error {
+	err := s.storage.AbortMultipartUpload(ctx, BlobsBucket, incomingName(upload.FileID), upload.StorageUploadID)
+	if err != nil {
+		return err
+	}
+	if err = s.uploads.Delete(ctx, upload.ID); err != nil {
+		return err
+	}
+	s.settleReserved(upload, 0)
+	return nil
+}
+
+// settleReserved settles the reservation of a deleted upload, uploads created before reservations count stored bytes only
+func (s *service) settleReserved(upload Upload, stored int64) {
+	if upload.Reserved {
+		s.settleUpload(upload.UserUUID, upload.Length, stored)
+		return
+	}
+	if stored > 0 {
+		s.settleUpload(upload.UserUUID, 0, stored)
+	}
+}
+
+// genericTypes are sniffed for many formats, like application/zip for docx, so they don't replace the type of the client
+var genericTypes = []string{"application/zip", "text/plain", "application/octet-stream"}
+
+// executableSignatures are magic bytes of executables http.DetectContentType takes for generic binary or text,
+// so denied types of executables are checked against the content and not only against the type of the client
+var executableSignatures = []struct {
+	prefix string
+	// generic is what http.DetectContentType sniffs, so a text starting with MZ is not taken for a program
+	generic     string
+	contentType string
+}{
+	{"MZ", "application/octet-stream", "application/x-msdownload"},
+	{"\x7fELF", "application/octet-stream", "application/x-executable"},
+	{"#!", "text/plain; charset=utf-8", "application/x-sh"},
+}
+
+// sniffContentType is http.DetectContentType which recognizes executables too
+func sniffContentType(head []byte) string {
+	sniffed := http.DetectContentType(head)
+	for _, signature := range executableSignatures {
+		if sniffed == signature.generic && bytes.HasPrefix(head, []byte(signature.prefix)) {
+			return signature.contentType
+		}
+	}
+	return sniffed
+}
+
+// detectContentType sniffs the type from the first bytes, the sniffed type is stored unless it is a generic one
+// and the client sent a specific type. The sniffed type is returned too, the returned reader yields the sniffed bytes again.
+func detectContentType(contentType string, reader io.Reader) (string, string, io.Reader) {
+	buffered := bufio.NewReaderSize(reader, sniffLen)
+	head, _ := buffered.Peek(sniffLen)
+	sniffed := sniffContentType(head)
+	if contentType == "" || contentType == "application/octet-stream" || !matchesType(genericTypes, sniffed) {
+		contentType = sniffed
+	}
+	return contentType, sniffed, buffered
+}
+
+// restoreChecksum continues SHA-256 of the chunks written before
+func restoreChecksum(upload Upload) (hash.Hash, error) {
+	checksum := sha256.New()
+	if err := checksum.(encoding.BinaryUnmarshaler).UnmarshalBinary(upload.HashState); err != nil {
+		return nil, fmt.Errorf("failed to restore checksum of upload %s. err: %w", upload.ID, err)
+	}
+	return checksum, nil
+}
+
+// limitedReader fails the upload once more than left bytes are read
+type limitedReader struct {
+	reader io.Reader
+	left   int64
+	read   int64
+	err    error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.exceeded() {
+		return 0, l.err
+	}
+	n, err := l.reader.Read(p)
+	l.read += int64(n)
+	l.left -= int64(n)
+	if l.exceeded() {
+		return n, l.err
+	}
+	return n, err
+}
+
+func (l *limitedReader) exceeded() bool {
+	return l.left < 0
+}
@@ -0,0 +1,483 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/sirupsen/logrus"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeObject struct {
+	content []byte
+	modTime time.Time
+}
+
+// fakeStorage keeps objects of all buckets by bucket and name
+type fakeStorage struct {
+	objects map[string]fakeObject
+	parts   map[string]map[int][]byte
+}
+
+func objectKey(bucketName, fileName string) string {
+	return bucketName + "/" + fileName
+}
+
+func (s *fakeStorage) GetFile(ctx context.Context, bucketName, fileName string, rng *ByteRange) (io.ReadCloser, error) {
+	object, ok := s.objects[objectKey(bucketName, fileName)]
+	if !ok {
+		return nil, apperror.ErrNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(object.content)), nil
+}
+
+func (s *fakeStorage) CreateFile(ctx context.Context, bucketName string, file *File) error {
+	content, err := ioutil.ReadAll(file.Reader)
+	if err != nil {
+		return err
+	}
+	s.objects[objectKey(bucketName, file.ID)] = fakeObject{content: content, modTime: time.Now()}
+	return nil
+}
+
+func (s *fakeStorage) FileExists(ctx context.Context, bucketName, fileName string) (bool, error) {
+	_, ok := s.objects[objectKey(bucketName, fileName)]
+	return ok, nil
+}
+
+func (s *fakeStorage) MoveFile(ctx context.Context, bucketName, fileName, newName string) error {
+	object, ok := s.objects[objectKey(bucketName, fileName)]
+	if !ok {
+		return apperror.ErrNotFound
+	}
+	delete(s.objects, objectKey(bucketName, fileName))
+	s.objects[objectKey(bucketName, newName)] = object
+	return nil
+}
+
+func (s *fakeStorage) DeleteFile(ctx context.Context, bucketName, fileName string) error {
+	delete(s.objects, objectKey(bucketName, fileName))
+	return nil
+}
+
+func (s *fakeStorage) ListFiles(ctx context.Context, bucketName string) (files []StoredFile, err error) {
+	for key, object := range s.objects {
+		if name := strings.TrimPrefix(key, bucketName+"/"); name != key {
+			files = append(files, StoredFile{Name: name, Size: int64(len(object.content)), ModTime: object.modTime})
+		}
+	}
+	return files, nil
+}
+
+func (s *fakeStorage) CreateMultipartUpload(ctx context.Context, bucketName string, file *File) (string, error) {
+	uploadID := "upload-" + file.ID
+	s.parts[uploadID] = make(map[int][]byte)
+	return uploadID, nil
+}
+
+func (s *fakeStorage) PutPart(ctx context.Context, bucketName, fileName, uploadID string, number int, reader io.Reader, size int64) (string, error) {
+	part, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	s.parts[uploadID][number] = part
+	return string(rune('a' + number)), nil
+}
+
+func (s *fakeStorage) CompleteMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string, etags []string) error {
+	var content []byte
+	for number := 1; number <= len(etags); number++ {
+		content = append(content, s.parts[uploadID][number]...)
+	}
+	delete(s.parts, uploadID)
+	s.objects[objectKey(bucketName, fileName)] = fakeObject{content: content, modTime: time.Now()}
+	return nil
+}
+
+func (s *fakeStorage) AbortMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string) error {
+	delete(s.parts, uploadID)
+	return nil
+}
+
+// blobNames are names of objects in BlobsBucket
+func (s *fakeStorage) blobNames() (names []string) {
+	files, _ := s.ListFiles(context.Background(), BlobsBucket)
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+type fakeMetaStorage struct {
+	files map[string]*File
+}
+
+func (s *fakeMetaStorage) Create(ctx context.Context, f *File) error {
+	s.files[f.ID] = f
+	return nil
+}
+
+func (s *fakeMetaStorage) FindOne(ctx context.Context, noteUUID, id string) (*File, error) {
+	f, ok := s.files[id]
+	if !ok || f.NoteUUID != noteUUID {
+		return nil, apperror.ErrNotFound
+	}
+	return f, nil
+}
+
+func (s *fakeMetaStorage) FindByNoteUUID(ctx context.Context, noteUUID string) (files []*File, err error) {
+	for _, f := range s.files {
+		if f.NoteUUID == noteUUID {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return nil, apperror.ErrNotFound
+	}
+	return files, nil
+}
+
+func (s *fakeMetaStorage) Delete(ctx context.Context, noteUUID, id string) error {
+	if _, err := s.FindOne(ctx, noteUUID, id); err != nil {
+		return err
+	}
+	delete(s.files, id)
+	return nil
+}
+
+func (s *fakeMetaStorage) DeleteByNoteUUID(ctx context.Context, noteUUID string) (deleted int, err error) {
+	for id, f := range s.files {
+		if f.NoteUUID == noteUUID {
+			delete(s.files, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *fakeMetaStorage) SetStatus(ctx context.Context, noteUUID, id, status string) error {
+	f, err := s.FindOne(ctx, noteUUID, id)
+	if err != nil {
+		return err
+	}
+	f.Status = status
+	return nil
+}
+
+func (s *fakeMetaStorage) FindQuarantined(ctx context.Context, before time.Time) (files []*File, err error) {
+	for _, f := range s.files {
+		if f.Quarantined() && f.CreatedAt.Before(before) {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+func (s *fakeMetaStorage) only(t *testing.T) *File {
+	if len(s.files) != 1 {
+		t.Fatalf("%d files are recorded, want 1", len(s.files))
+	}
+	for _, f := range s.files {
+		return f
+	}
+	return nil
+}
+
+// fakeBlobStorage keeps reference counts by checksum
+type fakeBlobStorage map[string]int64
+
+func (s fakeBlobStorage) AddRef(ctx context.Context, sha256 string, size int64) error {
+	s[sha256]++
+	return nil
+}
+
+func (s fakeBlobStorage) ReleaseRef(ctx context.Context, sha256 string) (bool, error) {
+	if _, ok := s[sha256]; !ok {
+		return false, nil
+	}
+	s[sha256]--
+	return s[sha256] == 0, nil
+}
+
+func (s fakeBlobStorage) Exists(ctx context.Context, sha256 string) (bool, error) {
+	_, ok := s[sha256]
+	return ok, nil
+}
+
+func (s fakeBlobStorage) Delete(ctx context.Context, sha256 string) error {
+	delete(s, sha256)
+	return nil
+}
+
+func (s fakeBlobStorage) ClaimUnreferenced(ctx context.Context) (claimed []string, err error) {
+	for sha256, refs := range s {
+		if refs <= 0 {
+			claimed = append(claimed, sha256)
+		}
+	}
+	return claimed, nil
+}
+
+type fakeUploadStorage struct {
+	uploads map[string]Upload
+}
+
+func (s *fakeUploadStorage) Create(ctx context.Context, upload Upload) error {
+	s.uploads[upload.ID] = upload
+	return nil
+}
+
+func (s *fakeUploadStorage) FindOne(ctx context.Context, id string) (Upload, error) {
+	upload, ok := s.uploads[id]
+	if !ok {
+		return Upload{}, apperror.ErrNotFound
+	}
+	return upload, nil
+}
+
+func (s *fakeUploadStorage) AddPart(ctx context.Context, id string, offset int64, part UploadPart) (bool, error) {
+	upload, ok := s.uploads[id]
+	if !ok || upload.Offset != offset {
+		return false, nil
+	}
+	upload.Offset += part.Size
+	upload.PartETags = append(upload.PartETags, part.ETag)
+	upload.ContentType = part.ContentType
+	upload.HashState = part.HashState
+	upload.ExpiresAt = part.ExpiresAt
+	s.uploads[id] = upload
+	return true, nil
+}
+
+func (s *fakeUploadStorage) Delete(ctx context.Context, id string) error {
+	delete(s.uploads, id)
+	return nil
+}
+
+func (s *fakeUploadStorage) FindExpired(ctx context.Context, now time.Time) (uploads []Upload, err error) {
+	for _, upload := range s.uploads {
+		if upload.ExpiresAt.Before(now) {
+			uploads = append(uploads, upload)
+		}
+	}
+	return uploads, nil
+}
+
+type fakeUsageStorage map[string]Usage
+
+func (s fakeUsageStorage) Get(ctx context.Context, userUUID string) (Usage, error) {
+	return s[userUUID], nil
+}
+
+func (s fakeUsageStorage) Add(ctx context.Context, userUUID string, delta int64) error {
+	usage := s[userUUID]
+	usage.Used += delta
+	s[userUUID] = usage
+	return nil
+}
+
+func (s fakeUsageStorage) Reserve(ctx context.Context, userUUID string, size, quota int64) (bool, error) {
+	usage := s[userUUID]
+	if quota > 0 && usage.Used+usage.Pending+size > quota {
+		return false, nil
+	}
+	usage.Pending += size
+	s[userUUID] = usage
+	return true, nil
+}
+
+func (s fakeUsageStorage) Settle(ctx context.Context, userUUID string, reserved, stored int64) error {
+	usage := s[userUUID]
+	usage.Pending -= reserved
+	usage.Used += stored
+	s[userUUID] = usage
+	return nil
+}
+
+func (s fakeUsageStorage) SetPlan(ctx context.Context, userUUID, plan string) error {
+	usage := s[userUUID]
+	usage.Plan = plan
+	s[userUUID] = usage
+	return nil
+}
+
+type fakes struct {
+	storage *fakeStorage
+	files   *fakeMetaStorage
+	blobs   fakeBlobStorage
+	usage   fakeUsageStorage
+}
+
+func newTestService(t *testing.T, quota int64) (*service, fakes) {
+	f := fakes{
+		storage: &fakeStorage{objects: map[string]fakeObject{}, parts: map[string]map[int][]byte{}},
+		files:   &fakeMetaStorage{files: map[string]*File{}},
+		blobs:   fakeBlobStorage{},
+		usage:   fakeUsageStorage{},
+	}
+	limits := Limits{MaxSize: 4 * MinChunkSize, ChunkSize: MinChunkSize, UploadTTL: time.Hour, UserQuota: quota}
+	s, err := NewService(f.storage, f.files, f.blobs, &fakeUploadStorage{uploads: map[string]Upload{}}, f.usage, nil, limits,
+		logging.Logger{Entry: logrus.NewEntry(logrus.New())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.(*service), f
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Test scenario:
+// 1. file without a specific type is recorded with the sniffed type, the normalized name and the checksum
+// 2. file larger than the quota left is rejected and not recorded
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	s, fakes := newTestService(t, MinChunkSize)
+	content := []byte("<html><body>notes</body></html>")
+
+	dto := CreateFileDTO{UserUUID: "user", Name: "Café notes.html", Size: -1, ContentType: "application/octet-stream",
+		Reader: bytes.NewReader(content)}
+	if err := s.Create(ctx, "note", dto); err != nil {
+		t.Fatal(err)
+	}
+	f := fakes.files.only(t)
+	if f.ContentType != "text/html; charset=utf-8" {
+		t.Errorf("content type = %q", f.ContentType)
+	}
+	if f.Name != "Cafe_notes.html" || f.OriginalName != "Café notes.html" {
+		t.Errorf("name = %q, original name = %q", f.Name, f.OriginalName)
+	}
+	if f.SHA256 != checksum(content) || f.Size != int64(len(content)) {
+		t.Errorf("file record = %+v", f)
+	}
+	if fakes.usage["user"].Used != int64(len(content)) {
+		t.Errorf("usage = %d, want %d", fakes.usage["user"].Used, len(content))
+	}
+
+	dto = CreateFileDTO{UserUUID: "user", Name: "big.bin", Size: -1, Reader: bytes.NewReader(make([]byte, MinChunkSize))}
+	if err := s.Create(ctx, "note", dto); err == nil {
+		t.Error("file over quota is accepted")
+	}
+	fakes.files.only(t)
+	if names := fakes.storage.blobNames(); len(names) != 1 || names[0] != f.SHA256 {
+		t.Errorf("blobs = %v, want only %s", names, f.SHA256)
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		content     string
+		want        string
+	}{
+		{"", "%PDF-1.4", "application/pdf"},
+		{"application/octet-stream", "notes", "text/plain; charset=utf-8"},
+		// the type of the client is not trusted when the content is recognized
+		{"image/png", "%PDF-1.4", "application/pdf"},
+		{"text/plain", "<html><body>", "text/html; charset=utf-8"},
+		// generic sniffed types keep the specific type of the client
+		{"text/csv", "a,b\n1,2", "text/csv"},
+		{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", "PK\x03\x04",
+			"application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	}
+	for _, tt := range tests {
+		contentType, _, reader := detectContentType(tt.contentType, strings.NewReader(tt.content))
+		if contentType != tt.want {
+			t.Errorf("%q declared as %q: type = %q, want %q", tt.content, tt.contentType, contentType, tt.want)
+		}
+		if read, _ := ioutil.ReadAll(reader); string(read) != tt.content {
+			t.Errorf("content read after sniffing = %q", read)
+		}
+	}
+}
+
+// Test scenario:
+// 1. the same content is attached to two notes, it is stored once
+// 2. the blob is kept while a note refers to it and deleted with the last reference
+func TestDeduplication(t *testing.T) {
+	ctx := context.Background()
+	s, fakes := newTestService(t, 0)
+	content := []byte("%PDF-1.4 the same document")
+	sum := checksum(content)
+
+	for _, noteUUID := range []string{"note1", "note2"} {
+		dto := CreateFileDTO{UserUUID: "user", Name: "doc.pdf", Size: int64(len(content)), Reader: bytes.NewReader(content)}
+		if err := s.Create(ctx, noteUUID, dto); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names := fakes.storage.blobNames(); len(names) != 1 || names[0] != sum {
+		t.Fatalf("blobs = %v, want only %s", names, sum)
+	}
+	if fakes.blobs[sum] != 2 {
+		t.Errorf("refs = %d, want 2", fakes.blobs[sum])
+	}
+
+	files, _ := s.GetFilesByNoteUUID(ctx, "note1")
+	if err := s.Delete(ctx, "note1", files[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	files, _ = s.GetFilesByNoteUUID(ctx, "note2")
+	reader, err := s.GetFile(ctx, "note2", files[0], nil)
+	if err != nil {
+		t.Fatalf("file of the other note is lost: %v", err)
+	}
+	if got, _ := ioutil.ReadAll(reader); !bytes.Equal(got, content) {
+		t.Errorf("content = %q", got)
+	}
+
+	if deleted, err := s.DeleteFilesByNoteUUID(ctx, "note2"); err != nil || deleted != 1 {
+		t.Fatalf("deleted = %d, error = %v", deleted, err)
+	}
+	if names := fakes.storage.blobNames(); len(names) != 0 {
+		t.Errorf("blobs = %v, want none", names)
+	}
+	if _, ok := fakes.blobs[sum]; ok {
+		t.Error("record of the deleted blob is kept")
+	}
+}
+
+// Test scenario:
+// 1. blobs without references and objects without records older than the grace period are deleted
+// 2. referenced blobs with their thumbnails and young incoming objects of uploads in progress are kept
+func TestCollectGarbage(t *testing.T) {
+	s, fakes := newTestService(t, 0)
+	old := time.Now().Add(-2 * time.Hour)
+	fakes.storage.objects = map[string]fakeObject{
+		objectKey(BlobsBucket, "incoming/old"): {content: []byte("12345"), modTime: old},
+		objectKey(BlobsBucket, "incoming/new"): {content: []byte("1"), modTime: time.Now()},
+		objectKey(BlobsBucket, "orphan"):       {content: []byte("123"), modTime: old},
+		objectKey(BlobsBucket, "released"):     {content: []byte("1"), modTime: old},
+		objectKey(BlobsBucket, "referenced"):   {content: []byte("1"), modTime: old},
+
+		objectKey(BlobsBucket, "thumbnails/referenced/small.jpg"): {content: []byte("1"), modTime: old},
+		objectKey(BlobsBucket, "thumbnails/orphan/small.jpg"):     {content: []byte("12"), modTime: old},
+	}
+	fakes.blobs["released"] = 0
+	fakes.blobs["referenced"] = 1
+
+	report, err := s.CollectGarbage(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (GCReport{Blobs: 1, Orphans: 3, OrphanBytes: 10}) {
+		t.Errorf("report = %+v", report)
+	}
+	for _, name := range []string{"incoming/new", "referenced", "thumbnails/referenced/small.jpg"} {
+		if _, ok := fakes.storage.objects[objectKey(BlobsBucket, name)]; !ok {
+			t.Errorf("%s is deleted", name)
+		}
+	}
+	if len(fakes.storage.objects) != 3 {
+		t.Errorf("objects left = %d, want 3", len(fakes.storage.objects))
+	}
+}
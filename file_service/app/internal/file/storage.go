@@ -2,11 +2,71 @@ package file
 
 import (
 	"context"
+	"io"
+	"time"
 )
 
+// Storage keeps content of files as named objects in buckets
 type Storage interface {
-	GetFile(ctx context.Context, bucketName, fileName string) (*File, error)
-	GetFilesByNoteUUID(ctx context.Context, uuid string) ([]*File, error)
-	CreateFile(ctx context.Context, noteUUID string, file *File) error
-	DeleteFile(ctx context.Context, noteUUID, fileName string) error
-}
\ No newline at end of file
+	// GetFile opens the whole file or rng of it
+	GetFile(ctx context.Context, bucketName, fileName string, rng *ByteRange) (io.ReadCloser, error)
+	// CreateFile stores the content of file under file.ID
+	CreateFile(ctx context.Context, bucketName string, file *File) error
+	FileExists(ctx context.Context, bucketName, fileName string) (bool, error)
+	// MoveFile renames the file, a file with the new name is replaced
+	MoveFile(ctx context.Context, bucketName, fileName, newName string) error
+	DeleteFile(ctx context.Context, bucketName, fileName string) error
+	ListFiles(ctx context.Context, bucketName string) ([]StoredFile, error)
+
+	// multipart uploads of resumable uploads, parts except the last one must be at least MinChunkSize
+	CreateMultipartUpload(ctx context.Context, bucketName string, file *File) (string, error)
+	PutPart(ctx context.Context, bucketName, fileName, uploadID string, number int, reader io.Reader, size int64) (string, error)
+	CompleteMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string, etags []string) error
+	AbortMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string) error
+}
+
+// MetaStorage keeps metadata records of files, so files are listed without the storage
+type MetaStorage interface {
+	Create(ctx context.Context, file *File) error
+	FindOne(ctx context.Context, noteUUID, id string) (*File, error)
+	FindByNoteUUID(ctx context.Context, noteUUID string) ([]*File, error)
+	Delete(ctx context.Context, noteUUID, id string) error
+	DeleteByNoteUUID(ctx context.Context, noteUUID string) (int, error)
+	SetStatus(ctx context.Context, noteUUID, id, status string) error
+	// FindQuarantined returns files created before the time which are still not scanned
+	FindQuarantined(ctx context.Context, before time.Time) ([]*File, error)
+}
+
+// BlobStorage counts references of files to blobs, see blob.go
+type BlobStorage interface {
+	// AddRef references the blob creating its record if needed, ErrBlobBusy means the blob is being deleted
+	AddRef(ctx context.Context, sha256 string, size int64) error
+	// ReleaseRef dereferences the blob, true means it was the last reference and the caller deletes the blob
+	ReleaseRef(ctx context.Context, sha256 string) (bool, error)
+	Exists(ctx context.Context, sha256 string) (bool, error)
+	Delete(ctx context.Context, sha256 string) error
+	// ClaimUnreferenced marks blobs without references as being deleted and returns all blobs being deleted,
+	// including those left by failed deletions
+	ClaimUnreferenced(ctx context.Context) ([]string, error)
+}
+
+type UploadStorage interface {
+	Create(ctx context.Context, upload Upload) error
+	FindOne(ctx context.Context, id string) (Upload, error)
+	// AddPart moves the offset by the part size if it is still at offset, false means another chunk was written first
+	AddPart(ctx context.Context, id string, offset int64, part UploadPart) (bool, error)
+	Delete(ctx context.Context, id string) error
+	FindExpired(ctx context.Context, now time.Time) ([]Upload, error)
+}
+
+// UsageStorage keeps how many bytes of files every user stores and reserves for uploads in progress, and the plan of the user
+type UsageStorage interface {
+	// Get returns Plan, Used and Pending, users without files have zero usage
+	Get(ctx context.Context, userUUID string) (Usage, error)
+	Add(ctx context.Context, userUUID string, delta int64) error
+	// Reserve adds size to Pending if Used and Pending stay within the quota, false means they don't. Quota 0 is unlimited.
+	Reserve(ctx context.Context, userUUID string, size, quota int64) (bool, error)
+	// Settle moves reserved bytes of a finished upload from Pending to Used, stored is 0 when the upload failed
+	Settle(ctx context.Context, userUUID string, reserved, stored int64) error
+	SetPlan(ctx context.Context, userUUID, plan string) error
+}
@@ -0,0 +1,297 @@
+package fs
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var _ file.Storage = &fsStorage{}
+
+// Files are kept in a directory per bucket under root, names with slashes are nested directories.
+// Files are written to tmpDir and renamed into place, so a file is never seen half written.
+// Parts of multipart uploads are kept in uploadsDir until the upload is completed.
+const (
+	tmpDir     = ".tmp"
+	uploadsDir = ".uploads"
+)
+
+type fsStorage struct {
+	root   string
+	logger logging.Logger
+}
+
+func NewStorage(root string, logger logging.Logger) (file.Storage, error) {
+	for _, dir := range []string{root, filepath.Join(root, tmpDir), filepath.Join(root, uploadsDir)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory. err: %w", err)
+		}
+	}
+	return &fsStorage{
+		root:   root,
+		logger: logger,
+	}, nil
+}
+
+func (s *fsStorage) GetFile(ctx context.Context, bucketName, fileName string, rng *file.ByteRange) (io.ReadCloser, error) {
+	path, err := s.path(bucketName, fileName)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, apperror.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open file. err: %w", err)
+	}
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		f.Close()
+		return nil, apperror.ErrNotFound
+	}
+	if rng == nil {
+		return f, nil
+	}
+	if _, err = f.Seek(rng.Start, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to seek file. err: %w", err)
+	}
+	return &rangeReader{Reader: io.LimitReader(f, rng.Length()), Closer: f}, nil
+}
+
+func (s *fsStorage) CreateFile(ctx context.Context, bucketName string, f *file.File) error {
+	path, err := s.path(bucketName, f.ID)
+	if err != nil {
+		return err
+	}
+	if _, err = s.writeAtomically(path, f.Reader); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *fsStorage) FileExists(ctx context.Context, bucketName, fileName string) (bool, error) {
+	path, err := s.path(bucketName, fileName)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to stat file. err: %w", err)
+	}
+	return !info.IsDir(), nil
+}
+
+func (s *fsStorage) MoveFile(ctx context.Context, bucketName, fileName, newName string) error {
+	path, err := s.path(bucketName, fileName)
+	if err != nil {
+		return err
+	}
+	newPath, err := s.path(bucketName, newName)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory. err: %w", err)
+	}
+	if err = os.Rename(path, newPath); err != nil {
+		if os.IsNotExist(err) {
+			return apperror.ErrNotFound
+		}
+		return fmt.Errorf("failed to move file. err: %w", err)
+	}
+	s.removeEmptyDirs(bucketName, filepath.Dir(path))
+	return nil
+}
+
+func (s *fsStorage) DeleteFile(ctx context.Context, bucketName, fileName string) error {
+	path, err := s.path(bucketName, fileName)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file. err: %w", err)
+	}
+	s.removeEmptyDirs(bucketName, filepath.Dir(path))
+	return nil
+}
+
+func (s *fsStorage) ListFiles(ctx context.Context, bucketName string) ([]file.StoredFile, error) {
+	bucket, err := s.path(bucketName, "")
+	if err != nil {
+		return nil, err
+	}
+	var files []file.StoredFile
+	err = filepath.Walk(bucket, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == bucket {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(bucket, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, file.StoredFile{Name: filepath.ToSlash(name), Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of bucket %s. err: %w", bucketName, err)
+	}
+	return files, nil
+}
+
+func (s *fsStorage) CreateMultipartUpload(ctx context.Context, bucketName string, f *file.File) (string, error) {
+	if _, err := s.path(bucketName, f.ID); err != nil {
+		return "", err
+	}
+	uploadID := uuid.New().String()
+	if err := os.Mkdir(filepath.Join(s.root, uploadsDir, uploadID), 0755); err != nil {
+		return "", fmt.Errorf("failed to start multipart upload. err: %w", err)
+	}
+	return uploadID, nil
+}
+
+func (s *fsStorage) PutPart(ctx context.Context, bucketName, fileName, uploadID string, number int, reader io.Reader, size int64) (string, error) {
+	dir, err := s.uploadDir(uploadID)
+	if err != nil {
+		return "", err
+	}
+	checksum := md5.New()
+	written, err := s.writeAtomically(filepath.Join(dir, strconv.Itoa(number)), io.TeeReader(reader, checksum))
+	if err != nil {
+		return "", err
+	}
+	if written != size {
+		return "", fmt.Errorf("part %d is %d bytes, want %d", number, written, size)
+	}
+	return hex.EncodeToString(checksum.Sum(nil)), nil
+}
+
+func (s *fsStorage) CompleteMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string, etags []string) error {
+	path, err := s.path(bucketName, fileName)
+	if err != nil {
+		return err
+	}
+	dir, err := s.uploadDir(uploadID)
+	if err != nil {
+		return err
+	}
+
+	parts := make([]io.Reader, 0, len(etags))
+	for i := range etags {
+		part, err := os.Open(filepath.Join(dir, strconv.Itoa(i+1)))
+		if err != nil {
+			return fmt.Errorf("failed to open part %d. err: %w", i+1, err)
+		}
+		defer part.Close()
+		parts = append(parts, part)
+	}
+	if _, err = s.writeAtomically(path, io.MultiReader(parts...)); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+func (s *fsStorage) AbortMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string) error {
+	dir, err := s.uploadDir(uploadID)
+	if err != nil {
+		return err
+	}
+	if err = os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to abort multipart upload. err: %w", err)
+	}
+	return nil
+}
+
+// path maps the file to its path under root, names escaping the bucket directory are rejected
+func (s *fsStorage) path(bucketName, fileName string) (string, error) {
+	if bucketName == "" || strings.HasPrefix(bucketName, ".") || strings.ContainsAny(bucketName, `/\`) {
+		return "", fmt.Errorf("invalid bucket name %q", bucketName)
+	}
+	bucket := filepath.Join(s.root, bucketName)
+	if fileName == "" {
+		return bucket, nil
+	}
+	path := filepath.Join(bucket, filepath.FromSlash(fileName))
+	if !strings.HasPrefix(path, bucket+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid file name %q", fileName)
+	}
+	return path, nil
+}
+
+func (s *fsStorage) uploadDir(uploadID string) (string, error) {
+	if _, err := uuid.Parse(uploadID); err != nil {
+		return "", fmt.Errorf("invalid upload id %q", uploadID)
+	}
+	dir := filepath.Join(s.root, uploadsDir, uploadID)
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return "", apperror.ErrNotFound
+		}
+		return "", fmt.Errorf("failed to stat multipart upload. err: %w", err)
+	}
+	return dir, nil
+}
+
+// writeAtomically writes reader to a temporary file and renames it to path
+func (s *fsStorage) writeAtomically(path string, reader io.Reader) (int64, error) {
+	tmp, err := ioutil.TempFile(filepath.Join(s.root, tmpDir), "write-")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temporary file. err: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, reader)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write file. err: %w", err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory. err: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to rename file. err: %w", err)
+	}
+	return written, nil
+}
+
+// removeEmptyDirs removes directories of nested names left empty, the bucket directory is kept
+func (s *fsStorage) removeEmptyDirs(bucketName, dir string) {
+	bucket := filepath.Join(s.root, bucketName)
+	for dir != bucket && strings.HasPrefix(dir, bucket) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+type rangeReader struct {
+	io.Reader
+	io.Closer
+}
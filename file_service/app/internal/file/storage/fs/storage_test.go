@@ -0,0 +1,43 @@
+package fs
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"github.com/theartofdevel/notes_system/file_service/internal/file/storage/storagetest"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) file.Storage {
+		s, err := NewStorage(t.TempDir(), logging.Logger{Entry: logrus.NewEntry(logrus.New())})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+// Test scenario:
+// 1. names escaping the bucket directory are rejected
+func TestPathTraversal(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewStorage(filepath.Join(root, "files"), logging.Logger{Entry: logrus.NewEntry(logrus.New())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(root, "secret"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"../../secret", "../other/a", "/etc/passwd", ""} {
+		if _, err = s.GetFile(context.Background(), "bucket", name, nil); err == nil {
+			t.Errorf("%q is read", name)
+		}
+	}
+	if _, err = s.GetFile(context.Background(), "..", "secret", nil); err == nil {
+		t.Error("bucket outside root is read")
+	}
+}
@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ file.Storage = &memoryStorage{}
+
+type object struct {
+	content []byte
+	modTime time.Time
+}
+
+// memoryStorage keeps files in memory, it is meant for tests and local runs, files are lost on restart
+type memoryStorage struct {
+	mu sync.RWMutex
+	// objects are keyed by bucket and name joined with a slash
+	objects map[string]object
+	// uploads are parts of multipart uploads by upload id and part number
+	uploads map[string]map[int][]byte
+}
+
+func NewStorage() file.Storage {
+	return &memoryStorage{
+		objects: make(map[string]object),
+		uploads: make(map[string]map[int][]byte),
+	}
+}
+
+func key(bucketName, fileName string) string {
+	return bucketName + "/" + fileName
+}
+
+func (s *memoryStorage) GetFile(ctx context.Context, bucketName, fileName string, rng *file.ByteRange) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.objects[key(bucketName, fileName)]
+	if !ok {
+		return nil, apperror.ErrNotFound
+	}
+	content := obj.content
+	if rng != nil {
+		content = content[rng.Start : rng.End+1]
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (s *memoryStorage) CreateFile(ctx context.Context, bucketName string, f *file.File) error {
+	content, err := ioutil.ReadAll(f.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to read file. err: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key(bucketName, f.ID)] = object{content: content, modTime: time.Now()}
+	return nil
+}
+
+func (s *memoryStorage) FileExists(ctx context.Context, bucketName, fileName string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.objects[key(bucketName, fileName)]
+	return ok, nil
+}
+
+func (s *memoryStorage) MoveFile(ctx context.Context, bucketName, fileName, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key(bucketName, fileName)]
+	if !ok {
+		return apperror.ErrNotFound
+	}
+	delete(s.objects, key(bucketName, fileName))
+	s.objects[key(bucketName, newName)] = obj
+	return nil
+}
+
+func (s *memoryStorage) DeleteFile(ctx context.Context, bucketName, fileName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key(bucketName, fileName))
+	return nil
+}
+
+func (s *memoryStorage) ListFiles(ctx context.Context, bucketName string) ([]file.StoredFile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var files []file.StoredFile
+	prefix := key(bucketName, "")
+	for k, obj := range s.objects {
+		if strings.HasPrefix(k, prefix) {
+			files = append(files, file.StoredFile{
+				Name:    strings.TrimPrefix(k, prefix),
+				Size:    int64(len(obj.content)),
+				ModTime: obj.modTime,
+			})
+		}
+	}
+	return files, nil
+}
+
+func (s *memoryStorage) CreateMultipartUpload(ctx context.Context, bucketName string, f *file.File) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uploadID := uuid.New().String()
+	s.uploads[uploadID] = make(map[int][]byte)
+	return uploadID, nil
+}
+
+func (s *memoryStorage) PutPart(ctx context.Context, bucketName, fileName, uploadID string, number int, reader io.Reader, size int64) (string, error) {
+	part, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read part %d. err: %w", number, err)
+	}
+	if int64(len(part)) != size {
+		return "", fmt.Errorf("part %d is %d bytes, want %d", number, len(part), size)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts, ok := s.uploads[uploadID]
+	if !ok {
+		return "", apperror.ErrNotFound
+	}
+	parts[number] = part
+	sum := md5.Sum(part)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (s *memoryStorage) CompleteMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string, etags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts, ok := s.uploads[uploadID]
+	if !ok {
+		return apperror.ErrNotFound
+	}
+	var content []byte
+	for i, etag := range etags {
+		part, ok := parts[i+1]
+		if sum := md5.Sum(part); !ok || hex.EncodeToString(sum[:]) != etag {
+			return fmt.Errorf("part %d with etag %s is not uploaded", i+1, etag)
+		}
+		content = append(content, part...)
+	}
+	delete(s.uploads, uploadID)
+	s.objects[key(bucketName, fileName)] = object{content: content, modTime: time.Now()}
+	return nil
+}
+
+func (s *memoryStorage) AbortMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uploads, uploadID)
+	return nil
+}
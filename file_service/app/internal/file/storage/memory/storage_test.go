@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"github.com/theartofdevel/notes_system/file_service/internal/file/storage/storagetest"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) file.Storage {
+		return NewStorage()
+	})
+}
@@ -1,7 +1,6 @@
 package minio
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
@@ -11,6 +10,8 @@ import (
 	"io"
 )
 
+var _ file.LegacyStorage = &minioStorage{}
+
 type minioStorage struct {
 	client *minio.Client
 	logger logging.Logger
@@ -23,80 +24,138 @@ func NewStorage(endpoint, accessKeyID, secretAccessKey string, logger logging.Lo
 	}
 	return &minioStorage{
 		client: client,
+		logger: logger,
 	}, nil
 }
 
-func (m *minioStorage) GetFile(ctx context.Context, bucketName, fileID string) (*file.File, error) {
-	obj, err := m.client.GetFile(ctx, bucketName, fileID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get file. err: %w", err)
+func (m *minioStorage) GetFile(ctx context.Context, bucketName, fileID string, rng *file.ByteRange) (io.ReadCloser, error) {
+	start, end := int64(0), int64(-1)
+	if rng != nil {
+		start, end = rng.Start, rng.End
 	}
-	defer obj.Close()
-	objectInfo, err := obj.Stat()
+	// blobs are named by their content, so there is no etag to match
+	obj, err := m.client.GetFile(ctx, bucketName, fileID, "", start, end)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file. err: %w", err)
 	}
-	buffer := make([]byte, objectInfo.Size)
-	_, err = obj.Read(buffer)
-	if err != nil && err != io.EOF {
-		return nil, fmt.Errorf("failed to get objects. err: %w", err)
-	}
-	f := file.File{
-		ID:	   objectInfo.Key,
-		Name:  objectInfo.UserMetadata["Name"],
-		Size:  objectInfo.Size,
-		Bytes: buffer,
+	// stat sends the request, so errors are returned before the response is started
+	if _, err = obj.Stat(); err != nil {
+		obj.Close()
+		if minio.IsNotFound(err) {
+			return nil, apperror.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get file. err: %w", err)
 	}
-	return &f, nil
+	return obj, nil
 }
 
-func (m *minioStorage) GetFilesByNoteUUID(ctx context.Context, noteUUID string) ([]*file.File, error) {
-	objects, err := m.client.GetBucketFiles(ctx, noteUUID)
+func (m *minioStorage) CreateFile(ctx context.Context, bucketName string, file *file.File) error {
+	err := m.client.UploadFile(ctx, file.ID, bucketName, objectMeta(file), file.Size, file.Reader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get objects. err: %w", err)
-	}
-	if len(objects) == 0 {
-		return nil, apperror.ErrNotFound
+		return err
 	}
+	return nil
+}
 
-	var files []*file.File
-	for _, obj := range objects {
-		stat, err := obj.Stat()
-		if err != nil {
-			m.logger.Errorf("failed to get objects. err: %v", err)
-			continue
-		}
-		buffer := make([]byte, stat.Size)
-		_, err = obj.Read(buffer)
-		if err != nil && err != io.EOF {
-			m.logger.Errorf("failed to get objects. err: %v", err)
-			continue
-		}
-		f := file.File{
-			ID:  stat.Key,
-			Name: stat.UserMetadata["Name"],
-			Size:  stat.Size,
-			Bytes: buffer,
+func (m *minioStorage) FileExists(ctx context.Context, bucketName, fileName string) (bool, error) {
+	_, err := m.client.StatFile(ctx, bucketName, fileName)
+	if err != nil {
+		if minio.IsNotFound(err) {
+			return false, nil
 		}
-		files = append(files, &f)
-		obj.Close()
+		return false, fmt.Errorf("failed to get file. err: %w", err)
 	}
+	return true, nil
+}
 
-	return files, nil
+func (m *minioStorage) MoveFile(ctx context.Context, bucketName, fileName, newName string) error {
+	return m.client.MoveFile(ctx, bucketName, fileName, newName)
 }
 
-func (m *minioStorage) CreateFile(ctx context.Context, noteUUID string, file *file.File) error {
-	err := m.client.UploadFile(ctx, file.ID, file.Name, noteUUID, file.Size, bytes.NewBuffer(file.Bytes))
+func (m *minioStorage) DeleteFile(ctx context.Context, bucketName, fileName string) error {
+	err := m.client.DeleteFile(ctx, bucketName, fileName)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (m *minioStorage) DeleteFile(ctx context.Context, noteUUID, fileId string) error {
-	err := m.client.DeleteFile(ctx, noteUUID, fileId)
+func (m *minioStorage) ListFiles(ctx context.Context, bucketName string) ([]file.StoredFile, error) {
+	objects, err := m.client.ListFiles(ctx, bucketName)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
-}
\ No newline at end of file
+	files := make([]file.StoredFile, 0, len(objects))
+	for _, object := range objects {
+		files = append(files, file.StoredFile{Name: object.Key, Size: object.Size, ModTime: object.LastModified})
+	}
+	return files, nil
+}
+
+func (m *minioStorage) ListNoteBuckets(ctx context.Context) ([]string, error) {
+	buckets, err := m.client.ListBuckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	notes := make([]string, 0, len(buckets))
+	for _, bucket := range buckets {
+		if bucket != file.BlobsBucket {
+			notes = append(notes, bucket)
+		}
+	}
+	return notes, nil
+}
+
+// ListNoteFiles returns files kept in the bucket of the note before blobs, the names were normalized on upload
+func (m *minioStorage) ListNoteFiles(ctx context.Context, noteUUID string) ([]*file.File, error) {
+	objects, err := m.client.ListFiles(ctx, noteUUID)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]*file.File, 0, len(objects))
+	for _, object := range objects {
+		// listing has no user metadata, stat returns it without reading the object
+		stat, err := m.client.StatFile(ctx, noteUUID, object.Key)
+		if err != nil {
+			if minio.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get file. err: %w", err)
+		}
+		files = append(files, &file.File{
+			ID:           stat.Key,
+			Name:         stat.UserMetadata["Name"],
+			OriginalName: stat.UserMetadata["Name"],
+			Size:         stat.Size,
+			ContentType:  stat.ContentType,
+			Uploader:     stat.UserMetadata["Uploader"],
+			CreatedAt:    stat.LastModified.UTC(),
+		})
+	}
+	return files, nil
+}
+
+func (m *minioStorage) DeleteNoteBucket(ctx context.Context, noteUUID string) error {
+	_, err := m.client.DeleteBucket(ctx, noteUUID)
+	return err
+}
+
+func (m *minioStorage) CreateMultipartUpload(ctx context.Context, bucketName string, file *file.File) (string, error) {
+	return m.client.NewMultipartUpload(ctx, file.ID, bucketName, objectMeta(file))
+}
+
+func (m *minioStorage) PutPart(ctx context.Context, bucketName, fileName, uploadID string, number int, reader io.Reader, size int64) (string, error) {
+	return m.client.PutObjectPart(ctx, fileName, bucketName, uploadID, number, reader, size)
+}
+
+func (m *minioStorage) CompleteMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string, etags []string) error {
+	return m.client.CompleteMultipartUpload(ctx, fileName, bucketName, uploadID, etags)
+}
+
+func (m *minioStorage) AbortMultipartUpload(ctx context.Context, bucketName, fileName, uploadID string) error {
+	return m.client.AbortMultipartUpload(ctx, fileName, bucketName, uploadID)
+}
+
+func objectMeta(f *file.File) minio.ObjectMeta {
+	return minio.ObjectMeta{Name: f.Name, ContentType: f.ContentType, Uploader: f.Uploader}
+}
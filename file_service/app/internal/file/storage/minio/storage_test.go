@@ -0,0 +1,42 @@
+package minio
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"github.com/theartofdevel/notes_system/file_service/internal/file/storage/storagetest"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+	"os"
+	"testing"
+)
+
+// TestStorage needs a running minio, it is skipped unless MINIO_ENDPOINT is set:
+//
+//	MINIO_ENDPOINT=localhost:9000 MINIO_ACCESS_KEY=minio MINIO_SECRET_KEY=minio123 go test ./internal/file/storage/minio
+func TestStorage(t *testing.T) {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("MINIO_ENDPOINT is not set")
+	}
+	logger := logging.Logger{Entry: logrus.NewEntry(logrus.New())}
+	storagetest.Run(t, func(t *testing.T) file.Storage {
+		s, err := NewStorage(endpoint, os.Getenv("MINIO_ACCESS_KEY"), os.Getenv("MINIO_SECRET_KEY"), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// buckets are shared by the tests, files of the previous one are removed
+		ctx := context.Background()
+		for _, bucket := range []string{"bucket", "other"} {
+			files, err := s.ListFiles(ctx, bucket)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range files {
+				if err = s.DeleteFile(ctx, bucket, f.Name); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		return s
+	})
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/theartofdevel/notes_system/file_service/internal/config"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"github.com/theartofdevel/notes_system/file_service/internal/file/storage/fs"
+	"github.com/theartofdevel/notes_system/file_service/internal/file/storage/memory"
+	"github.com/theartofdevel/notes_system/file_service/internal/file/storage/minio"
+	"github.com/theartofdevel/notes_system/file_service/pkg/logging"
+)
+
+const (
+	TypeMinIO  = "minio"
+	TypeFS     = "fs"
+	TypeMemory = "memory"
+)
+
+// NewStorage returns the storage of file contents selected by the config
+func NewStorage(cfg *config.Config, logger logging.Logger) (file.Storage, error) {
+	switch cfg.Storage.Type {
+	case TypeMinIO:
+		if cfg.MinIO.Endpoint == "" {
+			return nil, fmt.Errorf("minio endpoint is required for %s storage", TypeMinIO)
+		}
+		return minio.NewStorage(cfg.MinIO.Endpoint, cfg.MinIO.AccessKey, cfg.MinIO.SecretKey, logger)
+	case TypeFS:
+		if cfg.Storage.Path == "" {
+			return nil, fmt.Errorf("path is required for %s storage", TypeFS)
+		}
+		return fs.NewStorage(cfg.Storage.Path, logger)
+	case TypeMemory:
+		logger.Warn("files are stored in memory and are lost on restart")
+		return memory.NewStorage(), nil
+	}
+	return nil, fmt.Errorf("unknown storage type %q", cfg.Storage.Type)
+}
@@ -0,0 +1,244 @@
+// Package storagetest checks that an implementation of file.Storage behaves the way the file service expects
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/file_service/internal/file"
+	"io/ioutil"
+	"sort"
+	"testing"
+)
+
+// Run runs the conformance tests, newStorage returns an empty storage for every test
+func Run(t *testing.T, newStorage func(t *testing.T) file.Storage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s file.Storage)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"NestedNames", testNestedNames},
+		{"Missing", testMissing},
+		{"MoveFile", testMoveFile},
+		{"DeleteFile", testDeleteFile},
+		{"ListFiles", testListFiles},
+		{"MultipartUpload", testMultipartUpload},
+		{"AbortMultipartUpload", testAbortMultipartUpload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t))
+		})
+	}
+}
+
+const bucket = "bucket"
+
+func create(t *testing.T, s file.Storage, name, content string) {
+	t.Helper()
+	f := &file.File{ID: name, Name: name, Size: int64(len(content)), Reader: bytes.NewReader([]byte(content))}
+	if err := s.CreateFile(context.Background(), bucket, f); err != nil {
+		t.Fatalf("create %s: %v", name, err)
+	}
+}
+
+func read(t *testing.T, s file.Storage, name string, rng *file.ByteRange) string {
+	t.Helper()
+	reader, err := s.GetFile(context.Background(), bucket, name, rng)
+	if err != nil {
+		t.Fatalf("get %s: %v", name, err)
+	}
+	defer reader.Close()
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return string(content)
+}
+
+func exists(t *testing.T, s file.Storage, name string) bool {
+	t.Helper()
+	ok, err := s.FileExists(context.Background(), bucket, name)
+	if err != nil {
+		t.Fatalf("exists %s: %v", name, err)
+	}
+	return ok
+}
+
+// Test scenario:
+// 1. created file is read whole and by range
+// 2. creating a file with the same name replaces it
+func testCreateAndGet(t *testing.T, s file.Storage) {
+	create(t, s, "a", "hello world")
+	if !exists(t, s, "a") {
+		t.Error("created file does not exist")
+	}
+	if got := read(t, s, "a", nil); got != "hello world" {
+		t.Errorf("content = %q", got)
+	}
+	if got := read(t, s, "a", &file.ByteRange{Start: 6, End: 10}); got != "world" {
+		t.Errorf("range content = %q", got)
+	}
+
+	create(t, s, "a", "bye")
+	if got := read(t, s, "a", nil); got != "bye" {
+		t.Errorf("replaced content = %q", got)
+	}
+}
+
+// Test scenario:
+// 1. names with slashes are kept apart from names sharing their prefix
+// 2. a prefix of nested names is not a file
+func testNestedNames(t *testing.T, s file.Storage) {
+	create(t, s, "thumbnails/sha/64.jpg", "small")
+	create(t, s, "thumbnails/sha/256.jpg", "medium")
+	if got := read(t, s, "thumbnails/sha/64.jpg", nil); got != "small" {
+		t.Errorf("content = %q", got)
+	}
+	if exists(t, s, "thumbnails/sha") {
+		t.Error("prefix of nested names exists")
+	}
+	if _, err := s.GetFile(context.Background(), bucket, "thumbnails/sha", nil); !errors.Is(err, apperror.ErrNotFound) {
+		t.Errorf("get prefix of nested names: error = %v, want not found", err)
+	}
+}
+
+// Test scenario:
+// 1. missing file does not exist and is not found
+func testMissing(t *testing.T, s file.Storage) {
+	if exists(t, s, "missing") {
+		t.Error("missing file exists")
+	}
+	if _, err := s.GetFile(context.Background(), bucket, "missing", nil); !errors.Is(err, apperror.ErrNotFound) {
+		t.Errorf("get missing file: error = %v, want not found", err)
+	}
+}
+
+// Test scenario:
+// 1. moved file is read under the new name only
+// 2. moving to an existing name replaces it
+func testMoveFile(t *testing.T, s file.Storage) {
+	ctx := context.Background()
+	create(t, s, "incoming/a", "new")
+	create(t, s, "b", "old")
+	if err := s.MoveFile(ctx, bucket, "incoming/a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if exists(t, s, "incoming/a") {
+		t.Error("moved file is kept under the old name")
+	}
+	if got := read(t, s, "b", nil); got != "new" {
+		t.Errorf("content = %q, want the moved one", got)
+	}
+}
+
+// Test scenario:
+// 1. deleted file does not exist
+// 2. deleting a missing file is not an error
+func testDeleteFile(t *testing.T, s file.Storage) {
+	ctx := context.Background()
+	create(t, s, "a", "content")
+	if err := s.DeleteFile(ctx, bucket, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if exists(t, s, "a") {
+		t.Error("deleted file exists")
+	}
+	if err := s.DeleteFile(ctx, bucket, "a"); err != nil {
+		t.Errorf("delete missing file: %v", err)
+	}
+}
+
+// Test scenario:
+// 1. missing bucket has no files
+// 2. files of the bucket are listed with full names and sizes, files of other buckets are not
+func testListFiles(t *testing.T, s file.Storage) {
+	ctx := context.Background()
+	files, err := s.ListFiles(ctx, bucket)
+	if err != nil || len(files) != 0 {
+		t.Fatalf("missing bucket: files = %v, error = %v", files, err)
+	}
+
+	create(t, s, "a", "12345")
+	create(t, s, "thumbnails/sha/64.jpg", "123")
+	other := &file.File{ID: "c", Size: 1, Reader: bytes.NewReader([]byte("1"))}
+	if err = s.CreateFile(ctx, "other", other); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err = s.ListFiles(ctx, bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	if len(files) != 2 || files[0].Name != "a" || files[0].Size != 5 ||
+		files[1].Name != "thumbnails/sha/64.jpg" || files[1].Size != 3 {
+		t.Errorf("files = %+v", files)
+	}
+	for _, f := range files {
+		if f.ModTime.IsZero() {
+			t.Errorf("%s has no modification time", f.Name)
+		}
+	}
+}
+
+// Test scenario:
+// 1. parts are joined in order into the file when the upload is completed
+// 2. the file is not visible until then
+func testMultipartUpload(t *testing.T, s file.Storage) {
+	ctx := context.Background()
+	f := &file.File{ID: "incoming/a", Name: "a"}
+	uploadID, err := s.CreateMultipartUpload(ctx, bucket, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := bytes.Repeat([]byte{1}, file.MinChunkSize)
+	last := []byte("tail")
+	var etags []string
+	for i, part := range [][]byte{first, last} {
+		etag, err := s.PutPart(ctx, bucket, f.ID, uploadID, i+1, bytes.NewReader(part), int64(len(part)))
+		if err != nil {
+			t.Fatalf("part %d: %v", i+1, err)
+		}
+		etags = append(etags, etag)
+	}
+	if exists(t, s, f.ID) {
+		t.Error("file of unfinished upload exists")
+	}
+
+	if err = s.CompleteMultipartUpload(ctx, bucket, f.ID, uploadID, etags); err != nil {
+		t.Fatal(err)
+	}
+	want := string(first) + string(last)
+	if got := read(t, s, f.ID, nil); got != want {
+		t.Errorf("content is %d bytes, want %d", len(got), len(want))
+	}
+}
+
+// Test scenario:
+// 1. aborted upload leaves no file and can't be completed
+func testAbortMultipartUpload(t *testing.T, s file.Storage) {
+	ctx := context.Background()
+	f := &file.File{ID: "incoming/a", Name: "a"}
+	uploadID, err := s.CreateMultipartUpload(ctx, bucket, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag, err := s.PutPart(ctx, bucket, f.ID, uploadID, 1, bytes.NewReader([]byte("part")), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.AbortMultipartUpload(ctx, bucket, f.ID, uploadID); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.CompleteMultipartUpload(ctx, bucket, f.ID, uploadID, []string{etag}); err == nil {
+		t.Error("aborted upload is completed")
+	}
+	if exists(t, s, f.ID) {
+		t.Error("aborted upload left a file")
+	}
+}
@@ -0,0 +1,169 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+)
+
+// Thumbnails are JPEG, the standard library has no WebP encoder and file_service is built without cgo.
+// Decoders of the standard library read JPEG, PNG and GIF images.
+const (
+	thumbnailsPrefix     = "thumbnails/"
+	thumbnailContentType = "image/jpeg"
+	thumbnailQuality     = 80
+
+	// maxThumbnailPixels protects from images which are small files but huge bitmaps
+	maxThumbnailPixels = 25 << 20
+)
+
+// ThumbnailSize bounds the longer side of a thumbnail, the aspect ratio is kept
+type ThumbnailSize struct {
+	Name string
+	Side int
+}
+
+var thumbnailSizes = []ThumbnailSize{
+	{Name: "small", Side: 64},
+	{Name: "medium", Side: 256},
+	{Name: "large", Side: 1024},
+}
+
+var thumbnailSources = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// ParseThumbnailSize returns the size by name, medium is the default
+func ParseThumbnailSize(name string) (ThumbnailSize, error) {
+	if name == "" {
+		name = "medium"
+	}
+	for _, size := range thumbnailSizes {
+		if size.Name == name {
+			return size, nil
+		}
+	}
+	return ThumbnailSize{}, apperror.BadRequestError("size must be small, medium or large")
+}
+
+func thumbnailName(sha256 string, size ThumbnailSize) string {
+	return fmt.Sprintf("%s%s/%s.jpg", thumbnailsPrefix, sha256, size.Name)
+}
+
+// GetThumbnail returns the cached thumbnail of the image or makes it on the first request
+func (s *service) GetThumbnail(ctx context.Context, f *File, size ThumbnailSize) (io.ReadCloser, error) {
+	if !thumbnailSources[f.ContentType] {
+		return nil, apperror.BadRequestError("thumbnails are made of JPEG, PNG and GIF images only")
+	}
+
+	name := thumbnailName(f.SHA256, size)
+	cached, err := s.storage.GetFile(ctx, BlobsBucket, name, nil)
+	if err == nil {
+		return cached, nil
+	}
+	if err != apperror.ErrNotFound {
+		return nil, err
+	}
+
+	s.logger.Debugf("make %s thumbnail of blob %s", size.Name, f.SHA256)
+	original, err := s.storage.GetFile(ctx, BlobsBucket, f.SHA256, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer original.Close()
+	thumbnail, err := makeThumbnail(original, size)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.storage.CreateFile(ctx, BlobsBucket, &File{
+		ID:          name,
+		Size:        int64(len(thumbnail)),
+		ContentType: thumbnailContentType,
+		Reader:      bytes.NewReader(thumbnail),
+	})
+	if err != nil {
+		// the thumbnail is made again next time
+		s.logger.Errorf("failed to cache thumbnail %s. err: %v", name, err)
+	}
+	return ioutil.NopCloser(bytes.NewReader(thumbnail)), nil
+}
+
+func makeThumbnail(reader io.Reader, size ThumbnailSize) ([]byte, error) {
+	var head bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(reader, &head))
+	if err != nil {
+		return nil, apperror.BadRequestError("file is not a valid image")
+	}
+	if config.Width*config.Height > maxThumbnailPixels {
+		return nil, apperror.BadRequestError("image is too large for a thumbnail")
+	}
+	src, _, err := image.Decode(io.MultiReader(&head, reader))
+	if err != nil {
+		return nil, apperror.BadRequestError("file is not a valid image")
+	}
+
+	var buf bytes.Buffer
+	if err = jpeg.Encode(&buf, scaleDown(src, size.Side), &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail. err: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleDown fits the image into side x side averaging source pixels covered by every thumbnail pixel,
+// smaller images keep their size. Transparent pixels become white as JPEG has no alpha.
+func scaleDown(src image.Image, side int) *image.RGBA {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dstW, dstH := srcW, srcH
+	if srcW > side || srcH > side {
+		if srcW >= srcH {
+			dstW, dstH = side, max(1, srcH*side/srcW)
+		} else {
+			dstW, dstH = max(1, srcW*side/srcH), side
+		}
+	}
+
+	flat := image.NewRGBA(image.Rect(0, 0, srcW, srcH))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, bounds.Min, draw.Over)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0, y1 := y*srcH/dstH, max((y+1)*srcH/dstH, y*srcH/dstH+1)
+		for x := 0; x < dstW; x++ {
+			x0, x1 := x*srcW/dstW, max((x+1)*srcW/dstW, x*srcW/dstW+1)
+			var r, g, b, n int
+			for sy := y0; sy < y1; sy++ {
+				row := flat.Pix[sy*flat.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					r += int(row[sx*4])
+					g += int(row[sx*4+1])
+					b += int(row[sx*4+2])
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r/n), uint8(g/n), uint8(b/n), 255
+		}
+	}
+	return dst
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
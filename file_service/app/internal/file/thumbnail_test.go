@@ -0,0 +1,85 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestScaleDown(t *testing.T) {
+	tests := []struct {
+		w, h, side   int
+		wantW, wantH int
+	}{
+		{1000, 500, 256, 256, 128},
+		{500, 1000, 256, 128, 256},
+		{100, 50, 256, 100, 50},
+		{3000, 2, 64, 64, 1},
+	}
+	for _, tt := range tests {
+		got := scaleDown(image.NewGray(image.Rect(0, 0, tt.w, tt.h)), tt.side).Bounds()
+		if got.Dx() != tt.wantW || got.Dy() != tt.wantH {
+			t.Errorf("scaleDown(%dx%d, %d) = %dx%d, want %dx%d", tt.w, tt.h, tt.side, got.Dx(), got.Dy(), tt.wantW, tt.wantH)
+		}
+	}
+
+	// transparent pixels become white, the average of black and white is gray
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.Black)
+	got := scaleDown(src, 1).RGBAAt(0, 0)
+	if got.R < 126 || got.R > 128 || got.A != 255 {
+		t.Errorf("averaged pixel = %v, want opaque gray", got)
+	}
+}
+
+// Test scenario:
+// 1. thumbnail of a PNG image is a JPEG fitting the size, it is cached next to the blob
+// 2. the cached thumbnail is deleted with the blob
+// 3. files other than images have no thumbnails
+func TestGetThumbnail(t *testing.T) {
+	ctx := context.Background()
+	s, fakes := newTestService(t, 0)
+
+	var content bytes.Buffer
+	if err := png.Encode(&content, image.NewRGBA(image.Rect(0, 0, 600, 300))); err != nil {
+		t.Fatal(err)
+	}
+	err := s.Create(ctx, "note", CreateFileDTO{UserUUID: "user", Name: "a.png", Size: -1, Reader: &content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := fakes.files.only(t)
+	size, _ := ParseThumbnailSize("medium")
+
+	reader, err := s.GetThumbnail(ctx, f, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbnail, err := jpeg.Decode(reader)
+	if err != nil {
+		t.Fatalf("thumbnail is not a JPEG: %v", err)
+	}
+	if b := thumbnail.Bounds(); b.Dx() != 256 || b.Dy() != 128 {
+		t.Errorf("thumbnail is %dx%d, want 256x128", b.Dx(), b.Dy())
+	}
+	name := objectKey(BlobsBucket, thumbnailName(f.SHA256, size))
+	if _, ok := fakes.storage.objects[name]; !ok {
+		t.Fatal("thumbnail is not cached")
+	}
+
+	if err = s.Delete(ctx, "note", f.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fakes.storage.objects[name]; ok {
+		t.Error("thumbnail of the deleted blob is kept")
+	}
+
+	text := &File{SHA256: "abc", ContentType: "text/plain; charset=utf-8"}
+	if _, err = s.GetThumbnail(ctx, text, size); err == nil {
+		t.Error("thumbnail of a text file is made")
+	}
+}
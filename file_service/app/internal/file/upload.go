@@ -0,0 +1,84 @@
+package file
+
+import (
+	"io"
+	"time"
+)
+
+// MinChunkSize is the smallest part of a multipart upload object storage accepts
+const MinChunkSize = 5 << 20
+
+// Upload is a resumable upload session. Chunks are written as parts of a multipart upload
+// and the file appears in the note when the last chunk is written.
+type Upload struct {
+	ID       string `json:"id" bson:"_id"`
+	NoteUUID string `json:"note_uuid" bson:"note_uuid"`
+	UserUUID string `json:"user_uuid" bson:"user_uuid"`
+	FileID   string `json:"file_id" bson:"file_id"`
+	Name     string `json:"name" bson:"name"`
+	// ContentType is sniffed from the first chunk, the type sent by the client is kept only when the sniffed one is generic
+	ContentType string `json:"content_type" bson:"content_type"`
+	Length      int64  `json:"length" bson:"length"`
+	Offset      int64  `json:"offset" bson:"offset"`
+	ChunkSize   int64  `json:"chunk_size" bson:"chunk_size"`
+	// PartETags are etags of written chunks in order
+	PartETags []string `json:"-" bson:"part_etags"`
+	// StorageUploadID identifies the multipart upload in the storage
+	StorageUploadID string `json:"-" bson:"storage_upload_id"`
+	// HashState is the marshaled SHA-256 of written chunks, so the checksum is counted once across requests
+	HashState []byte    `json:"-" bson:"hash_state"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+	// Reserved means Length is reserved in the quota of the user, uploads created before reservations have none
+	Reserved bool `json:"-" bson:"reserved"`
+}
+
+func (u Upload) Completed() bool {
+	return u.Offset == u.Length
+}
+
+// nextChunkSize is the size the next chunk must have, only the last chunk may be smaller than ChunkSize
+func (u Upload) nextChunkSize() int64 {
+	if remaining := u.Length - u.Offset; remaining < u.ChunkSize {
+		return remaining
+	}
+	return u.ChunkSize
+}
+
+type CreateUploadDTO struct {
+	NoteUUID    string
+	UserUUID    string
+	Name        string
+	ContentType string
+	Length      int64
+}
+
+// UploadChunkDTO is a chunk written at Offset, Size is Content-Length of the request
+type UploadChunkDTO struct {
+	Offset int64
+	Size   int64
+	Reader io.Reader
+}
+
+// UploadPart is a chunk written to the storage with the upload state after it
+type UploadPart struct {
+	Size        int64
+	ETag        string
+	ContentType string
+	HashState   []byte
+	ExpiresAt   time.Time
+}
+
+// Limits bound uploads of every user
+type Limits struct {
+	MaxSize   int64
+	ChunkSize int64
+	UploadTTL time.Duration
+	// UserQuota is how many bytes a user without a plan can store, 0 is unlimited
+	UserQuota int64
+	// PlanQuotas are quotas of users by plan, 0 is unlimited
+	PlanQuotas map[string]int64
+	// AllowedTypes and DeniedTypes are MIME types like image/png or image/*,
+	// any type which is not denied is allowed when AllowedTypes is empty
+	AllowedTypes []string
+	DeniedTypes  []string
+}
@@ -0,0 +1,174 @@
+package file
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Resumable uploads follow the core of the tus protocol: POST creates an upload, HEAD tells the offset
+// to resume from, PATCH writes the chunk at the offset and DELETE aborts the upload.
+const (
+	uploadsURL = "/api/uploads"
+	uploadURL  = "/api/uploads/:id"
+
+	tusVersion        = "1.0.0"
+	offsetOctetStream = "application/offset+octet-stream"
+)
+
+func (h *Handler) CreateUpload(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("CREATE UPLOAD")
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	h.Logger.Debug("get note_uuid and user_uuid from URL")
+	dto := CreateUploadDTO{
+		NoteUUID: r.URL.Query().Get("note_uuid"),
+		UserUUID: r.URL.Query().Get("user_uuid"),
+	}
+
+	h.Logger.Debug("get upload length and metadata from headers")
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil {
+		return apperror.BadRequestError("Upload-Length header is required")
+	}
+	dto.Length = length
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		return apperror.BadRequestError("Upload-Metadata header is malformed")
+	}
+	dto.Name = metadata["filename"]
+	dto.ContentType = metadata["filetype"]
+
+	upload, err := h.FileService.CreateUpload(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+
+	uploadBytes, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	setUploadHeaders(w, upload)
+	w.Header().Set("Location", path.Join(uploadsURL, upload.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(uploadBytes)
+
+	return nil
+}
+
+func (h *Handler) GetUploadOffset(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("GET UPLOAD OFFSET")
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+
+	h.Logger.Debug("get upload id from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	uploadID := params.ByName("id")
+
+	upload, err := h.FileService.GetUpload(r.Context(), uploadID, r.URL.Query().Get("user_uuid"))
+	if err != nil {
+		return err
+	}
+	setUploadHeaders(w, upload)
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (h *Handler) WriteChunk(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("WRITE CHUNK")
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	h.Logger.Debug("get upload id from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	uploadID := params.ByName("id")
+
+	h.Logger.Debug("get offset from headers")
+	if r.Header.Get("Content-Type") != offsetOctetStream {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return nil
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return apperror.BadRequestError("Upload-Offset header is required")
+	}
+	if r.ContentLength < 0 {
+		return apperror.BadRequestError("Content-Length header is required")
+	}
+
+	dto := UploadChunkDTO{
+		Offset: offset,
+		Size:   r.ContentLength,
+		Reader: r.Body,
+	}
+	upload, err := h.FileService.WriteChunk(r.Context(), uploadID, r.URL.Query().Get("user_uuid"), dto)
+	if err != nil {
+		return err
+	}
+	setUploadHeaders(w, upload)
+	if upload.Completed() {
+		w.Header().Set("Location", fileURLFor(upload))
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) AbortUpload(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("ABORT UPLOAD")
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	h.Logger.Debug("get upload id from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	uploadID := params.ByName("id")
+
+	err := h.FileService.AbortUpload(r.Context(), uploadID, r.URL.Query().Get("user_uuid"))
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func setUploadHeaders(w http.ResponseWriter, upload Upload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	// every chunk except the last one must have exactly this size
+	w.Header().Set("Upload-Chunk-Size", strconv.FormatInt(upload.ChunkSize, 10))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+func fileURLFor(upload Upload) string {
+	return path.Join(filesURL, upload.FileID) + "?note_uuid=" + upload.NoteUUID
+}
+
+// parseUploadMetadata parses comma separated pairs of a key and a base64 encoded value
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, apperror.BadRequestError("malformed metadata pair")
+		}
+	}
+	return metadata, nil
+}
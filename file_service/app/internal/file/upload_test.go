@@ -0,0 +1,108 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"io/ioutil"
+	"testing"
+)
+
+func chunk(offset, size int64) UploadChunkDTO {
+	return UploadChunkDTO{Offset: offset, Size: size, Reader: bytes.NewReader(bytes.Repeat([]byte{byte(offset)}, int(size)))}
+}
+
+// Test scenario:
+// 1. create upload of one and a half chunks
+// 2. write chunk at a wrong offset and chunk of a wrong size, both are rejected
+// 3. write both chunks, the upload is finished with the metadata record and counted to usage of the user
+func TestWriteChunk(t *testing.T) {
+	ctx := context.Background()
+	s, fakes := newTestService(t, 0)
+	length := int64(MinChunkSize + MinChunkSize/2)
+
+	upload, err := s.CreateUpload(ctx, CreateUploadDTO{NoteUUID: "note", UserUUID: "user", Name: "a.bin", Length: length})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = s.WriteChunk(ctx, upload.ID, "user", chunk(10, MinChunkSize)); !errors.Is(err, apperror.ErrUploadOffsetMismatch) {
+		t.Errorf("chunk at wrong offset: error = %v, want offset mismatch", err)
+	}
+	if _, err = s.WriteChunk(ctx, upload.ID, "user", chunk(0, 10)); err == nil {
+		t.Error("chunk of wrong size is accepted")
+	}
+	if _, err = s.WriteChunk(ctx, upload.ID, "other", chunk(0, MinChunkSize)); !errors.Is(err, apperror.ErrNotFound) {
+		t.Errorf("upload of other user: error = %v, want not found", err)
+	}
+
+	upload, err = s.WriteChunk(ctx, upload.ID, "user", chunk(0, MinChunkSize))
+	if err != nil || upload.Offset != MinChunkSize || upload.Completed() {
+		t.Fatalf("first chunk: offset = %d, error = %v", upload.Offset, err)
+	}
+	upload, err = s.WriteChunk(ctx, upload.ID, "user", chunk(MinChunkSize, length-MinChunkSize))
+	if err != nil || !upload.Completed() {
+		t.Fatalf("last chunk: offset = %d, error = %v", upload.Offset, err)
+	}
+
+	f := fakes.files.only(t)
+	if f.Size != length || f.NoteUUID != "note" || f.Uploader != "user" {
+		t.Errorf("file record = %+v", f)
+	}
+	reader, err := s.GetFile(ctx, "note", f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadAll(reader)
+	if int64(len(content)) != length || checksum(content) != f.SHA256 {
+		t.Errorf("stored file is %d bytes with checksum %s, record has %s", len(content), checksum(content), f.SHA256)
+	}
+	if fakes.usage["user"].Used != length {
+		t.Errorf("usage = %d, want %d", fakes.usage["user"].Used, length)
+	}
+	if _, err = s.GetUpload(ctx, upload.ID, "user"); !errors.Is(err, apperror.ErrNotFound) {
+		t.Errorf("finished upload is kept, error = %v", err)
+	}
+}
+
+func TestCreateUploadLimits(t *testing.T) {
+	ctx := context.Background()
+	s, fakes := newTestService(t, 3*MinChunkSize)
+	fakes.usage["user"] = Usage{Used: MinChunkSize}
+
+	tests := []struct {
+		name   string
+		length int64
+		ok     bool
+	}{
+		{"empty", 0, false},
+		{"larger than max size", 5 * MinChunkSize, false},
+		{"over quota", 3 * MinChunkSize, false},
+		{"within quota", 2 * MinChunkSize, true},
+		// the pending upload counts to the quota
+		{"over quota with pending upload", 1, false},
+	}
+	for _, tt := range tests {
+		_, err := s.CreateUpload(ctx, CreateUploadDTO{NoteUUID: "note", UserUUID: "user", Name: "a.bin", Length: tt.length})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+	}
+}
+
+func TestParseUploadMetadata(t *testing.T) {
+	metadata, err := parseUploadMetadata("filename ZG9jLnBkZg==,filetype YXBwbGljYXRpb24vcGRm,is_confidential")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata["filename"] != "doc.pdf" || metadata["filetype"] != "application/pdf" {
+		t.Errorf("metadata = %v", metadata)
+	}
+	if _, ok := metadata["is_confidential"]; !ok {
+		t.Error("key without value is lost")
+	}
+	if _, err = parseUploadMetadata("filename %%%"); err == nil {
+		t.Error("malformed value is accepted")
+	}
+}
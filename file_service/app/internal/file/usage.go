@@ -0,0 +1,145 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Usage is how many bytes a user stores against the quota of the plan
+type Usage struct {
+	// Plan is empty for users on the default quota
+	Plan string `json:"plan"`
+	Used int64  `json:"used"`
+	// Pending is reserved by uploads in progress
+	Pending int64 `json:"pending"`
+	// Quota is 0 when unlimited
+	Quota       int64 `json:"quota"`
+	MaxFileSize int64 `json:"max_file_size"`
+}
+
+// SetPlanDTO moves the user to the plan, an empty plan is the default quota
+type SetPlanDTO struct {
+	Plan string `json:"plan"`
+}
+
+// quota is how many bytes a user of the plan can store, users of plans missing in the config get the default quota
+func (l Limits) quota(plan string) int64 {
+	if quota, ok := l.PlanQuotas[plan]; ok {
+		return quota
+	}
+	return l.UserQuota
+}
+
+// checkContentType checks the type of the file against the lists, the type sniffed from the content
+// is checked against the denied types only because it is generic for many formats, like application/zip for docx.
+// Executables are sniffed by their magic bytes, so denying their types holds whatever the client declares.
+func (l Limits) checkContentType(contentType, sniffed string) error {
+	if matchesType(l.DeniedTypes, contentType) || (sniffed != "" && matchesType(l.DeniedTypes, sniffed)) {
+		return apperror.ErrContentTypeNotAllowed
+	}
+	if len(l.AllowedTypes) > 0 && !matchesType(l.AllowedTypes, contentType) {
+		return apperror.ErrContentTypeNotAllowed
+	}
+	return nil
+}
+
+// matchesType reports whether the type matches one of patterns like image/png or image/*, parameters are ignored
+func matchesType(patterns []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if pattern == mediaType || pattern == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *service) GetUsage(ctx context.Context, userUUID string) (Usage, error) {
+	if userUUID == "" {
+		return Usage{}, apperror.BadRequestError("user_uuid is required")
+	}
+	usage, err := s.usage.Get(ctx, userUUID)
+	if err != nil {
+		return Usage{}, err
+	}
+	usage.Quota = s.limits.quota(usage.Plan)
+	usage.MaxFileSize = s.limits.MaxSize
+	return usage, nil
+}
+
+func (s *service) SetPlan(ctx context.Context, userUUID string, dto SetPlanDTO) error {
+	if userUUID == "" {
+		return apperror.BadRequestError("user_uuid is required")
+	}
+	if _, ok := s.limits.PlanQuotas[dto.Plan]; dto.Plan != "" && !ok {
+		return apperror.BadRequestError(fmt.Sprintf("unknown plan %q", dto.Plan))
+	}
+	return s.usage.SetPlan(ctx, userUUID, dto.Plan)
+}
+
+// reserveUpload reserves the size of an upload in the quota of the user, so concurrent uploads can't exceed it
+// together. A streamed upload of unknown size (-1) reserves the largest file the user can upload now.
+// It returns the reserved bytes and the error to reject more bytes with, the reservation is settled by settleUpload.
+func (s *service) reserveUpload(ctx context.Context, userUUID string, size int64) (int64, error, error) {
+	usage, err := s.GetUsage(ctx, userUUID)
+	if err != nil {
+		return 0, nil, err
+	}
+	limit, limitErr := usage.MaxFileSize, apperror.ErrFileTooLarge
+	if usage.Quota > 0 {
+		if left := usage.Quota - usage.Used - usage.Pending; left < limit {
+			limit, limitErr = left, apperror.ErrQuotaExceeded
+		}
+	}
+	if size > limit || limit < 0 {
+		return 0, nil, limitErr
+	}
+	if size < 0 {
+		size = limit
+	}
+	// usage read above may be taken by a concurrent upload meanwhile, the storage checks the quota again
+	ok, err := s.usage.Reserve(ctx, userUUID, size, usage.Quota)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !ok {
+		return 0, nil, apperror.ErrQuotaExceeded
+	}
+	return size, limitErr, nil
+}
+
+// settleUpload replaces the reservation of an upload with the stored bytes, stored is 0 when the upload failed.
+// The request may be canceled already, a reservation left unsettled would take the quota for good.
+func (s *service) settleUpload(userUUID string, reserved, stored int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.usage.Settle(ctx, userUUID, reserved, stored); err != nil {
+		s.logger.Errorf("failed to settle %d reserved bytes of user %s with %d stored. err: %v", reserved, userUUID, stored, err)
+	}
+}
+
+// releaseUsage subtracts deleted files from usage of their uploaders
+func (s *service) releaseUsage(ctx context.Context, files []*File) {
+	released := make(map[string]int64)
+	for _, f := range files {
+		if f.Uploader != "" {
+			released[f.Uploader] += f.Size
+		}
+	}
+	for userUUID, size := range released {
+		if err := s.usage.Add(ctx, userUUID, -size); err != nil {
+			s.logger.Errorf("failed to release %d bytes of user %s. err: %v", size, userUUID, err)
+		}
+	}
+}
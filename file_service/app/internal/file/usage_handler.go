@@ -0,0 +1,55 @@
+package file
+
+import (
+	"encoding/json"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"net/http"
+)
+
+const (
+	usageURL = "/api/usage"
+	planURL  = "/api/usage/plan"
+)
+
+func (h *Handler) GetUsage(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("GET USAGE")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("get user_uuid from URL")
+	usage, err := h.FileService.GetUsage(r.Context(), r.URL.Query().Get("user_uuid"))
+	if err != nil {
+		return err
+	}
+
+	usageBytes, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(usageBytes)
+
+	return nil
+}
+
+func (h *Handler) SetPlan(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("SET PLAN")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("get user_uuid from URL")
+	userUUID := r.URL.Query().Get("user_uuid")
+
+	h.Logger.Debug("decode plan")
+	defer r.Body.Close()
+	var dto SetPlanDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("failed to decode data")
+	}
+
+	if err := h.FileService.SetPlan(r.Context(), userUUID, dto); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/theartofdevel/notes_system/file_service/internal/apperror"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Test scenario:
+// 1. usage of a user without a plan has the default quota and counts pending uploads
+// 2. user moved to a plan gets the quota of the plan, unknown plans are rejected
+// 3. upload over the quota of the plan is rejected with the quota error, a file over the max size with the size error
+func TestUsage(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t, MinChunkSize)
+	s.limits.PlanQuotas = map[string]int64{"pro": 8 * MinChunkSize}
+
+	if _, err := s.CreateUpload(ctx, CreateUploadDTO{NoteUUID: "note", UserUUID: "user", Name: "a.bin", Length: 10}); err != nil {
+		t.Fatal(err)
+	}
+	usage, err := s.GetUsage(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Plan != "" || usage.Quota != MinChunkSize || usage.Pending != 10 || usage.MaxFileSize != 4*MinChunkSize {
+		t.Errorf("usage = %+v", usage)
+	}
+
+	if err = s.SetPlan(ctx, "user", SetPlanDTO{Plan: "gold"}); err == nil {
+		t.Error("unknown plan is set")
+	}
+	if err = s.SetPlan(ctx, "user", SetPlanDTO{Plan: "pro"}); err != nil {
+		t.Fatal(err)
+	}
+	usage, _ = s.GetUsage(ctx, "user")
+	if usage.Plan != "pro" || usage.Quota != 8*MinChunkSize {
+		t.Errorf("usage on pro plan = %+v", usage)
+	}
+
+	dto := CreateUploadDTO{NoteUUID: "note", UserUUID: "user", Name: "a.bin", Length: 4*MinChunkSize + 1}
+	if _, err = s.CreateUpload(ctx, dto); !errors.Is(err, apperror.ErrFileTooLarge) {
+		t.Errorf("file over max size: error = %v, want file too large", err)
+	}
+	for i := 0; i < 2; i++ {
+		dto.Length = 4 * MinChunkSize
+		_, err = s.CreateUpload(ctx, dto)
+	}
+	if !errors.Is(err, apperror.ErrQuotaExceeded) {
+		t.Errorf("upload over quota: error = %v, want quota exceeded", err)
+	}
+}
+
+func TestCheckContentType(t *testing.T) {
+	limits := Limits{AllowedTypes: []string{"image/*", "application/pdf"}, DeniedTypes: []string{"image/svg+xml", "application/zip"}}
+	tests := []struct {
+		contentType string
+		sniffed     string
+		ok          bool
+	}{
+		{"image/png", "image/png", true},
+		{"application/pdf", "", true},
+		{"IMAGE/JPEG", "", true},
+		{"text/plain; charset=utf-8", "text/plain; charset=utf-8", false},
+		{"image/svg+xml", "text/xml; charset=utf-8", false},
+		// the content is checked against denied types whatever the client claims
+		{"image/png", "application/zip", false},
+	}
+	for _, tt := range tests {
+		if err := limits.checkContentType(tt.contentType, tt.sniffed); (err == nil) != tt.ok {
+			t.Errorf("%s sniffed as %q: error = %v", tt.contentType, tt.sniffed, err)
+		}
+	}
+}
+
+// Test scenario:
+// 1. streamed file of a denied type is rejected before it is stored
+// 2. resumable upload with a denied first chunk is rejected and aborted
+func TestDeniedContentType(t *testing.T) {
+	ctx := context.Background()
+	s, fakes := newTestService(t, 0)
+	s.limits.DeniedTypes = []string{"application/pdf"}
+	pdf := append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte{0}, MinChunkSize)...)
+
+	dto := CreateFileDTO{UserUUID: "user", Name: "a.bin", Size: -1, Reader: bytes.NewReader(pdf)}
+	if err := s.Create(ctx, "note", dto); !errors.Is(err, apperror.ErrContentTypeNotAllowed) {
+		t.Errorf("streamed pdf: error = %v, want type not allowed", err)
+	}
+	if len(fakes.storage.objects) != 0 {
+		t.Errorf("rejected file is stored")
+	}
+
+	upload, err := s.CreateUpload(ctx, CreateUploadDTO{NoteUUID: "note", UserUUID: "user", Name: "a.bin", Length: int64(len(pdf))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := UploadChunkDTO{Offset: 0, Size: MinChunkSize, Reader: bytes.NewReader(pdf[:MinChunkSize])}
+	if _, err = s.WriteChunk(ctx, upload.ID, "user", chunk); !errors.Is(err, apperror.ErrContentTypeNotAllowed) {
+		t.Errorf("pdf chunk: error = %v, want type not allowed", err)
+	}
+	if _, err = s.GetUpload(ctx, upload.ID, "user"); !errors.Is(err, apperror.ErrNotFound) {
+		t.Errorf("rejected upload is kept, error = %v", err)
+	}
+}
+
+// Test scenario:
+// 1. executables are rejected by their magic bytes whatever type the client declares
+// 2. text which starts like an executable is accepted
+func TestDeniedExecutables(t *testing.T) {
+	ctx := context.Background()
+	s, fakes := newTestService(t, 0)
+	s.limits.DeniedTypes = []string{"application/x-msdownload", "application/x-executable", "application/x-sh"}
+	tests := []struct {
+		content     string
+		contentType string
+		ok          bool
+	}{
+		{"MZ\x90\x00\x03\x00\x00\x00\x04\x00", "image/png", false},
+		{"\x7fELF\x02\x01\x01\x00\x00\x00", "application/pdf", false},
+		{"#!/bin/sh\nrm -rf /\n", "text/plain", false},
+		{"MZ is a notes app", "text/plain", true},
+	}
+	for _, tt := range tests {
+		dto := CreateFileDTO{UserUUID: "user", Name: "a.txt", Size: -1, ContentType: tt.contentType, Reader: strings.NewReader(tt.content)}
+		if err := s.Create(ctx, "note", dto); (err == nil) != tt.ok {
+			t.Errorf("%q declared as %s: error = %v", tt.content, tt.contentType, err)
+		}
+	}
+	fakes.files.only(t)
+}
+
+// pendingReader records the reserved bytes of the user when the upload is read
+type pendingReader struct {
+	io.Reader
+	usage   fakeUsageStorage
+	pending int64
+}
+
+func (r *pendingReader) Read(p []byte) (int, error) {
+	r.pending = r.usage["user"].Pending
+	return r.Reader.Read(p)
+}
+
+// staleUsage returns the usage read before a concurrent upload reserved its size
+type staleUsage struct {
+	fakeUsageStorage
+	stale Usage
+}
+
+func (s staleUsage) Get(ctx context.Context, userUUID string) (Usage, error) {
+	return s.stale, nil
+}
+
+// Test scenario:
+// 1. streamed upload reserves the quota left while it is read, the reservation is settled with the stored size
+// 2. failed upload releases its reservation
+// 3. upload is rejected when a concurrent upload reserved the quota after the usage was read
+// 4. aborted resumable upload releases its length
+func TestReserveUpload(t *testing.T) {
+	ctx := context.Background()
+	s, fakes := newTestService(t, 100)
+
+	reader := &pendingReader{Reader: strings.NewReader("notes"), usage: fakes.usage}
+	if err := s.Create(ctx, "note", CreateFileDTO{UserUUID: "user", Name: "a.txt", Size: -1, Reader: reader}); err != nil {
+		t.Fatal(err)
+	}
+	if reader.pending != 100 {
+		t.Errorf("reserved while read = %d, want 100", reader.pending)
+	}
+	if usage := fakes.usage["user"]; usage.Used != 5 || usage.Pending != 0 {
+		t.Errorf("usage after upload = %+v", usage)
+	}
+
+	dto := CreateFileDTO{UserUUID: "user", Name: "b.txt", Size: -1, Reader: strings.NewReader(strings.Repeat("a", 96))}
+	if err := s.Create(ctx, "note", dto); !errors.Is(err, apperror.ErrQuotaExceeded) {
+		t.Errorf("upload over quota: error = %v, want quota exceeded", err)
+	}
+	if usage := fakes.usage["user"]; usage.Used != 5 || usage.Pending != 0 {
+		t.Errorf("usage after failed upload = %+v", usage)
+	}
+
+	s.usage = staleUsage{fakeUsageStorage: fakes.usage, stale: fakes.usage["user"]}
+	if ok, _ := fakes.usage.Reserve(ctx, "user", 80, 100); !ok {
+		t.Fatal("concurrent upload is not reserved")
+	}
+	dto = CreateFileDTO{UserUUID: "user", Name: "c.txt", Size: 30, Reader: strings.NewReader(strings.Repeat("a", 30))}
+	if err := s.Create(ctx, "note", dto); !errors.Is(err, apperror.ErrQuotaExceeded) {
+		t.Errorf("upload over quota reserved concurrently: error = %v, want quota exceeded", err)
+	}
+	if usage := fakes.usage["user"]; usage.Used != 5 || usage.Pending != 80 {
+		t.Errorf("usage after rejected upload = %+v", usage)
+	}
+	fakes.files.only(t)
+
+	s.usage = fakes.usage
+	fakes.usage.Settle(ctx, "user", 80, 0)
+	upload, err := s.CreateUpload(ctx, CreateUploadDTO{NoteUUID: "note", UserUUID: "user", Name: "d.bin", Length: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.AbortUpload(ctx, upload.ID, "user"); err != nil {
+		t.Fatal(err)
+	}
+	if usage := fakes.usage["user"]; usage.Used != 5 || usage.Pending != 0 {
+		t.Errorf("usage after aborted upload = %+v", usage)
+	}
+}
@@ -10,6 +10,24 @@ import (
 	"time"
 )
 
+// uploadPartSize bounds memory used by an upload of unknown size, minio buffers one part at a time
+const uploadPartSize = 16 << 20
+
+// ObjectMeta is stored with the object
+type ObjectMeta struct {
+	Name        string
+	ContentType string
+	Uploader    string
+}
+
+func (m ObjectMeta) putOptions() minio.PutObjectOptions {
+	userMetadata := map[string]string{"Name": m.Name}
+	if m.Uploader != "" {
+		userMetadata["Uploader"] = m.Uploader
+	}
+	return minio.PutObjectOptions{UserMetadata: userMetadata, ContentType: m.ContentType}
+}
+
 type Object struct {
 	ID   string
 	Size int64
@@ -19,6 +37,8 @@ type Object struct {
 type Client struct {
 	logger      logging.Logger
 	minioClient *minio.Client
+	// core exposes multipart upload calls of resumable uploads
+	core minio.Core
 }
 
 func NewClient(endpoint, accessKeyID, secretAccessKey string, logger logging.Logger) (*Client, error) {
@@ -33,44 +53,121 @@ func NewClient(endpoint, accessKeyID, secretAccessKey string, logger logging.Log
 	return &Client{
 		logger:      logger,
 		minioClient: minioClient,
+		core:        minio.Core{Client: minioClient},
 	}, nil
 }
 
-func (c *Client) GetFile(ctx context.Context, bucketName, fileId string) (*minio.Object, error) {
+// StatFile returns metadata of the object without reading it
+func (c *Client) StatFile(ctx context.Context, bucketName, fileId string) (minio.ObjectInfo, error) {
 	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	obj, err := c.minioClient.GetObject(reqCtx, bucketName, fileId, minio.GetObjectOptions{})
+	return c.minioClient.StatObject(reqCtx, bucketName, fileId, minio.StatObjectOptions{})
+}
+
+// GetFile returns the object to read, it is not read until the first Read or Stat, so the context
+// must live as long as the object is read. Bytes from start to end inclusive are read when end >= start,
+// otherwise the whole object. The object must have the etag if it is not empty.
+func (c *Client) GetFile(ctx context.Context, bucketName, fileId, etag string, start, end int64) (*minio.Object, error) {
+	opts := minio.GetObjectOptions{}
+	if end >= start {
+		if err := opts.SetRange(start, end); err != nil {
+			return nil, err
+		}
+	}
+	if etag != "" {
+		if err := opts.SetMatchETag(etag); err != nil {
+			return nil, err
+		}
+	}
+	obj, err := c.minioClient.GetObject(ctx, bucketName, fileId, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file with id: %s from minio bucket %s. err: %w", fileId, bucketName, err)
 	}
 	return obj, nil
 }
 
-func (c *Client) GetBucketFiles(ctx context.Context, bucketName string) ([]*minio.Object, error) {
+// GetBucketFiles returns metadata of all objects of the bucket without their content
+func (c *Client) GetBucketFiles(ctx context.Context, bucketName string) ([]minio.ObjectInfo, error) {
 	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	var files []*minio.Object
-	for lobj := range c.minioClient.ListObjects(reqCtx, bucketName, minio.ListObjectsOptions{WithMetadata: true}) {
+	var files []minio.ObjectInfo
+	for lobj := range c.minioClient.ListObjects(reqCtx, bucketName, minio.ListObjectsOptions{}) {
 		if lobj.Err != nil {
 			c.logger.Errorf("failed to list object from minio bucket %s. err: %v", bucketName, lobj.Err)
 			continue
 		}
-		object, err := c.minioClient.GetObject(ctx, bucketName, lobj.Key, minio.GetObjectOptions{})
+		// listing has no user metadata, stat returns it without reading the object
+		stat, err := c.minioClient.StatObject(reqCtx, bucketName, lobj.Key, minio.StatObjectOptions{})
 		if err != nil {
-			c.logger.Errorf("failed to get object key=%s from minio bucket %s. err: %v", lobj.Key, bucketName, lobj.Err)
+			c.logger.Errorf("failed to stat object key=%s from minio bucket %s. err: %v", lobj.Key, bucketName, err)
 			continue
 		}
-		files = append(files, object)
+		files = append(files, stat)
 	}
 	return files, nil
 }
 
-func (c *Client) UploadFile(ctx context.Context, fileId, fileName, bucketName string, fileSize int64, reader io.Reader) error {
-	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
+// UploadFile streams reader to the bucket, fileSize is -1 when it is not known in advance,
+// then the object is uploaded in parts of uploadPartSize
+func (c *Client) UploadFile(ctx context.Context, fileId, bucketName string, meta ObjectMeta, fileSize int64, reader io.Reader) error {
+	if err := c.ensureBucket(ctx, bucketName); err != nil {
+		return err
+	}
+
+	c.logger.Debugf("put new object %s to bucket %s", meta.Name, bucketName)
+	opts := meta.putOptions()
+	opts.PartSize = uploadPartSize
+	_, err := c.minioClient.PutObject(ctx, bucketName, fileId, reader, fileSize, opts)
+	if err != nil {
+		return fmt.Errorf("failed to upload file. err: %w", err)
+	}
+	return nil
+}
+
+// NewMultipartUpload starts an upload which is sent part by part, parts except the last one must be at least 5MB
+func (c *Client) NewMultipartUpload(ctx context.Context, fileId, bucketName string, meta ObjectMeta) (string, error) {
+	if err := c.ensureBucket(ctx, bucketName); err != nil {
+		return "", err
+	}
+
+	uploadID, err := c.core.NewMultipartUpload(ctx, bucketName, fileId, meta.putOptions())
+	if err != nil {
+		return "", fmt.Errorf("failed to start multipart upload. err: %w", err)
+	}
+	return uploadID, nil
+}
+
+// PutObjectPart uploads part with number starting from 1 and returns its etag
+func (c *Client) PutObjectPart(ctx context.Context, fileId, bucketName, uploadID string, number int, reader io.Reader, size int64) (string, error) {
+	part, err := c.core.PutObjectPart(ctx, bucketName, fileId, uploadID, number, reader, size, "", "", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload part %d. err: %w", number, err)
+	}
+	return part.ETag, nil
+}
+
+// CompleteMultipartUpload joins parts with the etags in order into the object
+func (c *Client) CompleteMultipartUpload(ctx context.Context, fileId, bucketName, uploadID string, etags []string) error {
+	parts := make([]minio.CompletePart, 0, len(etags))
+	for i, etag := range etags {
+		parts = append(parts, minio.CompletePart{PartNumber: i + 1, ETag: etag})
+	}
+	if _, err := c.core.CompleteMultipartUpload(ctx, bucketName, fileId, uploadID, parts); err != nil {
+		return fmt.Errorf("failed to complete multipart upload. err: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) AbortMultipartUpload(ctx context.Context, fileId, bucketName, uploadID string) error {
+	if err := c.core.AbortMultipartUpload(ctx, bucketName, fileId, uploadID); err != nil {
+		return fmt.Errorf("failed to abort multipart upload. err: %w", err)
+	}
+	return nil
+}
 
+func (c *Client) ensureBucket(ctx context.Context, bucketName string) error {
 	exists, errBucketExists := c.minioClient.BucketExists(ctx, bucketName)
 	if errBucketExists != nil || !exists {
 		c.logger.Warnf("no bucket %s. creating new one...", bucketName)
@@ -79,18 +176,6 @@ func (c *Client) UploadFile(ctx context.Context, fileId, fileName, bucketName st
 			return fmt.Errorf("failed to create new bucket. err: %w", err)
 		}
 	}
-
-	c.logger.Debugf("put new object %s to bucket %s", fileName, bucketName)
-	_, err := c.minioClient.PutObject(reqCtx, bucketName, fileId, reader, fileSize,
-		minio.PutObjectOptions{
-			UserMetadata: map[string]string{
-				"Name": fileName,
-			},
-			ContentType: "application/octet-stream",
-		})
-	if err != nil {
-		return fmt.Errorf("failed to upload file. err: %w", err)
-	}
 	return nil
 }
 
@@ -101,3 +186,98 @@ func (c *Client) DeleteFile(ctx context.Context, noteUUID, fileName string) erro
 	}
 	return nil
 }
+
+// DeleteBucket removes all objects of the bucket and the bucket itself, a missing bucket is not an error
+func (c *Client) DeleteBucket(ctx context.Context, bucketName string) (int, error) {
+	exists, err := c.minioClient.BucketExists(ctx, bucketName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check bucket %s. err: %w", bucketName, err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	objects := make(chan minio.ObjectInfo)
+	var listErr error
+	var deleted int
+	go func() {
+		defer close(objects)
+		for lobj := range c.minioClient.ListObjects(ctx, bucketName, minio.ListObjectsOptions{Recursive: true}) {
+			if lobj.Err != nil {
+				listErr = lobj.Err
+				return
+			}
+			deleted++
+			objects <- lobj
+		}
+	}()
+	// errors channel is drained completely so listing goroutine is not blocked
+	var removeErr error
+	for rErr := range c.minioClient.RemoveObjects(ctx, bucketName, objects, minio.RemoveObjectsOptions{}) {
+		if removeErr == nil {
+			removeErr = fmt.Errorf("failed to delete object %s from bucket %s. err: %w", rErr.ObjectName, bucketName, rErr.Err)
+		}
+	}
+	if removeErr != nil {
+		return 0, removeErr
+	}
+	if listErr != nil {
+		return 0, fmt.Errorf("failed to list objects of bucket %s. err: %w", bucketName, listErr)
+	}
+
+	if err = c.minioClient.RemoveBucket(ctx, bucketName); err != nil {
+		return deleted, fmt.Errorf("failed to delete bucket %s. err: %w", bucketName, err)
+	}
+	return deleted, nil
+}
+
+// MoveFile copies the object on the server side and removes the source, the destination is replaced
+func (c *Client) MoveFile(ctx context.Context, bucketName, srcName, dstName string) error {
+	dst := minio.CopyDestOptions{Bucket: bucketName, Object: dstName}
+	src := minio.CopySrcOptions{Bucket: bucketName, Object: srcName}
+	// compose copies objects larger than 5GB in parts, a single copy can't
+	if _, err := c.minioClient.ComposeObject(ctx, dst, src); err != nil {
+		return fmt.Errorf("failed to copy %s to %s in bucket %s. err: %w", srcName, dstName, bucketName, err)
+	}
+	return c.DeleteFile(ctx, bucketName, srcName)
+}
+
+// ListFiles returns keys, sizes and modification times of all objects of the bucket, a missing bucket is empty
+func (c *Client) ListFiles(ctx context.Context, bucketName string) ([]minio.ObjectInfo, error) {
+	var objects []minio.ObjectInfo
+	for lobj := range c.minioClient.ListObjects(ctx, bucketName, minio.ListObjectsOptions{Recursive: true}) {
+		if lobj.Err != nil {
+			if IsNotFound(lobj.Err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to list objects of bucket %s. err: %w", bucketName, lobj.Err)
+		}
+		objects = append(objects, lobj)
+	}
+	return objects, nil
+}
+
+// ListBuckets returns names of all buckets
+func (c *Client) ListBuckets(ctx context.Context) ([]string, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	buckets, err := c.minioClient.ListBuckets(reqCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list buckets. err: %w", err)
+	}
+	names := make([]string, 0, len(buckets))
+	for _, bucket := range buckets {
+		names = append(names, bucket.Name)
+	}
+	return names, nil
+}
+
+// IsNotFound reports whether the error is about a missing object or bucket
+func IsNotFound(err error) bool {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NoSuchBucket":
+		return true
+	}
+	return false
+}
@@ -158,4 +158,44 @@ func (s *db) Delete(ctx context.Context, uuid string) error {
 	s.logger.Tracef("Delete %v documents.\n", result.DeletedCount)
 
 	return nil
-}
\ No newline at end of file
+}
+
+func (s *db) DeleteByCategoryUUID(ctx context.Context, categoryUUID string) (int64, error) {
+	filter := bson.M{"category_uuid": categoryUUID}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	result, err := s.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+
+	s.logger.Tracef("Delete %v documents.\n", result.DeletedCount)
+
+	return result.DeletedCount, nil
+}
+
+func (s *db) TagsStats(ctx context.Context, tagIDs []int) (stats []note.TagStats, err error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tags": bson.M{"$in": tagIDs}}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$match", Value: bson.M{"tags": bson.M{"$in": tagIDs}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":         "$tags",
+			"notes_count": bson.M{"$sum": 1},
+			"last_used":   bson.M{"$max": "$updated_at"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "notes_count", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cur, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return stats, fmt.Errorf("failed to execute aggregation. error: %w", err)
+	}
+	if err = cur.All(ctx, &stats); err != nil {
+		return stats, fmt.Errorf("failed to decode document. error: %w", err)
+	}
+	return stats, nil
+}
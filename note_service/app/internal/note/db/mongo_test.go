@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/theartofdevel/notes_system/note_service/internal/note"
+	"github.com/theartofdevel/notes_system/note_service/pkg/logging"
+	mongodb "github.com/theartofdevel/notes_system/note_service/pkg/mongodb"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestStorage connects to the mongodb of NOTE_SERVICE_TEST_MONGODB_HOST, a collection of the test is dropped after it
+func newTestStorage(t *testing.T) *db {
+	host := os.Getenv("NOTE_SERVICE_TEST_MONGODB_HOST")
+	if host == "" {
+		t.Skip("NOTE_SERVICE_TEST_MONGODB_HOST is not set")
+	}
+	port := os.Getenv("NOTE_SERVICE_TEST_MONGODB_PORT")
+	if port == "" {
+		port = "27017"
+	}
+	database, err := mongodb.NewClient(context.Background(), host, port, "", "", "notes_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	collection := fmt.Sprintf("notes_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		database.Collection(collection).Drop(context.Background())
+	})
+	return NewStorage(database, collection, logging.Logger{Entry: logrus.NewEntry(logrus.New())}).(*db)
+}
+
+// Test scenario:
+// 1. tags are counted by notes having them, sorted by the count and then by the tag id
+// 2. last use is the latest update of a note with the tag, updating a note moves it
+// 3. only requested tags are counted, tags of other users on the same notes are left out
+func TestTagsStats(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+	day := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	// tags 1, 2 and 3 are of the user, 7 is of another one
+	notes := []note.Note{
+		{Header: "a", Tags: []int{1, 2}, UpdatedAt: day},
+		{Header: "b", Tags: []int{2, 7}, UpdatedAt: day.Add(time.Hour)},
+		{Header: "c", Tags: []int{3}, UpdatedAt: day.Add(2 * time.Hour)},
+		{Header: "d", Tags: []int{7}, UpdatedAt: day.Add(3 * time.Hour)},
+	}
+	var ids []string
+	for _, n := range notes {
+		id, err := s.Create(ctx, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	stats, err := s.TagsStats(ctx, []int{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []note.TagStats{
+		{TagID: 2, NotesCount: 2, LastUsed: day.Add(time.Hour)},
+		{TagID: 1, NotesCount: 1, LastUsed: day},
+		{TagID: 3, NotesCount: 1, LastUsed: day.Add(2 * time.Hour)},
+	}
+	checkStats(t, stats, want)
+
+	updated := day.Add(24 * time.Hour)
+	if err = s.Update(ctx, note.Note{UUID: ids[0], Tags: []int{1}, UpdatedAt: updated}); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = s.TagsStats(ctx, []int{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []note.TagStats{
+		{TagID: 1, NotesCount: 1, LastUsed: updated},
+		{TagID: 2, NotesCount: 1, LastUsed: day.Add(time.Hour)},
+		{TagID: 3, NotesCount: 1, LastUsed: day.Add(2 * time.Hour)},
+	}
+	checkStats(t, stats, want)
+
+	stats, err = s.TagsStats(ctx, []int{7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStats(t, stats, []note.TagStats{{TagID: 7, NotesCount: 2, LastUsed: day.Add(3 * time.Hour)}})
+
+	if stats, err = s.TagsStats(ctx, []int{42}); err != nil || len(stats) != 0 {
+		t.Errorf("unused tag: stats = %+v, error = %v", stats, err)
+	}
+}
+
+func checkStats(t *testing.T, stats, want []note.TagStats) {
+	t.Helper()
+	if len(stats) != len(want) {
+		t.Fatalf("stats = %+v, want %+v", stats, want)
+	}
+	for i := range want {
+		if stats[i].TagID != want[i].TagID || stats[i].NotesCount != want[i].NotesCount || !stats[i].LastUsed.Equal(want[i].LastUsed) {
+			t.Errorf("stats[%d] = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+}
@@ -7,11 +7,15 @@ import (
 	"github.com/theartofdevel/notes_system/note_service/internal/apperror"
 	"github.com/theartofdevel/notes_system/note_service/pkg/logging"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 const (
 	notesURL = "/api/notes"
 	noteURL  = "/api/notes/:uuid"
+
+	tagsStatsURL = "/api/stats/tags"
 )
 
 type Handler struct {
@@ -25,6 +29,8 @@ func (h *Handler) Register(router *httprouter.Router) {
 	router.HandlerFunc(http.MethodPost, notesURL, apperror.Middleware(h.CreateNote))
 	router.HandlerFunc(http.MethodPatch, noteURL, apperror.Middleware(h.PartiallyUpdateNote))
 	router.HandlerFunc(http.MethodDelete, noteURL, apperror.Middleware(h.DeleteNote))
+	router.HandlerFunc(http.MethodDelete, notesURL, apperror.Middleware(h.DeleteNotesByCategory))
+	router.HandlerFunc(http.MethodGet, tagsStatsURL, apperror.Middleware(h.GetTagsStats))
 }
 
 func (h *Handler) GetNote(w http.ResponseWriter, r *http.Request) error {
@@ -148,3 +154,68 @@ func (h *Handler) DeleteNote(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+func (h *Handler) DeleteNotesByCategory(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("DELETE NOTES BY CATEGORY")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("get category_uuid from URL")
+	categoryUUID := r.URL.Query().Get("category_uuid")
+	if categoryUUID == "" {
+		return apperror.BadRequestError("category_uuid query parameter is required")
+	}
+
+	deleted, err := h.NoteService.DeleteByCategoryUUID(r.Context(), categoryUUID)
+	if err != nil {
+		return err
+	}
+
+	resultBytes, err := json.Marshal(DeleteResult{Deleted: deleted})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultBytes)
+
+	return nil
+}
+
+func (h *Handler) GetTagsStats(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("GET TAGS STATS")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("get tag_id from URL")
+	idsParam := r.URL.Query().Get("tag_id")
+	if idsParam == "" {
+		return apperror.BadRequestError("tag_id query parameter is required and must be a comma separated integers")
+	}
+
+	h.Logger.Debug("split tag_id by comma and parse to int")
+	var tagIDs []int
+	for _, idStr := range strings.Split(idsParam, ",") {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			return apperror.BadRequestError("tag_id query parameter is required and must be a comma separated integers")
+		}
+		tagIDs = append(tagIDs, id)
+	}
+
+	stats, err := h.NoteService.GetTagsStats(r.Context(), tagIDs)
+	if err != nil {
+		return err
+	}
+	if stats == nil {
+		stats = []TagStats{}
+	}
+
+	statsBytes, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(statsBytes)
+
+	return nil
+}
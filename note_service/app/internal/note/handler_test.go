@@ -0,0 +1,70 @@
+package note
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/sirupsen/logrus"
+	"github.com/theartofdevel/notes_system/note_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/note_service/pkg/logging"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeStorage counts notes of tags it is given, other methods are not used
+type fakeStorage struct {
+	Storage
+	stats  []TagStats
+	tagIDs []int
+}
+
+func (s *fakeStorage) TagsStats(ctx context.Context, tagIDs []int) ([]TagStats, error) {
+	s.tagIDs = tagIDs
+	return s.stats, nil
+}
+
+func getTagsStats(h *Handler, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, tagsStatsURL+"?"+query, nil)
+	w := httptest.NewRecorder()
+	apperror.Middleware(h.GetTagsStats)(w, req)
+	return w
+}
+
+// Test scenario:
+// 1. tag ids of the query are given to the storage and its stats are returned as is
+// 2. tags without notes are an empty list, not null
+// 3. tag ids which are not integers are rejected
+func TestGetTagsStats(t *testing.T) {
+	storage := &fakeStorage{}
+	s, _ := NewService(storage, logging.Logger{Entry: logrus.NewEntry(logrus.New())})
+	h := &Handler{Logger: logging.Logger{Entry: logrus.NewEntry(logrus.New())}, NoteService: s}
+
+	storage.stats = []TagStats{{TagID: 2, NotesCount: 3, LastUsed: time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)}}
+	w := getTagsStats(h, "tag_id=2,5")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if !reflect.DeepEqual(storage.tagIDs, []int{2, 5}) {
+		t.Errorf("storage got tags %v, want [2 5]", storage.tagIDs)
+	}
+	var stats []TagStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stats, storage.stats) {
+		t.Errorf("stats = %+v, want %+v", stats, storage.stats)
+	}
+
+	storage.stats = nil
+	if w = getTagsStats(h, "tag_id=5"); w.Code != http.StatusOK || w.Body.String() != "[]" {
+		t.Errorf("unused tag: status = %d, body = %s", w.Code, w.Body)
+	}
+
+	for _, query := range []string{"", "tag_id=", "tag_id=1,x"} {
+		if w = getTagsStats(h, query); w.Code != http.StatusBadRequest {
+			t.Errorf("query %q: status = %d, want 400", query, w.Code)
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package note
 
+import "time"
+
 type Note struct {
-	UUID         string `json:"uuid" bson:"_id,omitempty"`
-	Header       string `json:"header" bson:"header,omitempty"`
-	Body         string `json:"body,omitempty" bson:"body,omitempty"`
-	ShortBody    string `json:"short_body,omitempty" bson:"short_body,omitempty"`
-	CategoryUUID string `json:"category_uuid" bson:"category_uuid,omitempty"`
-	Tags         []int  `json:"tags" bson:"tags,omitempty"`
+	UUID         string    `json:"uuid" bson:"_id,omitempty"`
+	Header       string    `json:"header" bson:"header,omitempty"`
+	Body         string    `json:"body,omitempty" bson:"body,omitempty"`
+	ShortBody    string    `json:"short_body,omitempty" bson:"short_body,omitempty"`
+	CategoryUUID string    `json:"category_uuid" bson:"category_uuid,omitempty"`
+	Tags         []int     `json:"tags" bson:"tags,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at" bson:"updated_at,omitempty"`
 }
 
 func (cn *Note) GenerateShortBody() {
@@ -25,6 +28,7 @@ func NewNote(dto CreateNoteDTO) Note {
 		Body:         dto.Body,
 		CategoryUUID: dto.CategoryUUID,
 		Tags:         dto.Tags,
+		UpdatedAt:    time.Now().UTC(),
 	}
 }
 
@@ -35,9 +39,22 @@ func UpdatedNote(dto UpdateNoteDTO) Note {
 		Body:         dto.Body,
 		CategoryUUID: dto.CategoryUUID,
 		Tags:         dto.Tags,
+		UpdatedAt:    time.Now().UTC(),
 	}
 }
 
+// TagStats is usage of a single tag across notes
+type TagStats struct {
+	TagID      int       `json:"tag_id" bson:"_id"`
+	NotesCount int       `json:"notes_count" bson:"notes_count"`
+	LastUsed   time.Time `json:"last_used" bson:"last_used"`
+}
+
+// DeleteResult is returned by bulk deletion
+type DeleteResult struct {
+	Deleted int64 `json:"deleted"`
+}
+
 type CreateNoteDTO struct {
 	Header       string `json:"header" bson:"header"`
 	Body         string `json:"body" bson:"body"`
@@ -28,6 +28,8 @@ type Service interface {
 	GetByCategoryUUID(ctx context.Context, uuid string) ([]Note, error)
 	Update(ctx context.Context, dto UpdateNoteDTO) error
 	Delete(ctx context.Context, uuid string) error
+	DeleteByCategoryUUID(ctx context.Context, categoryUUID string) (int64, error)
+	GetTagsStats(ctx context.Context, tagIDs []int) ([]TagStats, error)
 }
 
 func (s service) Create(ctx context.Context, dto CreateNoteDTO) (noteUUID string, err error) {
@@ -99,3 +101,20 @@ func (s service) Delete(ctx context.Context, uuid string) error {
 	}
 	return err
 }
+
+func (s service) DeleteByCategoryUUID(ctx context.Context, categoryUUID string) (int64, error) {
+	deleted, err := s.storage.DeleteByCategoryUUID(ctx, categoryUUID)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to delete notes by category. error: %w", err)
+	}
+	return deleted, nil
+}
+
+func (s service) GetTagsStats(ctx context.Context, tagIDs []int) (stats []TagStats, err error) {
+	stats, err = s.storage.TagsStats(ctx, tagIDs)
+
+	if err != nil {
+		return stats, fmt.Errorf("failed to get tags stats. error: %w", err)
+	}
+	return stats, nil
+}
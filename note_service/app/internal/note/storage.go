@@ -10,4 +10,6 @@ type Storage interface {
 	FindByCategoryUUID(ctx context.Context, uuid string) ([]Note, error)
 	Update(ctx context.Context, note Note) error
 	Delete(ctx context.Context, uuid string) error
+	DeleteByCategoryUUID(ctx context.Context, categoryUUID string) (int64, error)
+	TagsStats(ctx context.Context, tagIDs []int) ([]TagStats, error)
 }
@@ -45,7 +45,12 @@ func main() {
 		panic(err)
 	}
 
-	tagService, err := tag.NewService(tagStorage, logger)
+	var palette tag.Palette
+	for _, pc := range cfg.Palette {
+		palette = append(palette, tag.PaletteColor{Name: pc.Name, Hex: pc.Hex})
+	}
+
+	tagService, err := tag.NewService(tagStorage, palette, logger)
 	if err != nil {
 		panic(err)
 	}
@@ -22,6 +22,10 @@ type Config struct {
 		Database   string `yaml:"database" env-required:"true"`
 		Collection string `yaml:"collection" env-required:"true"`
 	} `yaml:"mongodb" env-required:"true"`
+	Palette []struct {
+		Name string `yaml:"name"`
+		Hex  string `yaml:"hex"`
+	} `yaml:"palette"`
 }
 
 var instance *Config
@@ -31,7 +31,7 @@ func (s *db) Create(ctx context.Context, t tag.Tag) (id int, err error) {
 	defer cancel()
 
 	findOptions := options.FindOptions{}
-	findOptions.SetSort(bson.D{{"_id", -1}})
+	findOptions.SetSort(bson.D{{Key: "_id", Value: -1}})
 	findOptions.SetLimit(1)
 	var nTag tag.Tag
 	cursor, err := s.collection.Find(nCtx, bson.M{}, &findOptions)
@@ -112,6 +112,22 @@ func (s *db) FindMany(ctx context.Context, ids []int) (tags []tag.Tag, err error
 	return tags, fmt.Errorf("failed to decode document. error: %w", err)
 }
 
+func (s *db) FindByOwner(ctx context.Context, ownerID string) (tags []tag.Tag, err error) {
+	filter := bson.M{"owner_id": ownerID}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	cur, err := s.collection.Find(ctx, filter)
+	if err != nil {
+		return tags, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if err = cur.All(ctx, &tags); err == nil {
+		return tags, nil
+	}
+	return tags, fmt.Errorf("failed to decode document. error: %w", err)
+}
+
 func (s *db) Update(ctx context.Context, t tag.Tag) error {
 	filter := bson.M{"_id": t.ID}
 
@@ -163,4 +179,19 @@ func (s *db) Delete(ctx context.Context, id int) error {
 	s.logger.Tracef("Delete %v documents.\n", result.DeletedCount)
 
 	return nil
-}
\ No newline at end of file
+}
+
+func (s *db) DeleteByOwner(ctx context.Context, ownerID string) (int64, error) {
+	filter := bson.M{"owner_id": ownerID}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	result, err := s.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+
+	s.logger.Tracef("Delete %v documents.\n", result.DeletedCount)
+
+	return result.DeletedCount, nil
+}
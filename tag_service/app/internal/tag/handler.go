@@ -14,6 +14,8 @@ import (
 const (
 	tagsURL = "/api/tags"
 	tagURL  = "/api/tags/:id"
+
+	paletteSegment = "palette"
 )
 
 type Handler struct {
@@ -27,8 +29,10 @@ func (h *Handler) Register(router *httprouter.Router) {
 	router.HandlerFunc(http.MethodPost, tagsURL, apperror.Middleware(h.CreateTag))
 	router.HandlerFunc(http.MethodPatch, tagURL, apperror.Middleware(h.PartiallyUpdateTag))
 	router.HandlerFunc(http.MethodDelete, tagURL, apperror.Middleware(h.DeleteTag))
+	router.HandlerFunc(http.MethodDelete, tagsURL, apperror.Middleware(h.DeleteTagsByOwner))
 }
 
+// GetTag returns the tag by id, /api/tags/palette is served here as well
 func (h *Handler) GetTag(w http.ResponseWriter, r *http.Request) error {
 	h.Logger.Info("GET TAG")
 	w.Header().Set("Content-Type", "application/json")
@@ -36,6 +40,9 @@ func (h *Handler) GetTag(w http.ResponseWriter, r *http.Request) error {
 	h.Logger.Debug("get id from context")
 	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
 	tagIDStr := params.ByName("id")
+	if tagIDStr == paletteSegment {
+		return h.GetPalette(w, r)
+	}
 	id, err := strconv.Atoi(tagIDStr)
 	if err != nil {
 		return apperror.BadRequestError("id resource identifier is required and must be an integer")
@@ -65,7 +72,11 @@ func (h *Handler) GetTags(w http.ResponseWriter, r *http.Request) error {
 	h.Logger.Debug("get id from URL")
 	idsParam := r.URL.Query().Get("id")
 	if idsParam == "" {
-		return apperror.BadRequestError("id query parameter is required and must be a comma separated integers")
+		ownerID := r.URL.Query().Get("owner_id")
+		if ownerID == "" {
+			return apperror.BadRequestError("id query parameter is required and must be a comma separated integers")
+		}
+		return h.getTagsByOwner(w, r, ownerID)
 	}
 
 	h.Logger.Debug("split id by comma and parse to int")
@@ -96,6 +107,24 @@ func (h *Handler) GetTags(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+func (h *Handler) getTagsByOwner(w http.ResponseWriter, r *http.Request, ownerID string) error {
+	tags, err := h.TagService.GetByOwner(r.Context(), ownerID)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Debug("marshal tags")
+	tagsBytes, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(tagsBytes)
+
+	return nil
+}
+
 func (h *Handler) CreateTag(w http.ResponseWriter, r *http.Request) error {
 	h.Logger.Info("CREATE TAG")
 	w.Header().Set("Content-Type", "application/json")
@@ -173,3 +202,50 @@ func (h *Handler) DeleteTag(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+func (h *Handler) DeleteTagsByOwner(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("DELETE TAGS BY OWNER")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("get owner_id from URL")
+	ownerID := r.URL.Query().Get("owner_id")
+	if ownerID == "" {
+		return apperror.BadRequestError("owner_id query parameter is required")
+	}
+
+	deleted, err := h.TagService.DeleteByOwner(r.Context(), ownerID)
+	if err != nil {
+		return err
+	}
+
+	resultBytes, err := json.Marshal(DeleteResult{Deleted: deleted})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultBytes)
+
+	return nil
+}
+
+func (h *Handler) GetPalette(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("GET PALETTE")
+	w.Header().Set("Content-Type", "application/json")
+
+	palette := h.TagService.GetPalette()
+	if palette == nil {
+		palette = Palette{}
+	}
+
+	h.Logger.Debug("marshal palette")
+	paletteBytes, err := json.Marshal(palette)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(paletteBytes)
+
+	return nil
+}
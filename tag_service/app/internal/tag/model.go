@@ -23,6 +23,11 @@ func UpdatedTag(dto UpdateTagDTO) Tag {
 	}
 }
 
+// DeleteResult is returned by bulk deletion
+type DeleteResult struct {
+	Deleted int64 `json:"deleted"`
+}
+
 type CreateTagDTO struct {
 	Name    string `json:"name" bson:"name"`
 	Color   string `json:"color" bson:"color"`
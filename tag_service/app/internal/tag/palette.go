@@ -0,0 +1,48 @@
+package tag
+
+import (
+	"regexp"
+	"strings"
+)
+
+var hexColorRegexp = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type PaletteColor struct {
+	Name string `json:"name"`
+	Hex  string `json:"hex"`
+}
+
+type Palette []PaletteColor
+
+// Normalize returns color as lower case #rrggbb. Palette names are resolved to their hex value.
+func (p Palette) Normalize(color string) (string, bool) {
+	color = strings.TrimSpace(color)
+	if hexColorRegexp.MatchString(color) {
+		return strings.ToLower(color), true
+	}
+	for _, pc := range p {
+		if strings.EqualFold(pc.Name, color) {
+			return strings.ToLower(pc.Hex), true
+		}
+	}
+	return "", false
+}
+
+// Pick returns the palette color least used by the given tags, preferring palette order on ties.
+func (p Palette) Pick(tags []Tag) string {
+	if len(p) == 0 {
+		return ""
+	}
+	usage := make(map[string]int, len(tags))
+	for _, t := range tags {
+		usage[strings.ToLower(t.Color)]++
+	}
+	picked := strings.ToLower(p[0].Hex)
+	for _, pc := range p[1:] {
+		hex := strings.ToLower(pc.Hex)
+		if usage[hex] < usage[picked] {
+			picked = hex
+		}
+	}
+	return picked
+}
@@ -0,0 +1,58 @@
+package tag
+
+import "testing"
+
+var testPalette = Palette{
+	{Name: "red", Hex: "#E53935"},
+	{Name: "green", Hex: "#43a047"},
+	{Name: "blue", Hex: "#1E88E5"},
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		color string
+		want  string
+		ok    bool
+	}{
+		{"#AABBCC", "#aabbcc", true},
+		{" #aabbcc ", "#aabbcc", true},
+		{"Red", "#e53935", true},
+		{"GREEN", "#43a047", true},
+		{"purple", "", false},
+		{"#abc", "", false},
+		{"aabbcc", "", false},
+		{"#gggggg", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := testPalette.Normalize(tt.color)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Normalize(%q) = %q, %v, want %q, %v", tt.color, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPick(t *testing.T) {
+	tests := []struct {
+		name   string
+		colors []string
+		want   string
+	}{
+		{"no tags take the first color", nil, "#e53935"},
+		{"least used color", []string{"#e53935", "#E53935", "#43A047", "#43a047", "#1e88e5"}, "#1e88e5"},
+		{"tie is broken by palette order", []string{"#e53935"}, "#43a047"},
+		{"colors outside the palette are ignored", []string{"#000000", "#000000"}, "#e53935"},
+	}
+	for _, tt := range tests {
+		var tags []Tag
+		for _, c := range tt.colors {
+			tags = append(tags, Tag{Color: c})
+		}
+		if got := testPalette.Pick(tags); got != tt.want {
+			t.Errorf("%s: Pick = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := (Palette{}).Pick(nil); got != "" {
+		t.Errorf("empty palette: Pick = %q", got)
+	}
+}
@@ -12,12 +12,19 @@ var _ Service = &service{}
 
 type service struct {
 	storage Storage
+	palette Palette
 	logger  logging.Logger
 }
 
-func NewService(tagStorage Storage, logger logging.Logger) (Service, error) {
+func NewService(tagStorage Storage, palette Palette, logger logging.Logger) (Service, error) {
+	for _, pc := range palette {
+		if !hexColorRegexp.MatchString(pc.Hex) {
+			return nil, fmt.Errorf("invalid hex %q of palette color %q", pc.Hex, pc.Name)
+		}
+	}
 	return &service{
 		storage: tagStorage,
+		palette: palette,
 		logger:  logger,
 	}, nil
 }
@@ -26,13 +33,31 @@ type Service interface {
 	Create(ctx context.Context, dto CreateTagDTO) (int, error)
 	GetOne(ctx context.Context, id int) (Tag, error)
 	GetMany(ctx context.Context, ids []int) ([]Tag, error)
+	GetByOwner(ctx context.Context, ownerID string) ([]Tag, error)
 	Update(ctx context.Context, dto UpdateTagDTO) error
 	Delete(ctx context.Context, id int) error
+	DeleteByOwner(ctx context.Context, ownerID string) (int64, error)
+	GetPalette() Palette
 }
 
 func (s service) Create(ctx context.Context, dto CreateTagDTO) (tagID int, err error) {
 	tag := NewTag(dto)
 
+	if tag.Color == "" {
+		s.logger.Debug("pick color from palette")
+		ownerTags, err := s.storage.FindByOwner(ctx, tag.OwnerID)
+		if err != nil {
+			return tagID, fmt.Errorf("failed to get tags by owner. error: %w", err)
+		}
+		tag.Color = s.palette.Pick(ownerTags)
+	} else {
+		color, ok := s.palette.Normalize(tag.Color)
+		if !ok {
+			return tagID, apperror.BadRequestError("color must be #RRGGBB or a palette color name")
+		}
+		tag.Color = color
+	}
+
 	tagID, err = s.storage.Create(ctx, tag)
 
 	if err != nil {
@@ -73,6 +98,19 @@ func (s service) GetMany(ctx context.Context, ids []int) (tags []Tag, err error)
 	return tags, nil
 }
 
+func (s service) GetByOwner(ctx context.Context, ownerID string) (tags []Tag, err error) {
+	tags, err = s.storage.FindByOwner(ctx, ownerID)
+
+	if err != nil {
+		return tags, fmt.Errorf("failed to get tags by owner. error: %w", err)
+	}
+	if tags == nil {
+		tags = []Tag{}
+	}
+
+	return tags, nil
+}
+
 func (s service) Update(ctx context.Context, dto UpdateTagDTO) error {
 	if dto.Name == "" && dto.Color == "" {
 		return apperror.BadRequestError("no data to update")
@@ -80,6 +118,14 @@ func (s service) Update(ctx context.Context, dto UpdateTagDTO) error {
 
 	tag := UpdatedTag(dto)
 
+	if tag.Color != "" {
+		color, ok := s.palette.Normalize(tag.Color)
+		if !ok {
+			return apperror.BadRequestError("color must be #RRGGBB or a palette color name")
+		}
+		tag.Color = color
+	}
+
 	err := s.storage.Update(ctx, tag)
 
 	if err != nil {
@@ -91,6 +137,14 @@ func (s service) Update(ctx context.Context, dto UpdateTagDTO) error {
 	return nil
 }
 
+func (s service) DeleteByOwner(ctx context.Context, ownerID string) (int64, error) {
+	deleted, err := s.storage.DeleteByOwner(ctx, ownerID)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to delete tags by owner. error: %w", err)
+	}
+	return deleted, nil
+}
+
 func (s service) Delete(ctx context.Context, id int) error {
 	err := s.storage.Delete(ctx, id)
 
@@ -102,3 +156,7 @@ func (s service) Delete(ctx context.Context, id int) error {
 	}
 	return nil
 }
+
+func (s service) GetPalette() Palette {
+	return s.palette
+}
@@ -8,6 +8,8 @@ type Storage interface {
 	Create(ctx context.Context, t Tag) (int, error)
 	FindOne(ctx context.Context, id int) (Tag, error)
 	FindMany(ctx context.Context, ids []int) ([]Tag, error)
+	FindByOwner(ctx context.Context, ownerID string) ([]Tag, error)
 	Update(ctx context.Context, t Tag) error
 	Delete(ctx context.Context, id int) error
+	DeleteByOwner(ctx context.Context, ownerID string) (int64, error)
 }
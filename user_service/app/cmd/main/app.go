@@ -6,9 +6,14 @@ import (
 	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"github.com/theartofdevel/notes_system/user_service/internal/config"
+	"github.com/theartofdevel/notes_system/user_service/internal/token"
+	tokenDB "github.com/theartofdevel/notes_system/user_service/internal/token/db"
 	"github.com/theartofdevel/notes_system/user_service/internal/user"
 	"github.com/theartofdevel/notes_system/user_service/internal/user/db"
 	"github.com/theartofdevel/notes_system/user_service/pkg/logging"
+	"github.com/theartofdevel/notes_system/user_service/pkg/mail"
+	mailLogger "github.com/theartofdevel/notes_system/user_service/pkg/mail/logger"
+	"github.com/theartofdevel/notes_system/user_service/pkg/mail/smtp"
 	"github.com/theartofdevel/notes_system/user_service/pkg/metric"
 	mongo "github.com/theartofdevel/notes_system/user_service/pkg/mongodb"
 	"github.com/theartofdevel/notes_system/user_service/pkg/shutdown"
@@ -41,7 +46,45 @@ func main() {
 		logger.Fatal(err)
 	}
 	userStorage := db.NewStorage(mongoClient, cfg.MongoDB.Collection, logger)
-	userService, err := user.NewService(userStorage, logger)
+
+	var mailSender mail.Sender
+	if cfg.Mail.Type == "smtp" {
+		mailSender = smtp.NewSender(cfg.Mail.SMTP.Host, cfg.Mail.SMTP.Port, cfg.Mail.SMTP.Username,
+			cfg.Mail.SMTP.Password, cfg.Mail.From)
+	} else {
+		mailSender = mailLogger.NewSender(logger)
+	}
+
+	passwordPolicy := user.PasswordPolicy{
+		MinLength:      cfg.Password.MinLength,
+		RequireUpper:   cfg.Password.RequireUpper,
+		RequireLower:   cfg.Password.RequireLower,
+		RequireDigit:   cfg.Password.RequireDigit,
+		RequireSpecial: cfg.Password.RequireSpecial,
+	}
+	if cfg.Password.CommonPasswordsFile != "" {
+		logger.Println("load common passwords")
+		passwordPolicy.Common, err = user.LoadCommonPasswords(cfg.Password.CommonPasswordsFile)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	tokenStorage, err := tokenDB.NewStorage(mongoClient, cfg.MongoDB.TokensCollection, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	userService, err := user.NewService(userStorage, tokenStorage, mailSender, user.Config{
+		VerifyURL:        cfg.VerifyURL,
+		VerifyTTL:        time.Duration(cfg.VerifyTTL) * time.Minute,
+		ResetPasswordURL: cfg.ResetPassword.URL,
+		ResetPasswordTTL: time.Duration(cfg.ResetPassword.TTL) * time.Minute,
+		PasswordPolicy:   passwordPolicy,
+		BcryptCost:       cfg.Password.BcryptCost,
+		AdminEmails:      cfg.AdminEmails,
+		MFAIssuer:        cfg.MFAIssuer,
+	}, logger)
 	if err != nil {
 		logger.Fatal(err)
 	}
@@ -52,6 +95,12 @@ func main() {
 	}
 	usersHandler.Register(router)
 
+	tokensHandler := token.Handler{
+		Logger:       logger,
+		TokenService: token.NewService(tokenStorage, userService, logger),
+	}
+	tokensHandler.Register(router)
+
 	logger.Println("start application")
 	start(router, logger, cfg)
 }
@@ -7,6 +7,7 @@ require (
 	github.com/google/uuid v1.2.0 // indirect
 	github.com/ilyakaznacheev/cleanenv v1.2.5
 	github.com/julienschmidt/httprouter v1.3.0
+	github.com/pquerna/otp v1.3.0
 	github.com/sirupsen/logrus v1.8.1
 	go.mongodb.org/mongo-driver v1.5.0
 	golang.org/x/crypto v0.0.0-20200302210943-78000ba7a073
@@ -6,7 +6,8 @@ import (
 )
 
 var (
-	ErrNotFound = NewAppError("not found", "NS-000003", "")
+	ErrNotFound           = NewAppError("not found", "NS-000003", "")
+	ErrInvalidCredentials = NewAppError("invalid email or password", "NS-000004", "")
 )
 
 type AppError struct {
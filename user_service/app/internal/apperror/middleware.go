@@ -17,6 +17,10 @@ func Middleware(h appHandler) http.HandlerFunc {
 					w.WriteHeader(http.StatusNotFound)
 					w.Write(ErrNotFound.Marshal())
 					return
+				} else if errors.Is(err, ErrInvalidCredentials) {
+					w.WriteHeader(http.StatusUnauthorized)
+					w.Write(ErrInvalidCredentials.Marshal())
+					return
 				}
 				err := err.(*AppError)
 				w.WriteHeader(http.StatusBadRequest)
@@ -21,7 +21,41 @@ type Config struct {
 		AuthDB     string `yaml:"auth_db" env-required:"true"`
 		Database   string `yaml:"database" env-required:"true"`
 		Collection string `yaml:"collection" env-required:"true"`
+		// TokensCollection keeps personal access tokens
+		TokensCollection string `yaml:"tokens_collection" env-default:"api_tokens"`
 	} `yaml:"mongodb" env-required:"true"`
+	Mail struct {
+		// Type is smtp or log
+		Type string `yaml:"type" env-default:"log"`
+		From string `yaml:"from" env-default:"noreply@notes.system"`
+		SMTP struct {
+			Host     string `yaml:"host" env-default:"localhost"`
+			Port     string `yaml:"port" env-default:"1025"`
+			Username string `yaml:"username"`
+			Password string `yaml:"password"`
+		} `yaml:"smtp"`
+	} `yaml:"mail"`
+	VerifyURL string `yaml:"verify_url" env-required:"true"`
+	// VerifyTTL of verification token in minutes
+	VerifyTTL int `yaml:"verify_ttl" env-default:"1440"`
+
+	ResetPassword struct {
+		URL string `yaml:"url" env-required:"true"`
+		// TTL of reset token in minutes
+		TTL int `yaml:"ttl" env-default:"30"`
+	} `yaml:"reset_password"`
+	MFAIssuer string `yaml:"mfa_issuer" env-default:"Notes System"`
+	// AdminEmails get admin role once they are verified
+	AdminEmails []string `yaml:"admin_emails" env-separator:","`
+	Password    struct {
+		BcryptCost          int    `yaml:"bcrypt_cost" env-default:"12"`
+		MinLength           int    `yaml:"min_length" env-default:"8"`
+		RequireUpper        bool   `yaml:"require_upper" env-default:"true"`
+		RequireLower        bool   `yaml:"require_lower" env-default:"true"`
+		RequireDigit        bool   `yaml:"require_digit" env-default:"true"`
+		RequireSpecial      bool   `yaml:"require_special" env-default:"false"`
+		CommonPasswordsFile string `yaml:"common_passwords_file"`
+	} `yaml:"password"`
 }
 
 var instance *Config
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/theartofdevel/notes_system/user_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/user_service/internal/token"
+	"github.com/theartofdevel/notes_system/user_service/pkg/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+var _ token.Storage = &db{}
+
+type db struct {
+	collection *mongo.Collection
+	logger     logging.Logger
+}
+
+func NewStorage(storage *mongo.Database, collection string, logger logging.Logger) (token.Storage, error) {
+	d := &db{
+		collection: storage.Collection(collection),
+		logger:     logger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := d.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_uuid", Value: 1}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create indexes. error: %w", err)
+	}
+	return d, nil
+}
+
+func (s *db) Create(ctx context.Context, t token.Token) (string, error) {
+	nCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := s.collection.InsertOne(nCtx, t)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute query. error: %w", err)
+	}
+
+	oid, ok := result.InsertedID.(primitive.ObjectID)
+	if ok {
+		return oid.Hex(), nil
+	}
+	return "", fmt.Errorf("failed to convet objectid to hex")
+}
+
+func (s *db) FindByUser(ctx context.Context, userUUID string) (tokens []token.Token, err error) {
+	filter := bson.M{"user_uuid": userUUID}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return tokens, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+
+	tokens = []token.Token{}
+	if err = cursor.All(ctx, &tokens); err != nil {
+		return tokens, fmt.Errorf("failed to decode documents. error: %w", err)
+	}
+
+	return tokens, nil
+}
+
+func (s *db) Use(ctx context.Context, hash string, usedAt time.Time) (t token.Token, err error) {
+	filter := bson.M{"hash": hash}
+	update := bson.M{"$set": bson.M{"last_used_at": usedAt}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result := s.collection.FindOneAndUpdate(ctx, filter, update, opts)
+	if result.Err() != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return t, apperror.ErrNotFound
+		}
+		return t, fmt.Errorf("failed to execute query. error: %w", result.Err())
+	}
+	if err = result.Decode(&t); err != nil {
+		return t, fmt.Errorf("failed to decode document. error: %w", err)
+	}
+
+	return t, nil
+}
+
+func (s *db) Delete(ctx context.Context, id, userUUID string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return apperror.ErrNotFound
+	}
+	filter := bson.M{"_id": objectID, "user_uuid": userUUID}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := s.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return apperror.ErrNotFound
+	}
+
+	s.logger.Tracef("Delete %v documents.\n", result.DeletedCount)
+
+	return nil
+}
+
+func (s *db) DeleteByUser(ctx context.Context, userUUID string) (int, error) {
+	filter := bson.M{"user_uuid": userUUID}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := s.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+
+	s.logger.Tracef("Delete %v documents.\n", result.DeletedCount)
+
+	return int(result.DeletedCount), nil
+}
@@ -0,0 +1,129 @@
+package token
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"github.com/theartofdevel/notes_system/user_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/user_service/pkg/logging"
+	"net/http"
+)
+
+const (
+	tokensURL = "/api/tokens"
+	tokenURL  = "/api/tokens/:id"
+
+	authenticateURL = "/api/tokens/authenticate"
+)
+
+type Handler struct {
+	Logger       logging.Logger
+	TokenService Service
+}
+
+func (h *Handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, tokensURL, apperror.Middleware(h.GetTokens))
+	router.HandlerFunc(http.MethodPost, tokensURL, apperror.Middleware(h.CreateToken))
+	router.HandlerFunc(http.MethodDelete, tokenURL, apperror.Middleware(h.DeleteToken))
+	router.HandlerFunc(http.MethodPost, authenticateURL, apperror.Middleware(h.Authenticate))
+}
+
+func (h *Handler) GetTokens(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("GET TOKENS")
+	w.Header().Set("Content-Type", "application/json")
+
+	userUUID := r.URL.Query().Get("user_uuid")
+	if userUUID == "" {
+		return apperror.BadRequestError("user_uuid query parameter is required")
+	}
+
+	tokens, err := h.TokenService.GetByUser(r.Context(), userUUID)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Debug("marshal tokens")
+	tokensBytes, err := json.Marshal(tokens)
+	if err != nil {
+		return fmt.Errorf("failed to marshall tokens. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(tokensBytes)
+	return nil
+}
+
+func (h *Handler) CreateToken(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("CREATE TOKEN")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("decode create token dto")
+	var dto CreateTokenDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	token, err := h.TokenService.Create(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Debug("marshal token")
+	tokenBytes, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshall token. error: %w", err)
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%s", tokensURL, token.ID))
+	w.WriteHeader(http.StatusCreated)
+	w.Write(tokenBytes)
+	return nil
+}
+
+func (h *Handler) DeleteToken(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("DELETE TOKEN")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	id := params.ByName("id")
+	userUUID := r.URL.Query().Get("user_uuid")
+	if userUUID == "" {
+		return apperror.BadRequestError("user_uuid query parameter is required")
+	}
+
+	err := h.TokenService.Delete(r.Context(), id, userUUID)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) Authenticate(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("AUTHENTICATE TOKEN")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("decode authenticate token dto")
+	var dto AuthenticateTokenDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	token, err := h.TokenService.Authenticate(r.Context(), dto.Token)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Debug("marshal token")
+	tokenBytes, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshall token. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(tokenBytes)
+	return nil
+}
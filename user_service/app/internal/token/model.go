@@ -0,0 +1,90 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Prefix marks personal access tokens so they are told apart from JWTs
+const Prefix = "nst_"
+
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+// resources tokens can be scoped to, a scope is "<resource>:<access>"
+var resources = map[string]bool{"notes": true, "categories": true, "tags": true, "files": true}
+
+type Token struct {
+	ID       string `json:"id" bson:"_id,omitempty"`
+	UserUUID string `json:"user_uuid" bson:"user_uuid"`
+	Name     string `json:"name" bson:"name"`
+	// Hint is a beginning of the token to recognize it in a list
+	Hint       string     `json:"hint" bson:"hint"`
+	Hash       string     `json:"-" bson:"hash"`
+	Scopes     []string   `json:"scopes" bson:"scopes"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at" bson:"last_used_at,omitempty"`
+	// Roles of the token owner, filled on authentication only
+	Roles []string `json:"roles,omitempty" bson:"-"`
+}
+
+// CreatedToken is returned once on creation, only the hash of the token is stored
+type CreatedToken struct {
+	Token
+	Secret string `json:"token"`
+}
+
+type CreateTokenDTO struct {
+	UserUUID string   `json:"user_uuid"`
+	Name     string   `json:"name"`
+	Scopes   []string `json:"scopes"`
+}
+
+type AuthenticateTokenDTO struct {
+	Token string `json:"token"`
+}
+
+func NewToken(dto CreateTokenDTO) (CreatedToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return CreatedToken{}, fmt.Errorf("failed to generate token due to error %w", err)
+	}
+	secret := Prefix + base64.RawURLEncoding.EncodeToString(b)
+
+	return CreatedToken{
+		Token: Token{
+			UserUUID:  dto.UserUUID,
+			Name:      dto.Name,
+			Hint:      secret[:len(Prefix)+6],
+			Hash:      hashToken(secret),
+			Scopes:    dto.Scopes,
+			CreatedAt: time.Now().UTC(),
+		},
+		Secret: secret,
+	}, nil
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		parts := strings.Split(scope, ":")
+		if len(parts) != 2 || !resources[parts[0]] || (parts[1] != AccessRead && parts[1] != AccessWrite) {
+			return fmt.Errorf("invalid scope %q, expected <resource>:read or <resource>:write", scope)
+		}
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,38 @@
+package token
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateScopes(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		valid  bool
+	}{
+		{[]string{"notes:read"}, true},
+		{[]string{"notes:write", "tags:read", "categories:write"}, true},
+		{nil, false},
+		{[]string{"notes"}, false},
+		{[]string{"notes:delete"}, false},
+		{[]string{"users:read"}, false},
+	}
+	for _, tt := range tests {
+		if err := validateScopes(tt.scopes); (err == nil) != tt.valid {
+			t.Errorf("scopes %v: expected valid %v, got error %v", tt.scopes, tt.valid, err)
+		}
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	created, err := NewToken(CreateTokenDTO{UserUUID: "user", Name: "backup script", Scopes: []string{"notes:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Secret, Prefix) || !strings.HasPrefix(created.Secret, created.Hint) {
+		t.Errorf("unexpected token %s with hint %s", created.Secret, created.Hint)
+	}
+	if created.Hash != hashToken(created.Secret) {
+		t.Error("hash does not match the token")
+	}
+}
@@ -0,0 +1,106 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/theartofdevel/notes_system/user_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/user_service/internal/user"
+	"github.com/theartofdevel/notes_system/user_service/pkg/logging"
+	"strings"
+	"time"
+)
+
+var _ Service = &service{}
+
+type service struct {
+	storage     Storage
+	userService user.Service
+	logger      logging.Logger
+}
+
+func NewService(tokenStorage Storage, userService user.Service, logger logging.Logger) Service {
+	return &service{
+		storage:     tokenStorage,
+		userService: userService,
+		logger:      logger,
+	}
+}
+
+type Service interface {
+	Create(ctx context.Context, dto CreateTokenDTO) (CreatedToken, error)
+	GetByUser(ctx context.Context, userUUID string) ([]Token, error)
+	Authenticate(ctx context.Context, token string) (Token, error)
+	Delete(ctx context.Context, id, userUUID string) error
+}
+
+func (s service) Create(ctx context.Context, dto CreateTokenDTO) (t CreatedToken, err error) {
+	dto.Name = strings.TrimSpace(dto.Name)
+	if dto.Name == "" {
+		return t, apperror.BadRequestError("token name is required")
+	}
+	s.logger.Debug("validate scopes")
+	if err = validateScopes(dto.Scopes); err != nil {
+		return t, apperror.BadRequestError(err.Error())
+	}
+
+	s.logger.Debug("check user exists")
+	if _, err = s.userService.GetOne(ctx, dto.UserUUID); err != nil {
+		return t, err
+	}
+
+	t, err = NewToken(dto)
+	if err != nil {
+		return t, err
+	}
+	t.ID, err = s.storage.Create(ctx, t.Token)
+	if err != nil {
+		return t, fmt.Errorf("failed to create token. error: %w", err)
+	}
+	return t, nil
+}
+
+func (s service) GetByUser(ctx context.Context, userUUID string) ([]Token, error) {
+	tokens, err := s.storage.FindByUser(ctx, userUUID)
+	if err != nil {
+		return tokens, fmt.Errorf("failed to find tokens. error: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s service) Authenticate(ctx context.Context, token string) (t Token, err error) {
+	if !strings.HasPrefix(token, Prefix) {
+		return t, apperror.ErrInvalidCredentials
+	}
+
+	t, err = s.storage.Use(ctx, hashToken(token), time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return t, apperror.ErrInvalidCredentials
+		}
+		return t, fmt.Errorf("failed to find token. error: %w", err)
+	}
+
+	s.logger.Debug("get token owner roles")
+	u, err := s.userService.GetOne(ctx, t.UserUUID)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return t, apperror.ErrInvalidCredentials
+		}
+		return t, err
+	}
+	t.Roles = u.Roles
+
+	return t, nil
+}
+
+func (s service) Delete(ctx context.Context, id, userUUID string) error {
+	err := s.storage.Delete(ctx, id, userUUID)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to delete token. error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package token
+
+import (
+	"context"
+	"time"
+)
+
+type Storage interface {
+	Create(ctx context.Context, token Token) (string, error)
+	FindByUser(ctx context.Context, userUUID string) ([]Token, error)
+	// Use finds the token by hash and sets its last used time
+	Use(ctx context.Context, hash string, usedAt time.Time) (Token, error)
+	Delete(ctx context.Context, id, userUUID string) error
+	// DeleteByUser deletes all tokens of the user and returns their count
+	DeleteByUser(ctx context.Context, userUUID string) (int, error)
+}
@@ -10,6 +10,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"time"
 )
 
@@ -67,6 +68,24 @@ func (s *db) FindOne(ctx context.Context, uuid string) (u user.User, err error)
 	return u, nil
 }
 
+func (s *db) FindAll(ctx context.Context) (users []user.User, err error) {
+	opts := options.Find().SetSort(bson.D{{Key: "email", Value: 1}})
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cursor, err := s.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return users, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+
+	users = []user.User{}
+	if err = cursor.All(ctx, &users); err != nil {
+		return users, fmt.Errorf("failed to decode documents. error: %w", err)
+	}
+
+	return users, nil
+}
+
 func (s *db) FindByEmail(ctx context.Context, email string) (u user.User, err error) {
 	filter := bson.M{"email": email}
 
@@ -88,6 +107,285 @@ func (s *db) FindByEmail(ctx context.Context, email string) (u user.User, err er
 	return u, nil
 }
 
+func (s *db) FindByIdentity(ctx context.Context, issuer, subject string) (u user.User, err error) {
+	filter := bson.M{"identities": bson.M{"$elemMatch": bson.M{"issuer": issuer, "subject": subject}}}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result := s.collection.FindOne(ctx, filter)
+	err = result.Err()
+	if err != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return u, apperror.ErrNotFound
+		}
+		return u, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if err = result.Decode(&u); err != nil {
+		return u, fmt.Errorf("failed to decode document. error: %w", err)
+	}
+
+	return u, nil
+}
+
+func (s *db) LinkIdentity(ctx context.Context, uuid string, identity user.Identity, takeOver bool) error {
+	set := bson.M{"email_verified": true}
+	unset := bson.M{"verification_token": "", "verification_expires_at": ""}
+	if takeOver {
+		set["roles"] = []string{user.RoleUser}
+		for _, field := range []string{"password", "password_reset_token", "password_reset_expires_at",
+			"mfa_enabled", "mfa_secret", "mfa_pending_secret", "mfa_last_step", "mfa_backup_codes"} {
+			unset[field] = ""
+		}
+	}
+	update := bson.M{
+		"$set":      set,
+		"$unset":    unset,
+		"$addToSet": bson.M{"identities": identity},
+	}
+	return s.updateOne(ctx, uuid, nil, update)
+}
+
+func (s *db) FindByVerificationToken(ctx context.Context, tokenHash string) (u user.User, err error) {
+	filter := bson.M{
+		"verification_token":      tokenHash,
+		"verification_expires_at": bson.M{"$gt": time.Now().UTC()},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result := s.collection.FindOne(ctx, filter)
+	err = result.Err()
+	if err != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return u, apperror.ErrNotFound
+		}
+		return u, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if err = result.Decode(&u); err != nil {
+		return u, fmt.Errorf("failed to decode document. error: %w", err)
+	}
+
+	return u, nil
+}
+
+func (s *db) SetEmailVerified(ctx context.Context, uuid string) error {
+	objectID, err := primitive.ObjectIDFromHex(uuid)
+	if err != nil {
+		return fmt.Errorf("failed to convert hex to objectid. error: %w", err)
+	}
+
+	filter := bson.M{"_id": objectID}
+	update := bson.M{
+		"$set":   bson.M{"email_verified": true},
+		"$unset": bson.M{"verification_token": "", "verification_expires_at": ""},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := s.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *db) SetVerificationToken(ctx context.Context, uuid, tokenHash string, expiresAt time.Time) error {
+	objectID, err := primitive.ObjectIDFromHex(uuid)
+	if err != nil {
+		return fmt.Errorf("failed to convert hex to objectid. error: %w", err)
+	}
+
+	filter := bson.M{"_id": objectID}
+	update := bson.M{
+		"$set": bson.M{
+			"verification_token":      tokenHash,
+			"verification_expires_at": expiresAt,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := s.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *db) SetPasswordResetToken(ctx context.Context, uuid, tokenHash string, expiresAt time.Time) error {
+	objectID, err := primitive.ObjectIDFromHex(uuid)
+	if err != nil {
+		return fmt.Errorf("failed to convert hex to objectid. error: %w", err)
+	}
+
+	filter := bson.M{"_id": objectID}
+	update := bson.M{
+		"$set": bson.M{
+			"password_reset_token":      tokenHash,
+			"password_reset_expires_at": expiresAt,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := s.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *db) FindByPasswordResetToken(ctx context.Context, tokenHash string) (u user.User, err error) {
+	filter := bson.M{
+		"password_reset_token":      tokenHash,
+		"password_reset_expires_at": bson.M{"$gt": time.Now().UTC()},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result := s.collection.FindOne(ctx, filter)
+	err = result.Err()
+	if err != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return u, apperror.ErrNotFound
+		}
+		return u, fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if err = result.Decode(&u); err != nil {
+		return u, fmt.Errorf("failed to decode document. error: %w", err)
+	}
+
+	return u, nil
+}
+
+func (s *db) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (u user.User, err error) {
+	filter := bson.M{
+		"password_reset_token":      tokenHash,
+		"password_reset_expires_at": bson.M{"$gt": time.Now().UTC()},
+	}
+	update := bson.M{
+		"$set":   bson.M{"password": passwordHash},
+		"$unset": bson.M{"password_reset_token": "", "password_reset_expires_at": ""},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result := s.collection.FindOneAndUpdate(ctx, filter, update, opts)
+	if result.Err() != nil {
+		if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+			return u, apperror.ErrNotFound
+		}
+		return u, fmt.Errorf("failed to execute query. error: %w", result.Err())
+	}
+	if err = result.Decode(&u); err != nil {
+		return u, fmt.Errorf("failed to decode document. error: %w", err)
+	}
+
+	return u, nil
+}
+
+func (s *db) SetRoles(ctx context.Context, uuid string, roles []string) error {
+	objectID, err := primitive.ObjectIDFromHex(uuid)
+	if err != nil {
+		return fmt.Errorf("failed to convert hex to objectid. error: %w", err)
+	}
+
+	filter := bson.M{"_id": objectID}
+	update := bson.M{"$set": bson.M{"roles": roles}}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := s.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *db) SetMFAPendingSecret(ctx context.Context, uuid, secret string) error {
+	return s.updateOne(ctx, uuid, nil, bson.M{"$set": bson.M{"mfa_pending_secret": secret}})
+}
+
+func (s *db) EnableMFA(ctx context.Context, uuid, secret string, backupCodeHashes []string, step int64) error {
+	update := bson.M{
+		"$set": bson.M{
+			"mfa_enabled":      true,
+			"mfa_secret":       secret,
+			"mfa_backup_codes": backupCodeHashes,
+			"mfa_last_step":    step,
+		},
+		"$unset": bson.M{"mfa_pending_secret": ""},
+	}
+	return s.updateOne(ctx, uuid, nil, update)
+}
+
+func (s *db) SetMFALastStep(ctx context.Context, uuid string, step int64) error {
+	filter := bson.M{"mfa_last_step": bson.M{"$lt": step}}
+	return s.updateOne(ctx, uuid, filter, bson.M{"$set": bson.M{"mfa_last_step": step}})
+}
+
+func (s *db) UseMFABackupCode(ctx context.Context, uuid, codeHash string) error {
+	filter := bson.M{"mfa_backup_codes": codeHash}
+	return s.updateOne(ctx, uuid, filter, bson.M{"$pull": bson.M{"mfa_backup_codes": codeHash}})
+}
+
+func (s *db) DisableMFA(ctx context.Context, uuid string) error {
+	update := bson.M{
+		"$set": bson.M{"mfa_enabled": false},
+		"$unset": bson.M{
+			"mfa_secret":         "",
+			"mfa_pending_secret": "",
+			"mfa_backup_codes":   "",
+			"mfa_last_step":      "",
+		},
+	}
+	return s.updateOne(ctx, uuid, nil, update)
+}
+
+// updateOne applies update to the user matching filter, ErrNotFound is returned if nothing matched
+func (s *db) updateOne(ctx context.Context, uuid string, filter bson.M, update bson.M) error {
+	objectID, err := primitive.ObjectIDFromHex(uuid)
+	if err != nil {
+		return fmt.Errorf("failed to convert hex to objectid. error: %w", err)
+	}
+	if filter == nil {
+		filter = bson.M{}
+	}
+	filter["_id"] = objectID
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	result, err := s.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to execute query. error: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
 func (s *db) Update(ctx context.Context, user user.User) error {
 	objectID, err := primitive.ObjectIDFromHex(user.UUID)
 	if err != nil {
@@ -127,6 +425,45 @@ func (s *db) Update(ctx context.Context, user user.User) error {
 	return nil
 }
 
+func (s *db) UpdateProfile(ctx context.Context, uuid string, dto user.UpdateProfileDTO) error {
+	set, unset := bson.M{}, bson.M{}
+	setOrUnset(set, unset, "display_name", dto.DisplayName)
+	setOrUnset(set, unset, "avatar_file_id", dto.AvatarFileID)
+	setOrUnset(set, unset, "locale", dto.Locale)
+	setOrUnset(set, unset, "timezone", dto.Timezone)
+	return s.updateOne(ctx, uuid, nil, setAndUnset(set, unset))
+}
+
+func (s *db) UpdatePreferences(ctx context.Context, uuid string, dto user.UpdatePreferencesDTO) error {
+	set, unset := bson.M{}, bson.M{}
+	setOrUnset(set, unset, "preferences.default_category_uuid", dto.DefaultCategoryUUID)
+	setOrUnset(set, unset, "preferences.note_sort_order", dto.NoteSortOrder)
+	return s.updateOne(ctx, uuid, nil, setAndUnset(set, unset))
+}
+
+// setOrUnset skips nil values, empty strings are removed from the document
+func setOrUnset(set, unset bson.M, field string, value *string) {
+	if value == nil {
+		return
+	}
+	if *value == "" {
+		unset[field] = ""
+		return
+	}
+	set[field] = *value
+}
+
+func setAndUnset(set, unset bson.M) bson.M {
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
+
 func (s *db) Delete(ctx context.Context, uuid string) error {
 	objectID, err := primitive.ObjectIDFromHex(uuid)
 	if err != nil {
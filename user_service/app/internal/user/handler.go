@@ -12,6 +12,22 @@ import (
 const (
 	usersURL = "/api/users"
 	userURL  = "/api/users/:uuid"
+	rolesURL = "/api/users/:uuid/roles"
+
+	profileURL     = "/api/users/:uuid/profile"
+	preferencesURL = "/api/users/:uuid/preferences"
+
+	authenticateURL   = "/api/users/authenticate"
+	verifyEmailURL    = "/api/users/verify"
+	resendVerifyURL   = "/api/users/verify/resend"
+	forgotPasswordURL = "/api/users/password/forgot"
+	resetPasswordURL  = "/api/users/password/reset"
+	identityLoginURL  = "/api/users/oidc"
+
+	mfaEnrollURL  = "/api/users/mfa/enroll"
+	mfaConfirmURL = "/api/users/mfa/confirm"
+	mfaVerifyURL  = "/api/users/mfa/verify"
+	mfaDisableURL = "/api/users/mfa/disable"
 )
 
 type Handler struct {
@@ -20,11 +36,24 @@ type Handler struct {
 }
 
 func (h *Handler) Register(router *httprouter.Router) {
-	router.HandlerFunc(http.MethodGet, usersURL, apperror.Middleware(h.GetUserByEmailAndPassword))
+	router.HandlerFunc(http.MethodGet, usersURL, apperror.Middleware(h.GetUsers))
 	router.HandlerFunc(http.MethodPost, usersURL, apperror.Middleware(h.CreateUser))
 	router.HandlerFunc(http.MethodGet, userURL, apperror.Middleware(h.GetUser))
 	router.HandlerFunc(http.MethodPatch, userURL, apperror.Middleware(h.PartiallyUpdateUser))
 	router.HandlerFunc(http.MethodDelete, userURL, apperror.Middleware(h.DeleteUser))
+	router.HandlerFunc(http.MethodPut, rolesURL, apperror.Middleware(h.SetRoles))
+	router.HandlerFunc(http.MethodPatch, profileURL, apperror.Middleware(h.UpdateProfile))
+	router.HandlerFunc(http.MethodPatch, preferencesURL, apperror.Middleware(h.UpdatePreferences))
+	router.HandlerFunc(http.MethodPost, authenticateURL, apperror.Middleware(h.Authenticate))
+	router.HandlerFunc(http.MethodPost, identityLoginURL, apperror.Middleware(h.LoginWithIdentity))
+	router.HandlerFunc(http.MethodPost, verifyEmailURL, apperror.Middleware(h.VerifyEmail))
+	router.HandlerFunc(http.MethodPost, resendVerifyURL, apperror.Middleware(h.ResendVerification))
+	router.HandlerFunc(http.MethodPost, forgotPasswordURL, apperror.Middleware(h.ForgotPassword))
+	router.HandlerFunc(http.MethodPost, resetPasswordURL, apperror.Middleware(h.ResetPassword))
+	router.HandlerFunc(http.MethodPost, mfaEnrollURL, apperror.Middleware(h.EnrollMFA))
+	router.HandlerFunc(http.MethodPost, mfaConfirmURL, apperror.Middleware(h.ConfirmMFA))
+	router.HandlerFunc(http.MethodPost, mfaVerifyURL, apperror.Middleware(h.VerifyMFA))
+	router.HandlerFunc(http.MethodPost, mfaDisableURL, apperror.Middleware(h.DisableMFA))
 }
 
 func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) error {
@@ -51,18 +80,107 @@ func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func (h *Handler) GetUserByEmailAndPassword(w http.ResponseWriter, r *http.Request) error {
-	h.Logger.Info("GET USER BY EMAIL AND PASSWORD")
+func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("GET USERS")
+	w.Header().Set("Content-Type", "application/json")
+
+	users, err := h.UserService.GetAll(r.Context())
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Debug("marshal users")
+	usersBytes, err := json.Marshal(users)
+	if err != nil {
+		return fmt.Errorf("failed to marshall users. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(usersBytes)
+	return nil
+}
+
+func (h *Handler) SetRoles(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("SET USER ROLES")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	userUUID := params.ByName("uuid")
+
+	h.Logger.Debug("decode set roles dto")
+	var dto SetRolesDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	err := h.UserService.SetRoles(r.Context(), userUUID, dto.Roles)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("UPDATE USER PROFILE")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	userUUID := params.ByName("uuid")
+
+	h.Logger.Debug("decode update profile dto")
+	var dto UpdateProfileDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	err := h.UserService.UpdateProfile(r.Context(), userUUID, dto)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) UpdatePreferences(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("UPDATE USER PREFERENCES")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	userUUID := params.ByName("uuid")
+
+	h.Logger.Debug("decode update preferences dto")
+	var dto UpdatePreferencesDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	err := h.UserService.UpdatePreferences(r.Context(), userUUID, dto)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) Authenticate(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("AUTHENTICATE USER")
 	w.Header().Set("Content-Type", "application/json")
 
-	h.Logger.Debug("get email and password from URL")
-	email := r.URL.Query().Get("email")
-	password := r.URL.Query().Get("password")
-	if email == "" || password == "" {
-		return apperror.BadRequestError("invalid query parameters email or password")
+	h.Logger.Debug("decode authenticate user dto")
+	var dto AuthenticateUserDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
 	}
 
-	user, err := h.UserService.GetByEmailAndPassword(r.Context(), email, password)
+	user, err := h.UserService.GetByEmailAndPassword(r.Context(), dto.Email, dto.Password)
 	if err != nil {
 		return err
 	}
@@ -79,6 +197,34 @@ func (h *Handler) GetUserByEmailAndPassword(w http.ResponseWriter, r *http.Reque
 	return nil
 }
 
+func (h *Handler) LoginWithIdentity(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("LOGIN USER WITH EXTERNAL IDENTITY")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("decode external login dto")
+	var dto ExternalLoginDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	login, err := h.UserService.LoginWithIdentity(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Debug("marshal user")
+	userBytes, err := json.Marshal(login)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(userBytes)
+
+	return nil
+}
+
 func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) error {
 	h.Logger.Info("CREATE USER")
 	w.Header().Set("Content-Type", "application/json")
@@ -140,3 +286,187 @@ func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("VERIFY EMAIL")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("decode verify email dto")
+	var dto VerifyEmailDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	err := h.UserService.VerifyEmail(r.Context(), dto.Token)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("RESEND VERIFICATION")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("decode resend verification dto")
+	var dto ResendVerificationDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	err := h.UserService.ResendVerification(r.Context(), dto.Email)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	return nil
+}
+
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("FORGOT PASSWORD")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("decode forgot password dto")
+	var dto ForgotPasswordDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	err := h.UserService.ForgotPassword(r.Context(), dto.Email)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusAccepted)
+
+	return nil
+}
+
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("RESET PASSWORD")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("decode reset password dto")
+	var dto ResetPasswordDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	user, err := h.UserService.ResetPassword(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Debug("marshal user")
+	userBytes, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("failed to marshall user. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(userBytes)
+
+	return nil
+}
+
+func (h *Handler) EnrollMFA(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("ENROLL MFA")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("decode mfa dto")
+	var dto MFACodeDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	enrollment, err := h.UserService.EnrollMFA(r.Context(), dto.UUID)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Debug("marshal enrollment")
+	enrollmentBytes, err := json.Marshal(enrollment)
+	if err != nil {
+		return fmt.Errorf("failed to marshall enrollment. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(enrollmentBytes)
+
+	return nil
+}
+
+func (h *Handler) ConfirmMFA(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("CONFIRM MFA")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("decode mfa dto")
+	var dto MFACodeDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	codes, err := h.UserService.ConfirmMFA(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+
+	h.Logger.Debug("marshal backup codes")
+	codesBytes, err := json.Marshal(codes)
+	if err != nil {
+		return fmt.Errorf("failed to marshall backup codes. error: %w", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(codesBytes)
+
+	return nil
+}
+
+func (h *Handler) VerifyMFA(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("VERIFY MFA")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("decode mfa dto")
+	var dto MFACodeDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	err := h.UserService.VerifyMFA(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *Handler) DisableMFA(w http.ResponseWriter, r *http.Request) error {
+	h.Logger.Info("DISABLE MFA")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.Logger.Debug("decode mfa dto")
+	var dto MFACodeDTO
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return apperror.BadRequestError("invalid JSON scheme. check swagger API")
+	}
+
+	err := h.UserService.DisableMFA(r.Context(), dto)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,102 @@
+package user
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"image/png"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod = 30
+	// totpSkew is how many periods before and after the current one are accepted to tolerate clock drift
+	totpSkew         = 1
+	qrCodeSize       = 256
+	backupCodesCount = 10
+)
+
+// MFAEnrollment is shown to the user once to add the secret to an authenticator app
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	// QRCodePNG is base64 encoded in JSON
+	QRCodePNG []byte `json:"qr_code_png"`
+}
+
+type MFABackupCodes struct {
+	Codes []string `json:"backup_codes"`
+}
+
+func generateMFAEnrollment(issuer, email string) (e MFAEnrollment, err error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: email,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return e, fmt.Errorf("failed to generate totp secret due to error %w", err)
+	}
+
+	img, err := key.Image(qrCodeSize, qrCodeSize)
+	if err != nil {
+		return e, fmt.Errorf("failed to generate qr code due to error %w", err)
+	}
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, img); err != nil {
+		return e, fmt.Errorf("failed to encode qr code due to error %w", err)
+	}
+
+	return MFAEnrollment{
+		Secret:     key.Secret(),
+		OTPAuthURI: key.URL(),
+		QRCodePNG:  buf.Bytes(),
+	}, nil
+}
+
+// validateTOTP returns the time step the code belongs to, steps not greater than lastStep are rejected
+// so a code can't be used twice
+func validateTOTP(secret, code string, lastStep int64, now time.Time) (int64, bool) {
+	opts := totp.ValidateOpts{
+		Period:    totpPeriod,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	}
+	for i := -totpSkew; i <= totpSkew; i++ {
+		t := now.Add(time.Duration(i*totpPeriod) * time.Second)
+		step := t.Unix() / totpPeriod
+		if step <= lastStep {
+			continue
+		}
+		if ok, err := totp.ValidateCustom(code, secret, t, opts); err == nil && ok {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// generateBackupCodes returns codes to show to the user and their hashes to store
+func generateBackupCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < backupCodesCount; i++ {
+		b := make([]byte, 5)
+		if _, err = rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate backup code due to error %w", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, hashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashBackupCode hashes a code entered by the user, dashes and case are ignored
+func hashBackupCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashToken(code)
+}
@@ -0,0 +1,57 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+// Test scenario:
+// 1. Generate a code for the current time
+// 2. The code is valid and belongs to the current step
+// 3. The same code is rejected once its step is saved as the last used one
+// 4. A code from a far past is rejected
+func TestValidateTOTP(t *testing.T) {
+	e, err := generateMFAEnrollment("Notes System", "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.QRCodePNG) == 0 {
+		t.Error("qr code is empty")
+	}
+
+	now := time.Now()
+	code, err := totp.GenerateCode(e.Secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step, ok := validateTOTP(e.Secret, code, 0, now)
+	if !ok || step != now.Unix()/totpPeriod {
+		t.Fatalf("expected code to be valid for step %d, got %d %v", now.Unix()/totpPeriod, step, ok)
+	}
+	if _, ok = validateTOTP(e.Secret, code, step, now); ok {
+		t.Error("code must not be accepted twice")
+	}
+
+	oldCode, _ := totp.GenerateCode(e.Secret, now.Add(-10*time.Minute))
+	if _, ok = validateTOTP(e.Secret, oldCode, 0, now); ok {
+		t.Error("outdated code must not be accepted")
+	}
+}
+
+func TestBackupCodes(t *testing.T) {
+	codes, hashes, err := generateBackupCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != backupCodesCount || len(hashes) != backupCodesCount {
+		t.Fatalf("expected %d codes, got %d", backupCodesCount, len(codes))
+	}
+	for i, code := range codes {
+		if hashBackupCode(code) != hashes[i] {
+			t.Errorf("hash of code %s does not match", code)
+		}
+	}
+}
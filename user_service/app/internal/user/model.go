@@ -1,14 +1,44 @@
 package user
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
+	"time"
 )
 
+const (
+	RoleUser     = "user"
+	RoleAdmin    = "admin"
+	RoleReadOnly = "read-only"
+)
+
+var roles = map[string]bool{RoleUser: true, RoleAdmin: true, RoleReadOnly: true}
+
 type User struct {
-	UUID     string `json:"uuid" bson:"_id,omitempty"`
-	Email    string `json:"email" bson:"email,omitempty"`
-	Password string `json:"-" bson:"password,omitempty"`
+	UUID                   string       `json:"uuid" bson:"_id,omitempty"`
+	Email                  string       `json:"email" bson:"email,omitempty"`
+	Password               string       `json:"-" bson:"password,omitempty"`
+	EmailVerified          bool         `json:"email_verified" bson:"email_verified,omitempty"`
+	Roles                  []string     `json:"roles" bson:"roles,omitempty"`
+	DisplayName            string       `json:"display_name,omitempty" bson:"display_name,omitempty"`
+	AvatarFileID           string       `json:"avatar_file_id,omitempty" bson:"avatar_file_id,omitempty"`
+	Locale                 string       `json:"locale,omitempty" bson:"locale,omitempty"`
+	Timezone               string       `json:"timezone,omitempty" bson:"timezone,omitempty"`
+	Preferences            *Preferences `json:"preferences" bson:"preferences,omitempty"`
+	MFAEnabled             bool         `json:"mfa_enabled" bson:"mfa_enabled,omitempty"`
+	MFASecret              string       `json:"-" bson:"mfa_secret,omitempty"`
+	MFAPendingSecret       string       `json:"-" bson:"mfa_pending_secret,omitempty"`
+	MFALastStep            int64        `json:"-" bson:"mfa_last_step,omitempty"`
+	MFABackupCodes         []string     `json:"-" bson:"mfa_backup_codes,omitempty"`
+	Identities             []Identity   `json:"-" bson:"identities,omitempty"`
+	VerificationToken      string       `json:"-" bson:"verification_token,omitempty"`
+	VerificationExpiresAt  time.Time    `json:"-" bson:"verification_expires_at,omitempty"`
+	PasswordResetToken     string       `json:"-" bson:"password_reset_token,omitempty"`
+	PasswordResetExpiresAt time.Time    `json:"-" bson:"password_reset_expires_at,omitempty"`
 }
 
 func (u *User) CheckPassword(password string) error {
@@ -19,8 +49,8 @@ func (u *User) CheckPassword(password string) error {
 	return nil
 }
 
-func (u *User) GeneratePasswordHash() error {
-	pwd, err := generatePasswordHash(u.Password)
+func (u *User) GeneratePasswordHash(cost int) error {
+	pwd, err := generatePasswordHash(u.Password, cost)
 	if err != nil {
 		return err
 	}
@@ -28,6 +58,26 @@ func (u *User) GeneratePasswordHash() error {
 	return nil
 }
 
+// NeedsRehash reports whether the password hash was generated with a cost lower than the given one
+func (u *User) NeedsRehash(cost int) bool {
+	hashCost, err := bcrypt.Cost([]byte(u.Password))
+	if err != nil {
+		return false
+	}
+	return hashCost < cost
+}
+
+// GenerateVerificationToken sets hash of a new token valid for ttl to the user and returns the token itself
+func (u *User) GenerateVerificationToken(ttl time.Duration) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+	u.VerificationToken = hashToken(token)
+	u.VerificationExpiresAt = time.Now().UTC().Add(ttl)
+	return token, nil
+}
+
 type CreateUserDTO struct {
 	Email          string `json:"email" bson:"email"`
 	Password       string `json:"password" bson:"password"`
@@ -42,10 +92,64 @@ type UpdateUserDTO struct {
 	NewPassword string `json:"new_password,omitempty" bson:"-"`
 }
 
+type SetRolesDTO struct {
+	Roles []string `json:"roles"`
+}
+
+type MFACodeDTO struct {
+	UUID string `json:"uuid"`
+	Code string `json:"code"`
+}
+
+// Identity is an account of the user at an external OpenID Connect provider
+type Identity struct {
+	Issuer  string `json:"issuer" bson:"issuer"`
+	Subject string `json:"subject" bson:"subject"`
+}
+
+// ExternalLoginDTO carries claims of an ID token verified by api_service
+type ExternalLoginDTO struct {
+	Issuer        string `json:"issuer"`
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// IdentityLogin is the user of an external identity. TakenOver is set when an unverified account with
+// the same email was reset and linked to the identity, sessions of the account must be revoked then.
+type IdentityLogin struct {
+	User
+	TakenOver bool `json:"taken_over,omitempty"`
+}
+
+type VerifyEmailDTO struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationDTO struct {
+	Email string `json:"email"`
+}
+
+type AuthenticateUserDTO struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type ForgotPasswordDTO struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordDTO struct {
+	Token          string `json:"token"`
+	Password       string `json:"password"`
+	RepeatPassword string `json:"repeat_password"`
+}
+
 func NewUser(dto CreateUserDTO) User {
 	return User{
 		Email:    dto.Email,
 		Password: dto.Password,
+		Roles:    []string{RoleUser},
 	}
 }
 
@@ -57,10 +161,51 @@ func UpdatedUser(dto UpdateUserDTO) User {
 	}
 }
 
-func generatePasswordHash(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+// setDefaults fills fields of users saved before they were introduced
+func setDefaults(u *User) {
+	setDefaultRoles(u)
+	setDefaultPreferences(u)
+}
+
+// setDefaultRoles gives plain user role to users created before roles were introduced
+func setDefaultRoles(u *User) {
+	if len(u.Roles) == 0 {
+		u.Roles = []string{RoleUser}
+	}
+}
+
+func validateRoles(userRoles []string) error {
+	if len(userRoles) == 0 {
+		return fmt.Errorf("at least one role is required")
+	}
+	for _, r := range userRoles {
+		if !roles[r] {
+			return fmt.Errorf("unknown role %q", r)
+		}
+		if r == RoleReadOnly && len(userRoles) > 1 {
+			return fmt.Errorf("%s role can not be combined with other roles", RoleReadOnly)
+		}
+	}
+	return nil
+}
+
+func generatePasswordHash(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password due to error %w", err)
 	}
 	return string(hash), nil
 }
+
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token due to error %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
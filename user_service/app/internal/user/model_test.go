@@ -0,0 +1,32 @@
+package user
+
+import (
+	"testing"
+	"time"
+)
+
+// Test scenario:
+// 1. Only hash of the verification token is kept by the user
+// 2. The token expires after ttl, a new token replaces the old one
+func TestGenerateVerificationToken(t *testing.T) {
+	u := User{}
+	before := time.Now().UTC()
+	token, err := u.GenerateVerificationToken(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.VerificationToken == token || u.VerificationToken != hashToken(token) {
+		t.Errorf("user keeps %q instead of hash of the token", u.VerificationToken)
+	}
+	if u.VerificationExpiresAt.Before(before.Add(time.Hour)) || u.VerificationExpiresAt.After(time.Now().UTC().Add(time.Hour)) {
+		t.Errorf("token expires at %s, want in an hour", u.VerificationExpiresAt)
+	}
+
+	next, err := u.GenerateVerificationToken(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == token || u.VerificationToken != hashToken(next) {
+		t.Error("new token does not replace the old one")
+	}
+}
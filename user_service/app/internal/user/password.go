@@ -0,0 +1,96 @@
+package user
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/theartofdevel/notes_system/user_service/internal/apperror"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type PasswordPolicy struct {
+	MinLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	// Common passwords are rejected, keys are lower case
+	Common map[string]struct{}
+}
+
+// minEmailPartLength is the shortest local part of email which is not allowed in passwords,
+// shorter ones are found in too many passwords by chance
+const minEmailPartLength = 3
+
+// Validate returns BadRequest app error describing the first violated rule, email of the user may be empty
+func (p PasswordPolicy) Validate(password, email string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return apperror.BadRequestError(fmt.Sprintf("password must be at least %d characters long", p.MinLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSpecial = true
+		}
+	}
+	switch {
+	case p.RequireUpper && !hasUpper:
+		return apperror.BadRequestError("password must contain an upper case letter")
+	case p.RequireLower && !hasLower:
+		return apperror.BadRequestError("password must contain a lower case letter")
+	case p.RequireDigit && !hasDigit:
+		return apperror.BadRequestError("password must contain a digit")
+	case p.RequireSpecial && !hasSpecial:
+		return apperror.BadRequestError("password must contain a special character")
+	}
+
+	lower := strings.ToLower(password)
+	if _, ok := p.Common[lower]; ok {
+		return apperror.BadRequestError("password is too common")
+	}
+	if local := emailLocalPart(email); utf8.RuneCountInString(local) >= minEmailPartLength && strings.Contains(lower, local) {
+		return apperror.BadRequestError("password must not contain the email")
+	}
+	return nil
+}
+
+func emailLocalPart(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		return email[:i]
+	}
+	return email
+}
+
+// LoadCommonPasswords reads a file with one password per line. Empty lines and lines starting with # are skipped.
+func LoadCommonPasswords(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open common passwords file. error: %w", err)
+	}
+	defer f.Close()
+
+	passwords := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read common passwords file. error: %w", err)
+	}
+	return passwords, nil
+}
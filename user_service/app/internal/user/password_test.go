@@ -0,0 +1,78 @@
+package user
+
+import (
+	"errors"
+	"github.com/theartofdevel/notes_system/user_service/internal/apperror"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:    8,
+		RequireUpper: true,
+		RequireLower: true,
+		RequireDigit: true,
+		Common:       map[string]struct{}{"password1a": {}},
+	}
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		email    string
+		valid    bool
+	}{
+		{name: "valid", policy: policy, password: "Notes2021", valid: true},
+		{name: "empty", policy: policy, password: ""},
+		{name: "too short", policy: policy, password: "Note202"},
+		{name: "length is counted in characters", policy: policy, password: "Пароль12", valid: true},
+		{name: "no upper case", policy: policy, password: "notes2021"},
+		{name: "no lower case", policy: policy, password: "NOTES2021"},
+		{name: "no digit", policy: policy, password: "NotesNotes"},
+		{name: "no special", policy: PasswordPolicy{RequireSpecial: true}, password: "Notes2021"},
+		{name: "special", policy: PasswordPolicy{RequireSpecial: true}, password: "Notes 2021!", valid: true},
+		{name: "common", policy: policy, password: "Password1a"},
+		{name: "email", policy: policy, password: "Jane.Doe2021", email: "jane.doe@example.com"},
+		{name: "email in the middle", policy: policy, password: "My1jane.doe!", email: "Jane.Doe@example.com"},
+		{name: "other email", policy: policy, password: "Notes2021", email: "jane@example.com", valid: true},
+		{name: "short email is allowed", policy: policy, password: "Notes2021jd", email: "jd@example.com", valid: true},
+		{name: "nothing required", policy: PasswordPolicy{}, password: "1", valid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.password, tt.email)
+			if tt.valid {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+			var appErr *apperror.AppError
+			if !errors.As(err, &appErr) || appErr.Code != "NS-000002" {
+				t.Errorf("error = %v, want bad request", err)
+			}
+		})
+	}
+}
+
+// Test scenario:
+// 1. Comments and empty lines of the file are skipped
+// 2. Passwords are compared case-insensitively
+func TestLoadCommonPasswords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "common.txt")
+	if err := ioutil.WriteFile(path, []byte("# comment\n\nQwerty\n 123456 \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	common, err := LoadCommonPasswords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(common) != 2 {
+		t.Errorf("loaded %d passwords, want 2: %v", len(common), common)
+	}
+	policy := PasswordPolicy{Common: common}
+	if err = policy.Validate("QWERTY", ""); err == nil {
+		t.Error("common password in other case is accepted")
+	}
+}
@@ -0,0 +1,99 @@
+package user
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	// timezones are validated in images without zoneinfo too
+	_ "time/tzdata"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	SortUpdatedAtDesc = "updated_at_desc"
+	SortUpdatedAtAsc  = "updated_at_asc"
+	SortHeaderAsc     = "header_asc"
+	SortHeaderDesc    = "header_desc"
+
+	maxDisplayNameLength = 64
+)
+
+var (
+	sortOrders = map[string]bool{SortUpdatedAtDesc: true, SortUpdatedAtAsc: true, SortHeaderAsc: true, SortHeaderDesc: true}
+	// localeRegexp accepts BCP 47 tags of language, optional script and region, e.g. en, en-US, sr-Latn-RS
+	localeRegexp = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z][a-z]{3})?(-([A-Z]{2}|[0-9]{3}))?$`)
+	fileIDRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+)
+
+type Preferences struct {
+	DefaultCategoryUUID string `json:"default_category_uuid,omitempty" bson:"default_category_uuid,omitempty"`
+	NoteSortOrder       string `json:"note_sort_order" bson:"note_sort_order,omitempty"`
+}
+
+// UpdateProfileDTO changes only fields which are present, empty string clears a field
+type UpdateProfileDTO struct {
+	DisplayName  *string `json:"display_name"`
+	AvatarFileID *string `json:"avatar_file_id"`
+	Locale       *string `json:"locale"`
+	Timezone     *string `json:"timezone"`
+}
+
+// UpdatePreferencesDTO changes only fields which are present, empty string resets a field to default
+type UpdatePreferencesDTO struct {
+	DefaultCategoryUUID *string `json:"default_category_uuid"`
+	NoteSortOrder       *string `json:"note_sort_order"`
+}
+
+// setDefaultPreferences fills preferences users didn't choose
+func setDefaultPreferences(u *User) {
+	if u.Preferences == nil {
+		u.Preferences = &Preferences{}
+	}
+	if u.Preferences.NoteSortOrder == "" {
+		u.Preferences.NoteSortOrder = SortUpdatedAtDesc
+	}
+}
+
+func (dto *UpdateProfileDTO) validate() error {
+	if dto.DisplayName == nil && dto.AvatarFileID == nil && dto.Locale == nil && dto.Timezone == nil {
+		return fmt.Errorf("nothing to update")
+	}
+	if dto.DisplayName != nil {
+		name := strings.TrimSpace(*dto.DisplayName)
+		if utf8.RuneCountInString(name) > maxDisplayNameLength {
+			return fmt.Errorf("display name must be at most %d characters", maxDisplayNameLength)
+		}
+		if strings.IndexFunc(name, unicode.IsControl) != -1 {
+			return fmt.Errorf("display name must not contain control characters")
+		}
+		dto.DisplayName = &name
+	}
+	if dto.AvatarFileID != nil && *dto.AvatarFileID != "" && !fileIDRegexp.MatchString(*dto.AvatarFileID) {
+		return fmt.Errorf("invalid avatar file id")
+	}
+	if dto.Locale != nil && *dto.Locale != "" && !localeRegexp.MatchString(*dto.Locale) {
+		return fmt.Errorf("locale must be a language tag like en or en-US")
+	}
+	if dto.Timezone != nil && *dto.Timezone != "" {
+		// LoadLocation treats empty name and "Local" specially, only IANA names are accepted
+		if *dto.Timezone == "Local" {
+			return fmt.Errorf("timezone must be an IANA name like Europe/Berlin")
+		}
+		if _, err := time.LoadLocation(*dto.Timezone); err != nil {
+			return fmt.Errorf("timezone must be an IANA name like Europe/Berlin")
+		}
+	}
+	return nil
+}
+
+func (dto *UpdatePreferencesDTO) validate() error {
+	if dto.DefaultCategoryUUID == nil && dto.NoteSortOrder == nil {
+		return fmt.Errorf("nothing to update")
+	}
+	if dto.NoteSortOrder != nil && *dto.NoteSortOrder != "" && !sortOrders[*dto.NoteSortOrder] {
+		return fmt.Errorf("unknown note sort order %q", *dto.NoteSortOrder)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"strings"
+	"testing"
+)
+
+func str(s string) *string { return &s }
+
+func TestUpdateProfileDTOValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		dto   UpdateProfileDTO
+		valid bool
+	}{
+		{"empty", UpdateProfileDTO{}, false},
+		{"display name", UpdateProfileDTO{DisplayName: str("  Jane Doe ")}, true},
+		{"long display name", UpdateProfileDTO{DisplayName: str(strings.Repeat("a", 65))}, false},
+		{"control characters", UpdateProfileDTO{DisplayName: str("Jane\nDoe")}, false},
+		{"clear avatar", UpdateProfileDTO{AvatarFileID: str("")}, true},
+		{"avatar", UpdateProfileDTO{AvatarFileID: str("5d2a7e2e-7a4b-11eb-9439-0242ac130002")}, true},
+		{"avatar path", UpdateProfileDTO{AvatarFileID: str("../secret")}, false},
+		{"locale", UpdateProfileDTO{Locale: str("en-US")}, true},
+		{"locale with script", UpdateProfileDTO{Locale: str("sr-Latn-RS")}, true},
+		{"invalid locale", UpdateProfileDTO{Locale: str("english")}, false},
+		{"timezone", UpdateProfileDTO{Timezone: str("Europe/Berlin")}, true},
+		{"local timezone", UpdateProfileDTO{Timezone: str("Local")}, false},
+		{"unknown timezone", UpdateProfileDTO{Timezone: str("Mars/Olympus")}, false},
+	}
+	for _, tt := range tests {
+		err := tt.dto.validate()
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+// Test scenario:
+// 1. Display name is trimmed by validation
+// 2. Preferences of users who never saved them get default sort order
+func TestProfileDefaults(t *testing.T) {
+	dto := UpdateProfileDTO{DisplayName: str("  Jane ")}
+	if err := dto.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if *dto.DisplayName != "Jane" {
+		t.Errorf("display name is not trimmed: %q", *dto.DisplayName)
+	}
+
+	u := User{}
+	setDefaults(&u)
+	if u.Preferences == nil || u.Preferences.NoteSortOrder != SortUpdatedAtDesc {
+		t.Errorf("unexpected default preferences %+v", u.Preferences)
+	}
+
+	prefs := UpdatePreferencesDTO{NoteSortOrder: str("random")}
+	if err := prefs.validate(); err == nil {
+		t.Error("unknown sort order is accepted")
+	}
+}
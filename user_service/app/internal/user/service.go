@@ -6,20 +6,74 @@ import (
 	"fmt"
 	"github.com/theartofdevel/notes_system/user_service/internal/apperror"
 	"github.com/theartofdevel/notes_system/user_service/pkg/logging"
+	"github.com/theartofdevel/notes_system/user_service/pkg/mail"
 	"golang.org/x/crypto/bcrypt"
+	"net/url"
+	"strings"
+	"time"
 )
 
 var _ Service = &service{}
 
+// backgroundTimeout limits jobs that outlive requests, like sending emails
+const backgroundTimeout = time.Minute
+
+// APITokens deletes API tokens of users, tokens are kept by the token package which depends on this one
+type APITokens interface {
+	DeleteByUser(ctx context.Context, userUUID string) (int, error)
+}
+
 type service struct {
-	storage Storage
-	logger  logging.Logger
+	storage    Storage
+	apiTokens  APITokens
+	mailSender mail.Sender
+	cfg        Config
+	// dummyHash is compared against when email is unknown or the password hash is cheaper to compare,
+	// so response time doesn't reveal registered emails
+	dummyHash []byte
+	logger    logging.Logger
 }
 
-func NewService(userStorage Storage, logger logging.Logger) (Service, error) {
+type Config struct {
+	// VerifyURL is a link sent to users to confirm email, token is added as a query parameter
+	VerifyURL string
+	VerifyTTL time.Duration
+	// ResetPasswordURL is a link sent to users to set a new password, token is added as a query parameter
+	ResetPasswordURL string
+	ResetPasswordTTL time.Duration
+	PasswordPolicy   PasswordPolicy
+	BcryptCost       int
+	// AdminEmails get admin role once they are verified
+	AdminEmails []string
+	// MFAIssuer is shown in authenticator apps next to the account name
+	MFAIssuer string
+}
+
+func NewService(userStorage Storage, apiTokens APITokens, mailSender mail.Sender, cfg Config, logger logging.Logger) (Service, error) {
+	if _, err := url.ParseRequestURI(cfg.VerifyURL); err != nil {
+		return nil, fmt.Errorf("invalid verify url. error: %w", err)
+	}
+	if _, err := url.ParseRequestURI(cfg.ResetPasswordURL); err != nil {
+		return nil, fmt.Errorf("invalid reset password url. error: %w", err)
+	}
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	dummyPassword, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte(dummyPassword), cfg.BcryptCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate dummy hash. error: %w", err)
+	}
 	return &service{
-		storage: userStorage,
-		logger:  logger,
+		storage:    userStorage,
+		apiTokens:  apiTokens,
+		mailSender: mailSender,
+		cfg:        cfg,
+		dummyHash:  dummyHash,
+		logger:     logger,
 	}, nil
 }
 
@@ -27,8 +81,21 @@ type Service interface {
 	Create(ctx context.Context, dto CreateUserDTO) (string, error)
 	GetByEmailAndPassword(ctx context.Context, email, password string) (User, error)
 	GetOne(ctx context.Context, uuid string) (User, error)
+	GetAll(ctx context.Context) ([]User, error)
+	SetRoles(ctx context.Context, uuid string, roles []string) error
 	Update(ctx context.Context, dto UpdateUserDTO) error
 	Delete(ctx context.Context, uuid string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, dto ResetPasswordDTO) (User, error)
+	EnrollMFA(ctx context.Context, uuid string) (MFAEnrollment, error)
+	ConfirmMFA(ctx context.Context, dto MFACodeDTO) (MFABackupCodes, error)
+	VerifyMFA(ctx context.Context, dto MFACodeDTO) error
+	DisableMFA(ctx context.Context, dto MFACodeDTO) error
+	LoginWithIdentity(ctx context.Context, dto ExternalLoginDTO) (IdentityLogin, error)
+	UpdateProfile(ctx context.Context, uuid string, dto UpdateProfileDTO) error
+	UpdatePreferences(ctx context.Context, uuid string, dto UpdatePreferencesDTO) error
 }
 
 func (s service) Create(ctx context.Context, dto CreateUserDTO) (userUUID string, err error) {
@@ -37,15 +104,26 @@ func (s service) Create(ctx context.Context, dto CreateUserDTO) (userUUID string
 		return userUUID, apperror.BadRequestError("password does not match repeat password")
 	}
 
+	s.logger.Debug("check password policy")
+	if err = s.cfg.PasswordPolicy.Validate(dto.Password, dto.Email); err != nil {
+		return userUUID, err
+	}
+
 	user := NewUser(dto)
 
 	s.logger.Debug("generate password hash")
-	err = user.GeneratePasswordHash()
+	err = user.GeneratePasswordHash(s.cfg.BcryptCost)
 	if err != nil {
 		s.logger.Errorf("failed to create user due to error %v", err)
 		return
 	}
 
+	s.logger.Debug("generate verification token")
+	token, err := user.GenerateVerificationToken(s.cfg.VerifyTTL)
+	if err != nil {
+		return userUUID, fmt.Errorf("failed to create user. error: %w", err)
+	}
+
 	userUUID, err = s.storage.Create(ctx, user)
 
 	if err != nil {
@@ -55,23 +133,56 @@ func (s service) Create(ctx context.Context, dto CreateUserDTO) (userUUID string
 		return userUUID, fmt.Errorf("failed to create user. error: %w", err)
 	}
 
+	s.logger.Debug("send verification email")
+	if err = s.sendVerificationEmail(ctx, user.Email, token); err != nil {
+		s.logger.Errorf("failed to send verification email to user %s due to error %v", userUUID, err)
+	}
+
 	return userUUID, nil
 }
 
 func (s service) GetByEmailAndPassword(ctx context.Context, email, password string) (u User, err error) {
+	if email == "" || password == "" {
+		return u, apperror.ErrInvalidCredentials
+	}
+
 	u, err = s.storage.FindByEmail(ctx, email)
 
 	if err != nil {
 		if errors.Is(err, apperror.ErrNotFound) {
-			return u, err
+			bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+			return User{}, apperror.ErrInvalidCredentials
 		}
 		return u, fmt.Errorf("failed to find user by email. error: %w", err)
 	}
 
+	// users without a password, with a broken or a legacy hash of a lower cost are answered faster,
+	// the dummy hash is compared too, so response time doesn't reveal them
+	if hashCost, err := bcrypt.Cost([]byte(u.Password)); err != nil || hashCost < s.cfg.BcryptCost {
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+	}
+	if u.Password == "" {
+		return User{}, apperror.ErrInvalidCredentials
+	}
 	if err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
-		return u, apperror.ErrNotFound
+		return User{}, apperror.ErrInvalidCredentials
+	}
+
+	if u.NeedsRehash(s.cfg.BcryptCost) {
+		s.logger.Debugf("rehash password of user %s with cost %d", u.UUID, s.cfg.BcryptCost)
+		rehashed := User{UUID: u.UUID, Password: password}
+		if err = rehashed.GeneratePasswordHash(s.cfg.BcryptCost); err != nil {
+			s.logger.Errorf("failed to rehash password due to error %v", err)
+			return u, nil
+		}
+		if err = s.storage.Update(ctx, rehashed); err != nil {
+			s.logger.Errorf("failed to save rehashed password due to error %v", err)
+			return u, nil
+		}
+		u.Password = rehashed.Password
 	}
 
+	setDefaults(&u)
 	return u, nil
 }
 
@@ -84,9 +195,37 @@ func (s service) GetOne(ctx context.Context, uuid string) (u User, err error) {
 		}
 		return u, fmt.Errorf("failed to find user by uuid. error: %w", err)
 	}
+	setDefaults(&u)
 	return u, nil
 }
 
+func (s service) GetAll(ctx context.Context) ([]User, error) {
+	users, err := s.storage.FindAll(ctx)
+	if err != nil {
+		return users, fmt.Errorf("failed to find users. error: %w", err)
+	}
+	for i := range users {
+		setDefaults(&users[i])
+	}
+	return users, nil
+}
+
+func (s service) SetRoles(ctx context.Context, uuid string, roles []string) error {
+	s.logger.Debug("validate roles")
+	if err := validateRoles(roles); err != nil {
+		return apperror.BadRequestError(err.Error())
+	}
+
+	err := s.storage.SetRoles(ctx, uuid, roles)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to set user roles. error: %w", err)
+	}
+	return nil
+}
+
 func (s service) Update(ctx context.Context, dto UpdateUserDTO) error {
 	var updatedUser User
 	s.logger.Debug("compare old and new passwords")
@@ -108,13 +247,29 @@ func (s service) Update(ctx context.Context, dto UpdateUserDTO) error {
 
 	updatedUser = UpdatedUser(dto)
 
-	s.logger.Debug("generate password hash")
-	err := updatedUser.GeneratePasswordHash()
-	if err != nil {
-		return fmt.Errorf("failed to update user. error %w", err)
+	if updatedUser.Password != "" {
+		email := updatedUser.Email
+		if email == "" {
+			s.logger.Debug("get user email")
+			user, err := s.GetOne(ctx, dto.UUID)
+			if err != nil {
+				return err
+			}
+			email = user.Email
+		}
+
+		s.logger.Debug("check password policy")
+		if err := s.cfg.PasswordPolicy.Validate(updatedUser.Password, email); err != nil {
+			return err
+		}
+
+		s.logger.Debug("generate password hash")
+		if err := updatedUser.GeneratePasswordHash(s.cfg.BcryptCost); err != nil {
+			return fmt.Errorf("failed to update user. error %w", err)
+		}
 	}
 
-	err = s.storage.Update(ctx, updatedUser)
+	err := s.storage.Update(ctx, updatedUser)
 
 	if err != nil {
 		if errors.Is(err, apperror.ErrNotFound) {
@@ -125,6 +280,36 @@ func (s service) Update(ctx context.Context, dto UpdateUserDTO) error {
 	return nil
 }
 
+func (s service) UpdateProfile(ctx context.Context, uuid string, dto UpdateProfileDTO) error {
+	s.logger.Debug("validate profile")
+	if err := dto.validate(); err != nil {
+		return apperror.BadRequestError(err.Error())
+	}
+	err := s.storage.UpdateProfile(ctx, uuid, dto)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to update profile. error: %w", err)
+	}
+	return nil
+}
+
+func (s service) UpdatePreferences(ctx context.Context, uuid string, dto UpdatePreferencesDTO) error {
+	s.logger.Debug("validate preferences")
+	if err := dto.validate(); err != nil {
+		return apperror.BadRequestError(err.Error())
+	}
+	err := s.storage.UpdatePreferences(ctx, uuid, dto)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to update preferences. error: %w", err)
+	}
+	return nil
+}
+
 func (s service) Delete(ctx context.Context, uuid string) error {
 	err := s.storage.Delete(ctx, uuid)
 
@@ -136,3 +321,381 @@ func (s service) Delete(ctx context.Context, uuid string) error {
 	}
 	return err
 }
+
+func (s service) VerifyEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return apperror.BadRequestError("verification token is required")
+	}
+
+	u, err := s.storage.FindByVerificationToken(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return apperror.BadRequestError("invalid or expired verification token")
+		}
+		return fmt.Errorf("failed to find user by verification token. error: %w", err)
+	}
+
+	if err = s.storage.SetEmailVerified(ctx, u.UUID); err != nil {
+		return fmt.Errorf("failed to verify email. error: %w", err)
+	}
+	return s.grantAdminRole(ctx, &u)
+}
+
+// ResendVerification sends a new verification link, the old one stops working. Unknown and verified emails
+// are ignored and the link is sent in the background, so the response doesn't reveal registered emails.
+func (s service) ResendVerification(ctx context.Context, email string) error {
+	if email == "" {
+		return apperror.BadRequestError("email is required")
+	}
+
+	u, err := s.storage.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			s.logger.Debug("verification resend requested for unknown email")
+			return nil
+		}
+		return fmt.Errorf("failed to find user by email. error: %w", err)
+	}
+	if u.EmailVerified {
+		s.logger.Debugf("verification resend requested for verified user %s", u.UUID)
+		return nil
+	}
+
+	s.inBackground("resend verification email", func(ctx context.Context) error {
+		token, err := u.GenerateVerificationToken(s.cfg.VerifyTTL)
+		if err != nil {
+			return err
+		}
+		if err = s.storage.SetVerificationToken(ctx, u.UUID, u.VerificationToken, u.VerificationExpiresAt); err != nil {
+			return fmt.Errorf("failed to save verification token. error: %w", err)
+		}
+		s.logger.Debugf("send verification email to user %s", u.UUID)
+		return s.sendVerificationEmail(ctx, u.Email, token)
+	})
+	return nil
+}
+
+func (s service) ForgotPassword(ctx context.Context, email string) error {
+	if email == "" {
+		return apperror.BadRequestError("email is required")
+	}
+
+	u, err := s.storage.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			// don't tell whether the email is registered
+			s.logger.Debug("password reset requested for unknown email")
+			return nil
+		}
+		return fmt.Errorf("failed to find user by email. error: %w", err)
+	}
+
+	// the token is saved and sent in the background, so the response doesn't depend on whether the email is registered
+	s.inBackground("send password reset email", func(ctx context.Context) error {
+		return s.sendPasswordReset(ctx, u)
+	})
+	return nil
+}
+
+func (s service) sendPasswordReset(ctx context.Context, u User) error {
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().UTC().Add(s.cfg.ResetPasswordTTL)
+	if err = s.storage.SetPasswordResetToken(ctx, u.UUID, hashToken(token), expiresAt); err != nil {
+		return fmt.Errorf("failed to save password reset token. error: %w", err)
+	}
+
+	s.logger.Debugf("send password reset email to user %s", u.UUID)
+	return s.mailSender.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Follow the link to set a new password: %s\nThe link expires in %s.\n",
+			linkWithToken(s.cfg.ResetPasswordURL, token), s.cfg.ResetPasswordTTL),
+	})
+}
+
+func (s service) ResetPassword(ctx context.Context, dto ResetPasswordDTO) (u User, err error) {
+	if dto.Token == "" {
+		return u, apperror.BadRequestError("reset token is required")
+	}
+	if dto.Password != dto.RepeatPassword {
+		return u, apperror.BadRequestError("password does not match repeat password")
+	}
+
+	u, err = s.storage.FindByPasswordResetToken(ctx, hashToken(dto.Token))
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return u, apperror.BadRequestError("invalid or expired reset token")
+		}
+		return u, fmt.Errorf("failed to find user by reset token. error: %w", err)
+	}
+
+	s.logger.Debug("check password policy")
+	if err = s.cfg.PasswordPolicy.Validate(dto.Password, u.Email); err != nil {
+		return User{}, err
+	}
+
+	s.logger.Debug("generate password hash")
+	passwordHash, err := generatePasswordHash(dto.Password, s.cfg.BcryptCost)
+	if err != nil {
+		return User{}, fmt.Errorf("failed to reset password. error: %w", err)
+	}
+
+	u, err = s.storage.ResetPassword(ctx, hashToken(dto.Token), passwordHash)
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return u, apperror.BadRequestError("invalid or expired reset token")
+		}
+		return u, fmt.Errorf("failed to reset password. error: %w", err)
+	}
+	setDefaults(&u)
+	return u, nil
+}
+
+func (s service) EnrollMFA(ctx context.Context, uuid string) (e MFAEnrollment, err error) {
+	u, err := s.GetOne(ctx, uuid)
+	if err != nil {
+		return e, err
+	}
+	if u.MFAEnabled {
+		return e, apperror.BadRequestError("2FA is already enabled")
+	}
+
+	s.logger.Debug("generate totp secret")
+	e, err = generateMFAEnrollment(s.cfg.MFAIssuer, u.Email)
+	if err != nil {
+		return e, err
+	}
+	// the secret becomes active only after the user confirms it with a code
+	if err = s.storage.SetMFAPendingSecret(ctx, u.UUID, e.Secret); err != nil {
+		return e, fmt.Errorf("failed to save totp secret. error: %w", err)
+	}
+	return e, nil
+}
+
+func (s service) ConfirmMFA(ctx context.Context, dto MFACodeDTO) (c MFABackupCodes, err error) {
+	u, err := s.GetOne(ctx, dto.UUID)
+	if err != nil {
+		return c, err
+	}
+	if u.MFAEnabled {
+		return c, apperror.BadRequestError("2FA is already enabled")
+	}
+	if u.MFAPendingSecret == "" {
+		return c, apperror.BadRequestError("2FA enrollment is not started")
+	}
+
+	step, ok := validateTOTP(u.MFAPendingSecret, dto.Code, 0, time.Now())
+	if !ok {
+		return c, apperror.BadRequestError("invalid code")
+	}
+
+	s.logger.Debug("generate backup codes")
+	codes, hashes, err := generateBackupCodes()
+	if err != nil {
+		return c, err
+	}
+	if err = s.storage.EnableMFA(ctx, u.UUID, u.MFAPendingSecret, hashes, step); err != nil {
+		return c, fmt.Errorf("failed to enable 2FA. error: %w", err)
+	}
+	s.logger.Infof("2FA enabled for user %s", u.UUID)
+
+	return MFABackupCodes{Codes: codes}, nil
+}
+
+// VerifyMFA accepts either a TOTP code or a backup code, a backup code can be used only once
+func (s service) VerifyMFA(ctx context.Context, dto MFACodeDTO) error {
+	if dto.Code == "" {
+		return apperror.ErrInvalidCredentials
+	}
+	u, err := s.GetOne(ctx, dto.UUID)
+	if err != nil {
+		return err
+	}
+	if !u.MFAEnabled {
+		return apperror.BadRequestError("2FA is not enabled")
+	}
+
+	if step, ok := validateTOTP(u.MFASecret, dto.Code, u.MFALastStep, time.Now()); ok {
+		err = s.storage.SetMFALastStep(ctx, u.UUID, step)
+		if err != nil {
+			if errors.Is(err, apperror.ErrNotFound) {
+				// the same code was used concurrently
+				return apperror.ErrInvalidCredentials
+			}
+			return fmt.Errorf("failed to save totp step. error: %w", err)
+		}
+		return nil
+	}
+
+	err = s.storage.UseMFABackupCode(ctx, u.UUID, hashBackupCode(dto.Code))
+	if err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			return apperror.ErrInvalidCredentials
+		}
+		return fmt.Errorf("failed to use backup code. error: %w", err)
+	}
+	s.logger.Infof("user %s used a backup code", u.UUID)
+	return nil
+}
+
+func (s service) DisableMFA(ctx context.Context, dto MFACodeDTO) error {
+	if err := s.VerifyMFA(ctx, dto); err != nil {
+		return err
+	}
+	if err := s.storage.DisableMFA(ctx, dto.UUID); err != nil {
+		return fmt.Errorf("failed to disable 2FA. error: %w", err)
+	}
+	s.logger.Infof("2FA disabled for user %s", dto.UUID)
+	return nil
+}
+
+// LoginWithIdentity finds the user of an external identity. Unknown identities are linked to the user
+// with the same email, or a new user without password is created, only if the provider verified the email.
+func (s service) LoginWithIdentity(ctx context.Context, dto ExternalLoginDTO) (l IdentityLogin, err error) {
+	if dto.Issuer == "" || dto.Subject == "" {
+		return l, apperror.BadRequestError("issuer and subject are required")
+	}
+	identity := Identity{Issuer: dto.Issuer, Subject: dto.Subject}
+
+	s.logger.Debug("find user by identity")
+	u, err := s.storage.FindByIdentity(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		setDefaults(&u)
+		return IdentityLogin{User: u}, nil
+	}
+	if !errors.Is(err, apperror.ErrNotFound) {
+		return l, fmt.Errorf("failed to find user by identity. error: %w", err)
+	}
+
+	if dto.Email == "" || !dto.EmailVerified {
+		return l, apperror.BadRequestError("identity provider did not verify the email")
+	}
+
+	s.logger.Debug("find user by email")
+	u, err = s.storage.FindByEmail(ctx, dto.Email)
+	if err == nil {
+		return s.linkIdentity(ctx, u, identity)
+	}
+	if !errors.Is(err, apperror.ErrNotFound) {
+		return l, fmt.Errorf("failed to find user by email. error: %w", err)
+	}
+
+	s.logger.Debug("create user for identity")
+	u = User{
+		Email:         dto.Email,
+		EmailVerified: true,
+		Roles:         []string{RoleUser},
+		Identities:    []Identity{identity},
+	}
+	u.Roles = s.rolesOfVerified(u)
+	u.UUID, err = s.storage.Create(ctx, u)
+	if err != nil {
+		return l, fmt.Errorf("failed to create user. error: %w", err)
+	}
+	s.logger.Infof("user %s created for identity %s of %s", u.UUID, identity.Subject, identity.Issuer)
+	setDefaults(&u)
+	return IdentityLogin{User: u}, nil
+}
+
+// linkIdentity links the identity to the user with the same email. Password, 2FA, roles and API tokens
+// of an unverified account may be set by someone who doesn't own the email, the provider proved
+// the ownership so such account is taken over: all of them are reset.
+func (s service) linkIdentity(ctx context.Context, u User, identity Identity) (l IdentityLogin, err error) {
+	takeOver := !u.EmailVerified
+	if takeOver {
+		// tokens are deleted first, so a failure leaves the account as it was and the login can be repeated
+		deleted, err := s.apiTokens.DeleteByUser(ctx, u.UUID)
+		if err != nil {
+			return l, fmt.Errorf("failed to delete api tokens. error: %w", err)
+		}
+		s.logger.Infof("user %s is taken over by identity %s of %s, %d api tokens deleted",
+			u.UUID, identity.Subject, identity.Issuer, deleted)
+	}
+
+	if err = s.storage.LinkIdentity(ctx, u.UUID, identity, takeOver); err != nil {
+		return l, fmt.Errorf("failed to link identity. error: %w", err)
+	}
+	s.logger.Infof("identity %s of %s linked to user %s", identity.Subject, identity.Issuer, u.UUID)
+	u.EmailVerified = true
+	u.Identities = append(u.Identities, identity)
+	if takeOver {
+		u.Password = ""
+		u.Roles = []string{RoleUser}
+		u.MFAEnabled, u.MFASecret, u.MFAPendingSecret, u.MFALastStep, u.MFABackupCodes = false, "", "", 0, nil
+	}
+	setDefaults(&u)
+	if err = s.grantAdminRole(ctx, &u); err != nil {
+		return l, err
+	}
+	return IdentityLogin{User: u, TakenOver: takeOver}, nil
+}
+
+// rolesOfVerified returns roles of the user with admin role added if the email is in AdminEmails,
+// the email must be verified before that
+func (s service) rolesOfVerified(u User) []string {
+	setDefaultRoles(&u)
+	isAdminEmail := false
+	for _, email := range s.cfg.AdminEmails {
+		if strings.EqualFold(email, u.Email) {
+			isAdminEmail = true
+		}
+	}
+	if !isAdminEmail {
+		return u.Roles
+	}
+	for _, r := range u.Roles {
+		if r == RoleAdmin || r == RoleReadOnly {
+			return u.Roles
+		}
+	}
+	return append(append([]string{}, u.Roles...), RoleAdmin)
+}
+
+// grantAdminRole saves admin role of a verified user whose email is in AdminEmails
+func (s service) grantAdminRole(ctx context.Context, u *User) error {
+	setDefaultRoles(u)
+	roles := s.rolesOfVerified(*u)
+	if len(roles) == len(u.Roles) {
+		return nil
+	}
+	s.logger.Infof("grant admin role to %s", u.Email)
+	if err := s.storage.SetRoles(ctx, u.UUID, roles); err != nil {
+		return fmt.Errorf("failed to grant admin role. error: %w", err)
+	}
+	u.Roles = roles
+	return nil
+}
+
+// inBackground runs the job detached from the request, its error is logged
+func (s service) inBackground(name string, job func(ctx context.Context) error) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), backgroundTimeout)
+		defer cancel()
+		if err := job(ctx); err != nil {
+			s.logger.Errorf("failed to %s due to error %v", name, err)
+		}
+	}()
+}
+
+func (s service) sendVerificationEmail(ctx context.Context, email, token string) error {
+	return s.mailSender.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Follow the link to confirm your email: %s\nThe link expires in %s.\n",
+			linkWithToken(s.cfg.VerifyURL, token), s.cfg.VerifyTTL),
+	})
+}
+
+func linkWithToken(base, token string) string {
+	link, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+	q := link.Query()
+	q.Set("token", token)
+	link.RawQuery = q.Encode()
+	return link.String()
+}
@@ -0,0 +1,404 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/theartofdevel/notes_system/user_service/internal/apperror"
+	"github.com/theartofdevel/notes_system/user_service/pkg/logging"
+	"github.com/theartofdevel/notes_system/user_service/pkg/mail"
+	"golang.org/x/crypto/bcrypt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStorage keeps users by uuid and counts of their API tokens, methods not used by tests panic
+type fakeStorage struct {
+	Storage
+	mu        sync.Mutex
+	users     map[string]User
+	apiTokens map[string]int
+}
+
+func (s *fakeStorage) DeleteByUser(ctx context.Context, userUUID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := s.apiTokens[userUUID]
+	delete(s.apiTokens, userUUID)
+	return deleted, nil
+}
+
+func (s *fakeStorage) FindByIdentity(ctx context.Context, issuer, subject string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		for _, i := range u.Identities {
+			if i.Issuer == issuer && i.Subject == subject {
+				return u, nil
+			}
+		}
+	}
+	return User{}, apperror.ErrNotFound
+}
+
+func (s *fakeStorage) LinkIdentity(ctx context.Context, uuid string, identity Identity, takeOver bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.users[uuid]
+	u.EmailVerified = true
+	u.Identities = append(u.Identities, identity)
+	if takeOver {
+		u.Password, u.Roles = "", []string{RoleUser}
+		u.MFAEnabled, u.MFASecret, u.MFABackupCodes = false, "", nil
+	}
+	s.users[uuid] = u
+	return nil
+}
+
+func (s *fakeStorage) FindByEmail(ctx context.Context, email string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return User{}, apperror.ErrNotFound
+}
+
+func (s *fakeStorage) SetPasswordResetToken(ctx context.Context, uuid, tokenHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[uuid]
+	if !ok {
+		return apperror.ErrNotFound
+	}
+	u.PasswordResetToken, u.PasswordResetExpiresAt = tokenHash, expiresAt
+	s.users[uuid] = u
+	return nil
+}
+
+func (s *fakeStorage) SetVerificationToken(ctx context.Context, uuid, tokenHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[uuid]
+	if !ok {
+		return apperror.ErrNotFound
+	}
+	u.VerificationToken, u.VerificationExpiresAt = tokenHash, expiresAt
+	s.users[uuid] = u
+	return nil
+}
+
+func (s *fakeStorage) Create(ctx context.Context, u User) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u.UUID = strconv.Itoa(len(s.users) + 1)
+	s.users[u.UUID] = u
+	return u.UUID, nil
+}
+
+func (s *fakeStorage) FindByVerificationToken(ctx context.Context, tokenHash string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.VerificationToken == tokenHash && u.VerificationExpiresAt.After(time.Now()) {
+			return u, nil
+		}
+	}
+	return User{}, apperror.ErrNotFound
+}
+
+func (s *fakeStorage) SetEmailVerified(ctx context.Context, uuid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.users[uuid]
+	u.EmailVerified, u.VerificationToken = true, ""
+	s.users[uuid] = u
+	return nil
+}
+
+func (s *fakeStorage) SetRoles(ctx context.Context, uuid string, roles []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[uuid]
+	if !ok {
+		return apperror.ErrNotFound
+	}
+	u.Roles = roles
+	s.users[uuid] = u
+	return nil
+}
+
+func (s *fakeStorage) Update(ctx context.Context, u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.users[u.UUID]
+	if !ok {
+		return apperror.ErrNotFound
+	}
+	if u.Password != "" {
+		stored.Password = u.Password
+	}
+	s.users[u.UUID] = stored
+	return nil
+}
+
+func (s *fakeStorage) get(uuid string) User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.users[uuid]
+}
+
+// fakeSender passes sent messages to the channel and fails when err is set
+type fakeSender struct {
+	sent chan mail.Message
+	err  error
+}
+
+func (s *fakeSender) Send(ctx context.Context, msg mail.Message) error {
+	s.sent <- msg
+	return s.err
+}
+
+func newTestService(t *testing.T, users ...User) (Service, *fakeStorage, *fakeSender) {
+	t.Helper()
+	return newTestServiceWithCost(t, bcrypt.MinCost, users...)
+}
+
+func newTestServiceWithCost(t *testing.T, cost int, users ...User) (Service, *fakeStorage, *fakeSender) {
+	t.Helper()
+	storage := &fakeStorage{users: make(map[string]User), apiTokens: make(map[string]int)}
+	for _, u := range users {
+		storage.users[u.UUID] = u
+	}
+	sender := &fakeSender{sent: make(chan mail.Message, 10)}
+	l := logrus.New()
+	l.SetOutput(ioutil.Discard)
+	s, err := NewService(storage, storage, sender, Config{
+		VerifyURL:        "http://localhost/verify",
+		VerifyTTL:        time.Hour,
+		ResetPasswordURL: "http://localhost/reset-password",
+		ResetPasswordTTL: 30 * time.Minute,
+		BcryptCost:       cost,
+		AdminEmails:      []string{"admin@example.com"},
+	}, logging.Logger{Entry: logrus.NewEntry(l)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, storage, sender
+}
+
+func waitMessage(t *testing.T, sender *fakeSender) mail.Message {
+	t.Helper()
+	select {
+	case msg := <-sender.sent:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("message is not sent")
+		return mail.Message{}
+	}
+}
+
+func noMessage(t *testing.T, sender *fakeSender) {
+	t.Helper()
+	select {
+	case msg := <-sender.sent:
+		t.Errorf("unexpected message to %s", msg.To)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// Test scenario:
+// 1. Unknown and registered emails get the same answer, even when the mail server fails
+// 2. Only the registered user gets the link, its token is saved as a hash
+func TestForgotPassword(t *testing.T) {
+	s, storage, sender := newTestService(t, User{UUID: "1", Email: "jane@example.com"})
+	sender.err = errors.New("mail server is down")
+	ctx := context.Background()
+
+	if err := s.ForgotPassword(ctx, "john@example.com"); err != nil {
+		t.Errorf("unknown email: error = %v", err)
+	}
+	noMessage(t, sender)
+
+	if err := s.ForgotPassword(ctx, "jane@example.com"); err != nil {
+		t.Errorf("registered email: error = %v", err)
+	}
+	msg := waitMessage(t, sender)
+	if msg.To != "jane@example.com" {
+		t.Errorf("message is sent to %s", msg.To)
+	}
+
+	u := storage.get("1")
+	if u.PasswordResetToken == "" || strings.Contains(msg.Body, u.PasswordResetToken) {
+		t.Errorf("reset token hash %q is not saved or is sent", u.PasswordResetToken)
+	}
+
+	var appErr *apperror.AppError
+	if err := s.ForgotPassword(ctx, ""); !errors.As(err, &appErr) || appErr.Code != "NS-000002" {
+		t.Errorf("empty email: error = %v, want bad request", err)
+	}
+}
+
+// Test scenario:
+// 1. Unknown and verified emails get no link
+// 2. Unverified user gets a new link which expires
+func TestResendVerification(t *testing.T) {
+	s, storage, sender := newTestService(t,
+		User{UUID: "1", Email: "jane@example.com"},
+		User{UUID: "2", Email: "john@example.com", EmailVerified: true},
+	)
+	ctx := context.Background()
+
+	for _, email := range []string{"unknown@example.com", "john@example.com"} {
+		if err := s.ResendVerification(ctx, email); err != nil {
+			t.Errorf("%s: error = %v", email, err)
+		}
+	}
+	noMessage(t, sender)
+
+	if err := s.ResendVerification(ctx, "jane@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := waitMessage(t, sender); msg.To != "jane@example.com" {
+		t.Errorf("message is sent to %s", msg.To)
+	}
+	u := storage.get("1")
+	if u.VerificationToken == "" || u.VerificationExpiresAt.IsZero() {
+		t.Errorf("verification token is not saved with expiry: %+v", u)
+	}
+}
+
+var tokenParam = regexp.MustCompile(`token=([\w-]+)`)
+
+// Test scenario:
+// 1. Signup with an email from AdminEmails gives plain user role
+// 2. Admin role is granted when the email is verified
+func TestAdminRoleAfterVerification(t *testing.T) {
+	s, storage, sender := newTestService(t)
+	ctx := context.Background()
+
+	uuid, err := s.Create(ctx, CreateUserDTO{Email: "Admin@example.com", Password: "Notes2021", RepeatPassword: "Notes2021"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roles := storage.get(uuid).Roles; len(roles) != 1 || roles[0] != RoleUser {
+		t.Errorf("roles of unverified user = %v", roles)
+	}
+
+	match := tokenParam.FindStringSubmatch(waitMessage(t, sender).Body)
+	if match == nil {
+		t.Fatal("verification link has no token")
+	}
+	if err = s.VerifyEmail(ctx, match[1]); err != nil {
+		t.Fatal(err)
+	}
+	if roles := storage.get(uuid).Roles; len(roles) != 2 || roles[1] != RoleAdmin {
+		t.Errorf("roles of verified user = %v", roles)
+	}
+}
+
+// Test scenario:
+// 1. Unverified account with the email of the identity is taken over: its password, 2FA, roles
+// and API tokens are reset
+// 2. Verified account is linked as it is
+// 3. Linked identity finds its user without taking it over again
+func TestLoginWithIdentityTakesOver(t *testing.T) {
+	s, storage, _ := newTestService(t,
+		User{UUID: "1", Email: "jane@example.com", Password: "hash", Roles: []string{RoleUser, RoleAdmin},
+			MFAEnabled: true, MFASecret: "secret", MFABackupCodes: []string{"code"}},
+		User{UUID: "2", Email: "john@example.com", Password: "hash", EmailVerified: true, MFAEnabled: true},
+	)
+	storage.apiTokens["1"], storage.apiTokens["2"] = 2, 1
+	ctx := context.Background()
+	dto := ExternalLoginDTO{Issuer: "idp", Subject: "jane", Email: "jane@example.com", EmailVerified: true}
+
+	login, err := s.LoginWithIdentity(ctx, dto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !login.TakenOver || login.UUID != "1" {
+		t.Errorf("login = %+v, want take over of user 1", login)
+	}
+	u := storage.get("1")
+	if u.Password != "" || u.MFAEnabled || u.MFASecret != "" || len(u.Roles) != 1 || u.Roles[0] != RoleUser {
+		t.Errorf("taken over user is not reset: %+v", u)
+	}
+	if login.MFAEnabled || len(login.Roles) != 1 {
+		t.Errorf("returned user is not reset: %+v", login.User)
+	}
+	if _, ok := storage.apiTokens["1"]; ok {
+		t.Error("api tokens of taken over user are kept")
+	}
+
+	login, err = s.LoginWithIdentity(ctx, ExternalLoginDTO{Issuer: "idp", Subject: "john", Email: "john@example.com", EmailVerified: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.TakenOver || !login.MFAEnabled || storage.get("2").Password == "" || storage.apiTokens["2"] != 1 {
+		t.Errorf("verified user is reset: %+v", login)
+	}
+
+	login, err = s.LoginWithIdentity(ctx, dto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.TakenOver || login.UUID != "1" {
+		t.Errorf("second login = %+v", login)
+	}
+}
+
+// Test scenario:
+// 1. Users without a password, with a broken or a legacy hash are answered not faster than a compare at the
+// configured cost, whether the password is right or not
+// 2. Legacy hash is rehashed with the configured cost after the login
+func TestLoginTakesUniformTime(t *testing.T) {
+	const cost = 10
+	legacy, err := bcrypt.GenerateFromPassword([]byte("Notes2021"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, storage, _ := newTestServiceWithCost(t, cost,
+		User{UUID: "1", Email: "jane@example.com", Password: string(legacy)},
+		User{UUID: "2", Email: "john@example.com"},
+		User{UUID: "3", Email: "ann@example.com", Password: "broken"},
+	)
+	ctx := context.Background()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("Notes2021"), cost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	bcrypt.CompareHashAndPassword(hash, []byte("Notes2021"))
+	uniform := time.Since(started)
+
+	logins := []struct {
+		email, password string
+	}{
+		{"jane@example.com", "wrong"},
+		{"john@example.com", "Notes2021"},
+		{"ann@example.com", "Notes2021"},
+		{"jane@example.com", "Notes2021"},
+	}
+	for i, login := range logins {
+		started = time.Now()
+		_, err = s.GetByEmailAndPassword(ctx, login.email, login.password)
+		if elapsed := time.Since(started); elapsed < uniform/2 {
+			t.Errorf("%s: login took %v, compare at cost %d takes %v", login.email, elapsed, cost, uniform)
+		}
+		if last := i == len(logins)-1; last != (err == nil) {
+			t.Errorf("%s: error = %v", login.email, err)
+		}
+	}
+
+	if hashCost, _ := bcrypt.Cost([]byte(storage.get("1").Password)); hashCost != cost {
+		t.Errorf("cost of rehashed password = %d, want %d", hashCost, cost)
+	}
+}
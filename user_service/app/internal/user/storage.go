@@ -2,12 +2,37 @@ package user
 
 import (
 	"context"
+	"time"
 )
 
 type Storage interface {
 	Create(ctx context.Context, user User) (string, error)
 	FindByEmail(ctx context.Context, email string) (User, error)
 	FindOne(ctx context.Context, uuid string) (User, error)
+	FindAll(ctx context.Context) ([]User, error)
+	FindByIdentity(ctx context.Context, issuer, subject string) (User, error)
+	// LinkIdentity adds the identity and marks email verified. If takeOver is set password and 2FA are removed
+	// and roles are reset to plain user.
+	LinkIdentity(ctx context.Context, uuid string, identity Identity, takeOver bool) error
+	// FindByVerificationToken returns ErrNotFound if there is no such token or it is expired
+	FindByVerificationToken(ctx context.Context, tokenHash string) (User, error)
+	SetVerificationToken(ctx context.Context, uuid, tokenHash string, expiresAt time.Time) error
+	SetEmailVerified(ctx context.Context, uuid string) error
+	SetPasswordResetToken(ctx context.Context, uuid, tokenHash string, expiresAt time.Time) error
+	// FindByPasswordResetToken returns ErrNotFound if there is no such token or it is expired
+	FindByPasswordResetToken(ctx context.Context, tokenHash string) (User, error)
+	// ResetPassword sets new password hash if the token is valid and not expired, the token is removed
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string) (User, error)
+	SetRoles(ctx context.Context, uuid string, roles []string) error
+	SetMFAPendingSecret(ctx context.Context, uuid, secret string) error
+	EnableMFA(ctx context.Context, uuid, secret string, backupCodeHashes []string, step int64) error
+	// SetMFALastStep returns ErrNotFound if the step is not greater than the saved one
+	SetMFALastStep(ctx context.Context, uuid string, step int64) error
+	// UseMFABackupCode removes the code hash, ErrNotFound is returned if there is no such code
+	UseMFABackupCode(ctx context.Context, uuid, codeHash string) error
+	DisableMFA(ctx context.Context, uuid string) error
 	Update(ctx context.Context, user User) error
+	UpdateProfile(ctx context.Context, uuid string, dto UpdateProfileDTO) error
+	UpdatePreferences(ctx context.Context, uuid string, dto UpdatePreferencesDTO) error
 	Delete(ctx context.Context, uuid string) error
 }
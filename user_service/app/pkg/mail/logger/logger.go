@@ -0,0 +1,23 @@
+package logger
+
+import (
+	"context"
+	"github.com/theartofdevel/notes_system/user_service/pkg/logging"
+	"github.com/theartofdevel/notes_system/user_service/pkg/mail"
+)
+
+var _ mail.Sender = &sender{}
+
+// sender only writes messages to the log. Use it for local development.
+type sender struct {
+	logger logging.Logger
+}
+
+func NewSender(logger logging.Logger) mail.Sender {
+	return &sender{logger: logger}
+}
+
+func (s *sender) Send(ctx context.Context, msg mail.Message) error {
+	s.logger.Infof("mail to: %s, subject: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
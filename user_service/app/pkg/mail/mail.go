@@ -0,0 +1,14 @@
+package mail
+
+import "context"
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Sender interface {
+	// Send delivers a plain text message to a single recipient.
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,57 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/theartofdevel/notes_system/user_service/pkg/mail"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+var _ mail.Sender = &sender{}
+
+type sender struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSender creates SMTP sender. Auth is skipped when username is empty, e.g. for MailHog.
+func NewSender(host, port, username, password, from string) mail.Sender {
+	s := sender{
+		addr: net.JoinHostPort(host, port),
+		from: from,
+	}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &s
+}
+
+func (s *sender) Send(ctx context.Context, msg mail.Message) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	buf.WriteString(msg.Body)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, buf.Bytes())
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to send mail. error: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to send mail. error: %w", ctx.Err())
+	}
+}